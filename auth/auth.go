@@ -3,54 +3,266 @@ package auth
 
 import (
 	"fmt"
+	"log"
 	"net/http"
+	"os"
 	"recipe-book/database"
 	"recipe-book/models"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtKey = []byte("your-secret-key-change-in-production")
+// devJWTSecret is the fallback signing key, only used when DEV_MODE is enabled
+// and JWT_SECRET is not set. Never rely on it in production.
+const devJWTSecret = "dev-only-insecure-jwt-secret"
+
+var jwtKey []byte
+
+const (
+	// tokenTTL is how long a freshly issued token stays valid
+	tokenTTL = 24 * time.Hour
+	// refreshGracePeriod allows a token that expired recently to still be refreshed
+	refreshGracePeriod = time.Hour
+	// defaultMaxTokenLifetime caps how long a session can be extended by refreshing
+	defaultMaxTokenLifetime = 7 * 24 * time.Hour
+)
+
+// maxTokenLifetime is measured from the original login, see JWT_MAX_LIFETIME
+var maxTokenLifetime = defaultMaxTokenLifetime
+
+// InitJWTSecret loads the JWT signing key from the JWT_SECRET environment variable.
+// It exits if the variable is empty unless DEV_MODE is enabled.
+func InitJWTSecret() {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		if !IsDevMode() {
+			log.Fatal("❌ JWT_SECRET environment variable is required (set DEV_MODE=true to use an insecure development key)")
+		}
+		log.Println("⚠️  JWT_SECRET not set, using insecure development key (DEV_MODE)")
+		secret = devJWTSecret
+	}
+
+	SetJWTSecret(secret)
+
+	if lifetime := os.Getenv("JWT_MAX_LIFETIME"); lifetime != "" {
+		d, err := time.ParseDuration(lifetime)
+		if err != nil || d <= 0 {
+			log.Fatalf("❌ Invalid JWT_MAX_LIFETIME %q: expected a positive duration such as 168h", lifetime)
+		}
+		maxTokenLifetime = d
+	}
+}
+
+// bcryptCost is the work factor new password hashes are made with, see BCRYPT_COST.
+// Existing hashes record their own cost, so they keep verifying when it changes.
+var bcryptCost = bcrypt.DefaultCost
+
+// InitBcryptCost loads the password hashing cost from the BCRYPT_COST environment
+// variable, exiting if it is outside the range bcrypt supports
+func InitBcryptCost() {
+	if value := os.Getenv("BCRYPT_COST"); value != "" {
+		cost, err := strconv.Atoi(value)
+		if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			log.Fatalf("❌ Invalid BCRYPT_COST %q: expected a whole number from %d to %d", value, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		bcryptCost = cost
+	}
+
+	log.Printf("🔐 Hashing passwords with bcrypt cost %d", bcryptCost)
+}
+
+// HashPassword hashes a new password with the configured bcrypt cost
+func HashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+}
+
+// secureCookies marks the auth cookie Secure, so it is only sent over HTTPS
+var secureCookies bool
+
+// cookieSameSite limits which cross-site requests carry the auth cookie
+var cookieSameSite = http.SameSiteLaxMode
+
+// sameSiteModes are the values COOKIE_SAMESITE accepts
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+// InitCookies loads the auth cookie's attributes from SECURE_COOKIES, which should be
+// true whenever the site is served over HTTPS, and COOKIE_SAMESITE (lax, strict or none).
+// It exits on invalid values, and on SameSite=None without Secure, which browsers reject.
+func InitCookies() {
+	if value := os.Getenv("SECURE_COOKIES"); value != "" {
+		secure, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("❌ Invalid SECURE_COOKIES %q: expected true or false", value)
+		}
+		secureCookies = secure
+	}
+
+	if value := os.Getenv("COOKIE_SAMESITE"); value != "" {
+		mode, ok := sameSiteModes[strings.ToLower(value)]
+		if !ok {
+			log.Fatalf("❌ Invalid COOKIE_SAMESITE %q: expected lax, strict or none", value)
+		}
+		cookieSameSite = mode
+	}
+
+	if cookieSameSite == http.SameSiteNoneMode && !secureCookies {
+		log.Fatal("❌ COOKIE_SAMESITE=none requires SECURE_COOKIES=true")
+	}
+	if !secureCookies && !IsDevMode() {
+		log.Println("⚠️  SECURE_COOKIES is not set: the session cookie is also sent over plain HTTP")
+	}
+}
+
+// SetJWTSecret sets the key used to sign and verify tokens
+func SetJWTSecret(secret string) {
+	jwtKey = []byte(secret)
+}
+
+// IsDevMode reports whether the DEV_MODE environment flag is enabled
+func IsDevMode() bool {
+	devMode, _ := strconv.ParseBool(os.Getenv("DEV_MODE"))
+	return devMode
+}
 
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
+	// TokenVersion must match the user's current one, which changes to revoke every token
+	TokenVersion int `json:"token_version"`
+	// AuthTime is when the user originally logged in; it is carried over on refresh
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
 	jwt.RegisteredClaims
 }
 
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	if len(jwtKey) == 0 {
+		return nil, fmt.Errorf("JWT secret not configured")
+	}
+	return jwtKey, nil
+}
+
+func getUserByID(userID int) (*models.User, error) {
+	var user models.User
+	err := database.DB.QueryRow("SELECT id, username, email, COALESCE(is_admin, 0), COALESCE(email_verified, 0), COALESCE(token_version, 0) FROM users WHERE id = ?", userID).
+		Scan(&user.ID, &user.Username, &user.Email, &user.IsAdmin, &user.EmailVerified, &user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// userForClaims looks up the user a token was issued to, rejecting tokens that were
+// revoked by a change of the user's token version
+func userForClaims(claims *Claims) (*models.User, error) {
+	user, err := getUserByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenVersion != user.TokenVersion {
+		return nil, fmt.Errorf("token revoked")
+	}
+	return user, nil
+}
+
 func GetUserFromToken(r *http.Request) (*models.User, error) {
+	claims, err := parseToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return userForClaims(claims)
+}
+
+// UserIDFromToken returns the ID of the user the request's token was issued to, or 0
+// without a valid token. Unlike GetUserFromToken it does not look the user up, so it is
+// cheap enough to call for every request, but it can't tell revoked tokens apart either.
+func UserIDFromToken(r *http.Request) int {
+	claims, err := parseToken(r)
+	if err != nil {
+		return 0
+	}
+	return claims.UserID
+}
+
+// parseToken verifies the request's auth_token cookie and returns its claims
+func parseToken(r *http.Request) (*Claims, error) {
 	cookie, err := r.Cookie("auth_token")
 	if err != nil {
 		return nil, err
 	}
 
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(cookie.Value, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+	token, err := jwt.ParseWithClaims(cookie.Value, claims, keyFunc)
 
 	if err != nil || !token.Valid {
 		return nil, fmt.Errorf("invalid token")
 	}
 
-	var user models.User
-	err = database.DB.QueryRow("SELECT id, username, email FROM users WHERE id = ?", claims.UserID).
-		Scan(&user.ID, &user.Username, &user.Email)
+	return claims, nil
+}
+
+// RefreshToken validates the request's token, allowing it to have expired within the
+// grace period, and issues a new one. Sessions older than the maximum lifetime must log in again.
+func RefreshToken(r *http.Request) (string, *models.User, error) {
+	cookie, err := r.Cookie("auth_token")
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
-	return &user, nil
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(cookie.Value, claims, keyFunc, jwt.WithLeeway(refreshGracePeriod))
+	if err != nil || !token.Valid {
+		return "", nil, fmt.Errorf("invalid token")
+	}
+
+	authTime := claims.AuthTime
+	if authTime == nil {
+		authTime = claims.IssuedAt
+	}
+	if authTime == nil || time.Since(authTime.Time) > maxTokenLifetime {
+		return "", nil, fmt.Errorf("session too old to refresh")
+	}
+
+	user, err := userForClaims(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tokenString, err := createToken(user, authTime.Time)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, user, nil
 }
 
 func CreateToken(user *models.User) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+	return createToken(user, time.Now())
+}
+
+func createToken(user *models.User, authTime time.Time) (string, error) {
+	if len(jwtKey) == 0 {
+		return "", fmt.Errorf("JWT secret not configured")
+	}
+
+	now := time.Now()
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
+		UserID:       user.ID,
+		Username:     user.Username,
+		TokenVersion: user.TokenVersion,
+		AuthTime:     jwt.NewNumericDate(authTime),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
 		},
 	}
 
@@ -64,21 +276,24 @@ func CreateToken(user *models.User) (string, error) {
 }
 
 func SetAuthCookie(w http.ResponseWriter, tokenString string) {
-	expirationTime := time.Now().Add(24 * time.Hour)
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    tokenString,
-		Expires:  expirationTime,
-		HttpOnly: true,
-		Path:     "/",
-	})
+	// Keep the cookie through the refresh grace period so an expired token can still be refreshed
+	http.SetCookie(w, authCookie(tokenString, time.Now().Add(tokenTTL+refreshGracePeriod)))
 }
 
 func ClearAuthCookie(w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
-		Name:    "auth_token",
-		Value:   "",
-		Expires: time.Now().Add(-time.Hour),
-		Path:    "/",
-	})
+	// Browsers only replace the cookie with one of the same attributes
+	http.SetCookie(w, authCookie("", time.Now().Add(-time.Hour)))
+}
+
+// authCookie is the auth_token cookie, with the attributes configured by InitCookies
+func authCookie(value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     "auth_token",
+		Value:    value,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secureCookies,
+		SameSite: cookieSameSite,
+		Path:     "/",
+	}
 }
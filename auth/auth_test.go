@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"recipe-book/database"
+	"recipe-book/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupTestDB initializes a fresh database, whose seeded admin has user ID 1
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("DB_PATH", filepath.Join(dir, "recipes.db"))
+	database.InitDB()
+	t.Cleanup(func() { database.DB.Close() })
+}
+
+// requestWithToken returns a request carrying tokenString in the auth cookie
+func requestWithToken(tokenString string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	r.AddCookie(&http.Cookie{Name: "auth_token", Value: tokenString})
+	return r
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	setupTestDB(t)
+	SetJWTSecret("test-secret")
+	t.Cleanup(func() { SetJWTSecret("") })
+
+	admin, err := getUserByID(1)
+	if err != nil {
+		t.Fatalf("loading the seeded admin: %v", err)
+	}
+
+	tokenString, err := CreateToken(admin)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	user, err := GetUserFromToken(requestWithToken(tokenString))
+	if err != nil {
+		t.Fatalf("GetUserFromToken: %v", err)
+	}
+	if user.ID != admin.ID || user.Username != admin.Username {
+		t.Errorf("GetUserFromToken returned user %d %q, want %d %q", user.ID, user.Username, admin.ID, admin.Username)
+	}
+
+	// A token signed with another secret must not verify
+	SetJWTSecret("another-secret")
+	if _, err := GetUserFromToken(requestWithToken(tokenString)); err == nil {
+		t.Error("GetUserFromToken accepted a token signed with a different secret")
+	}
+}
+
+func TestCreateTokenWithoutSecret(t *testing.T) {
+	SetJWTSecret("")
+
+	if _, err := CreateToken(&models.User{ID: 1, Username: "admin"}); err == nil {
+		t.Error("CreateToken signed a token without a secret")
+	}
+}
+
+func TestHashPasswordCost(t *testing.T) {
+	t.Cleanup(func() { bcryptCost = bcrypt.DefaultCost })
+
+	// Hashes made before the cost changed must keep verifying
+	var hashes [][]byte
+	for _, cost := range []int{bcrypt.MinCost, bcrypt.MinCost + 1, 6} {
+		t.Setenv("BCRYPT_COST", strconv.Itoa(cost))
+		InitBcryptCost()
+
+		hash, err := HashPassword("correct horse battery")
+		if err != nil {
+			t.Fatalf("HashPassword at cost %d: %v", cost, err)
+		}
+		if got, _ := bcrypt.Cost(hash); got != cost {
+			t.Errorf("BCRYPT_COST=%d hashed with cost %d", cost, got)
+		}
+		hashes = append(hashes, hash)
+
+		for i, hash := range hashes {
+			if err := bcrypt.CompareHashAndPassword(hash, []byte("correct horse battery")); err != nil {
+				t.Errorf("hash %d no longer verifies at cost %d: %v", i, cost, err)
+			}
+			if bcrypt.CompareHashAndPassword(hash, []byte("wrong horse battery")) == nil {
+				t.Errorf("hash %d accepts a wrong password", i)
+			}
+		}
+	}
+}
+
+func TestAuthCookieAttributes(t *testing.T) {
+	t.Cleanup(func() { secureCookies, cookieSameSite = false, http.SameSiteLaxMode })
+
+	tests := []struct {
+		secure     string
+		sameSite   string
+		wantSecure bool
+		wantMode   http.SameSite
+	}{
+		{"", "", false, http.SameSiteLaxMode},
+		{"true", "", true, http.SameSiteLaxMode},
+		{"false", "Strict", false, http.SameSiteStrictMode},
+		{"1", "none", true, http.SameSiteNoneMode},
+	}
+
+	for _, tt := range tests {
+		secureCookies, cookieSameSite = false, http.SameSiteLaxMode
+		t.Setenv("SECURE_COOKIES", tt.secure)
+		t.Setenv("COOKIE_SAMESITE", tt.sameSite)
+		InitCookies()
+
+		w := httptest.NewRecorder()
+		SetAuthCookie(w, "token-value")
+		ClearAuthCookie(w)
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 2 {
+			t.Fatalf("got %d cookies, want the set and the cleared one", len(cookies))
+		}
+		for i, cookie := range cookies {
+			if cookie.Name != "auth_token" || !cookie.HttpOnly || cookie.Path != "/" ||
+				cookie.Secure != tt.wantSecure || cookie.SameSite != tt.wantMode {
+				t.Errorf("SECURE_COOKIES=%q COOKIE_SAMESITE=%q: cookie %d is %q HttpOnly=%v Path=%q Secure=%v SameSite=%v, want HttpOnly on / with Secure=%v SameSite=%v",
+					tt.secure, tt.sameSite, i, cookie.Name, cookie.HttpOnly, cookie.Path, cookie.Secure, cookie.SameSite, tt.wantSecure, tt.wantMode)
+			}
+		}
+
+		if set := cookies[0]; set.Value != "token-value" || !set.Expires.After(time.Now()) {
+			t.Errorf("set cookie has value %q expiring %v", set.Value, set.Expires)
+		}
+		if cleared := cookies[1]; cleared.Value != "" || !cleared.Expires.Before(time.Now()) {
+			t.Errorf("cleared cookie has value %q expiring %v", cleared.Value, cleared.Expires)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"recipe-book/utils"
+)
+
+// DeleteUserAccount removes a user together with their recipes, favorites, ratings, comments and views,
+// then deletes the uploaded image files that belonged to their recipes
+func DeleteUserAccount(userID int) error {
+	if !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid user ID")
+	}
+
+	// Collect image filenames before the rows referencing them are gone
+	rows, err := DB.Query(`
+		SELECT ri.filename
+		FROM recipe_images ri
+		JOIN recipes r ON ri.recipe_id = r.id
+		WHERE r.created_by = ?
+	`, userID)
+	if err != nil {
+		return err
+	}
+	var filenames []string
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err == nil {
+			filenames = append(filenames, filename)
+		}
+	}
+	rows.Close()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Failed logins are keyed by username rather than user ID, so they don't cascade
+	// and would otherwise lock out whoever registers the name next
+	if _, err := tx.Exec("DELETE FROM login_attempts WHERE username = (SELECT username FROM users WHERE id = ?)", userID); err != nil {
+		return err
+	}
+
+	// Recipes, tokens and the rest go with the user through ON DELETE CASCADE
+	result, err := tx.Exec("DELETE FROM users WHERE id = ?", userID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, filename := range filenames {
+		imagePath := filepath.Join("uploads", filename)
+		if err := os.Remove(imagePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove image %s: %v", imagePath, err)
+		}
+	}
+
+	return nil
+}
+
+// RevokeUserSessions signs a user out everywhere by bumping their token version, which
+// every token issued so far no longer matches
+func RevokeUserSessions(userID int) error {
+	if !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid user ID")
+	}
+
+	_, err := DB.Exec("UPDATE users SET token_version = COALESCE(token_version, 0) + 1 WHERE id = ?", userID)
+	return err
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"recipe-book/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// auditTimeLayout matches how SQLite stores CURRENT_TIMESTAMP
+const auditTimeLayout = "2006-01-02 15:04:05"
+
+// Maximum stored lengths, matching the audit_log CHECK constraints
+const (
+	maxAuditEventLength   = 100
+	maxAuditIPLength      = 100
+	maxAuditDetailsLength = 2000
+)
+
+// AuditFilter narrows an audit log query. Zero values match everything.
+type AuditFilter struct {
+	EventType string
+	From      time.Time
+	To        time.Time
+}
+
+// WriteAuditLog stores a security event. userID is 0 when no user is known.
+// It matches utils.AuditSink so it can be registered with utils.SetAuditSink.
+func WriteAuditLog(eventType string, userID int, clientIP, details string) error {
+	// InitDB runs in the background, so events can arrive before the database is ready
+	if DB == nil {
+		return errors.New("database not initialized")
+	}
+
+	var user interface{}
+	if userID > 0 {
+		user = userID
+	}
+
+	_, err := DB.Exec("INSERT INTO audit_log (event_type, user_id, client_ip, details) VALUES (?, ?, ?, ?)",
+		truncate(eventType, maxAuditEventLength), user, truncate(clientIP, maxAuditIPLength), truncate(details, maxAuditDetailsLength))
+	return err
+}
+
+// GetAuditLog returns one page of audit entries matching the filter, newest first,
+// along with the total number of matches
+func GetAuditLog(filter AuditFilter, limit, offset int) ([]models.AuditEntry, int, error) {
+	if limit <= 0 || offset < 0 {
+		return nil, 0, fmt.Errorf("invalid limit or offset")
+	}
+
+	var conditions []string
+	var args []interface{}
+	if filter.EventType != "" {
+		conditions = append(conditions, "event_type = ?")
+		args = append(args, filter.EventType)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.UTC().Format(auditTimeLayout))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, filter.To.UTC().Format(auditTimeLayout))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM audit_log "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT id, created_at, event_type, user_id, COALESCE(client_ip, ''), COALESCE(details, '')
+		FROM audit_log `+where+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var userID sql.NullInt64
+		if err := rows.Scan(&entry.ID, &entry.Timestamp, &entry.EventType, &userID, &entry.ClientIP, &entry.Details); err != nil {
+			continue
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			entry.UserID = &id
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, total, nil
+}
+
+// truncate shortens s to at most max bytes without splitting a UTF-8 character
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
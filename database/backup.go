@@ -0,0 +1,28 @@
+package database
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrBackupInProgress is returned when a backup is requested while another one is running
+var ErrBackupInProgress = errors.New("a backup is already in progress")
+
+var backupRunning atomic.Bool
+
+// BackupTo writes a consistent snapshot of the database to path, which must not exist yet.
+// The WAL is checkpointed first so that the main database file is complete as well.
+func BackupTo(path string) error {
+	if !backupRunning.CompareAndSwap(false, true) {
+		return ErrBackupInProgress
+	}
+	defer backupRunning.Store(false)
+
+	if _, err := DB.Exec("PRAGMA wal_checkpoint(FULL)"); err != nil {
+		return err
+	}
+
+	// VACUUM INTO copies the database as seen by a single read transaction
+	_, err := DB.Exec("VACUUM INTO ?", path)
+	return err
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OrphanMinAge protects recently written files from cleanup, as an upload saves the
+// file before its recipe_images row exists
+const OrphanMinAge = 10 * time.Minute
+
+// FindOrphanedImageFiles lists the files in uploadDir, by name, that no recipe_images row
+// refers to. Hidden files, directories and files modified within OrphanMinAge are skipped.
+func FindOrphanedImageFiles(uploadDir string) ([]string, error) {
+	entries, err := os.ReadDir(uploadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool)
+	rows, err := DB.Query("SELECT filename FROM recipe_images")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			return nil, err
+		}
+		referenced[filename] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-OrphanMinAge)
+	var orphans []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || referenced[name] {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		orphans = append(orphans, name)
+	}
+
+	sort.Strings(orphans)
+	return orphans, nil
+}
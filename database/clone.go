@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	cloneTitleSuffix = " (Copy)"
+	maxTitleLength   = 200
+)
+
+// CloneRecipe copies a recipe visible to userID, with its ingredients and tags, into a new
+// recipe owned by userID and returns the new recipe's ID. Images are not copied: image files belong to a
+// single recipe and are removed from disk when that recipe's image is deleted.
+func CloneRecipe(recipeID, userID int) (int64, error) {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
+		return 0, fmt.Errorf("invalid recipe or user ID")
+	}
+
+	source, err := GetRecipeByIDSecure(recipeID, userID)
+	if err != nil {
+		return 0, ErrRecipeNotFound
+	}
+
+	tagIDs := make([]int, 0, len(source.Tags))
+	for _, tag := range source.Tags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+
+	clone := &models.Recipe{
+		Title:         cloneTitle(source.Title),
+		Description:   source.Description,
+		Instructions:  source.Instructions,
+		PrepTime:      source.PrepTime,
+		CookTime:      source.CookTime,
+		Servings:      source.Servings,
+		ServingUnit:   source.ServingUnit,
+		YieldQuantity: source.YieldQuantity,
+		YieldUnit:     source.YieldUnit,
+		Cuisine:       source.Cuisine,
+		SourceURL:     source.SourceURL,
+		VideoURL:      source.VideoURL,
+		IsPublic:      source.IsPublic,
+		Status:        source.Status,
+		CreatedBy:     userID,
+		Ingredients:   source.Ingredients,
+	}
+
+	// The copy, its ingredients and its tags are written in one transaction
+	return CreateRecipeWithRelations(clone, tagIDs)
+}
+
+// cloneTitle appends the copy suffix, shortening the title if needed to stay within the limit
+func cloneTitle(title string) string {
+	title = strings.TrimSpace(title)
+	for len(title)+len(cloneTitleSuffix) > maxTitleLength {
+		_, size := utf8.DecodeLastRuneInString(title)
+		title = title[:len(title)-size]
+	}
+	return strings.TrimSpace(title) + cloneTitleSuffix
+}
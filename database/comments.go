@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// ErrCommentNotFound is returned when a comment does not exist
+var ErrCommentNotFound = errors.New("comment not found")
+
+// ErrCommentAccessDenied is returned when a user may not delete a comment
+var ErrCommentAccessDenied = errors.New("comment access denied")
+
+// GetRecipeComments returns the comments on a recipe visible to the viewer, with their authors, oldest first
+func GetRecipeComments(recipeID, viewerID int) ([]models.Comment, error) {
+	if !utils.IsValidID(recipeID) {
+		return nil, fmt.Errorf("invalid recipe ID")
+	}
+
+	exists, err := recipeVisible(recipeID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrRecipeNotFound
+	}
+
+	rows, err := DB.Query(`
+		SELECT c.id, c.recipe_id, c.user_id, u.username, c.body, c.created_at
+		FROM recipe_comments c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.recipe_id = ?
+		ORDER BY c.created_at ASC, c.id ASC
+	`, recipeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var comment models.Comment
+		if err := rows.Scan(&comment.ID, &comment.RecipeID, &comment.UserID, &comment.AuthorName,
+			&comment.Body, &comment.CreatedAt); err != nil {
+			continue
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, nil
+}
+
+// AddComment stores a comment on a recipe and returns its ID. The body must already be sanitized.
+func AddComment(recipeID, userID int, body string) (int64, error) {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
+		return 0, fmt.Errorf("invalid recipe or user ID")
+	}
+
+	exists, err := recipeVisible(recipeID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrRecipeNotFound
+	}
+
+	result, err := DB.Exec("INSERT INTO recipe_comments (recipe_id, user_id, body) VALUES (?, ?, ?)",
+		recipeID, userID, body)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// DeleteComment removes a comment. Only the comment's author or the recipe's owner may delete it.
+func DeleteComment(commentID, userID int) error {
+	if !utils.IsValidID(commentID) || !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid comment or user ID")
+	}
+
+	var authorID, recipeOwnerID int
+	err := DB.QueryRow(`
+		SELECT c.user_id, r.created_by
+		FROM recipe_comments c
+		JOIN recipes r ON c.recipe_id = r.id
+		WHERE c.id = ?
+	`, commentID).Scan(&authorID, &recipeOwnerID)
+	if err == sql.ErrNoRows {
+		return ErrCommentNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if userID != authorID && userID != recipeOwnerID {
+		return ErrCommentAccessDenied
+	}
+
+	_, err = DB.Exec("DELETE FROM recipe_comments WHERE id = ?", commentID)
+	return err
+}
@@ -3,17 +3,21 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"recipe-book/models"
 	"recipe-book/utils"
+	"strconv"
 	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 var DB *sql.DB
@@ -22,14 +26,13 @@ var (
 	stmtGetUser          *sql.Stmt
 	stmtCreateUser       *sql.Stmt
 	stmtGetRecipeByID    *sql.Stmt
-	stmtSearchRecipes    *sql.Stmt
 	stmtCreateRecipe     *sql.Stmt
-	stmtUpdateRecipe     *sql.Stmt
 	stmtDeleteRecipe     *sql.Stmt
 	stmtCreateIngredient *sql.Stmt
 	stmtDeleteIngredient *sql.Stmt
 	stmtCreateTag        *sql.Stmt
 	stmtDeleteTag        *sql.Stmt
+	stmtUpdateTag        *sql.Stmt
 )
 
 func InitDB() {
@@ -55,24 +58,30 @@ func InitDB() {
 		}
 	}
 
-	DB, err = sql.Open("sqlite", dbPath)
+	DB, err = openWithRetry(dbPath)
 	if err != nil {
 		log.Fatal("Failed to open database:", err)
 	}
 
+	// The file may only have become reachable while retrying, so whether the database
+	// is new is decided by its schema rather than by the earlier file check
+	if err := DB.QueryRow("SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'users'").Scan(&dbExists); err != nil {
+		log.Fatal("Failed to inspect database:", err)
+	}
+
 	// Set connection pool settings for performance
 	DB.SetMaxOpenConns(10) // Reduced for startup speed
 	DB.SetMaxIdleConns(5)
 	DB.SetConnMaxLifetime(5 * time.Minute)
 
-	// Enable WAL mode and other pragmas for performance
+	// Enable WAL mode and other pragmas for performance. Foreign keys are enabled through
+	// the DSN instead, as they have to be on for every pooled connection.
 	_, err = DB.Exec(`
 		PRAGMA journal_mode = WAL;
 		PRAGMA synchronous = NORMAL;
 		PRAGMA cache_size = 2000;
 		PRAGMA temp_store = memory;
 		PRAGMA mmap_size = 268435456;
-		PRAGMA foreign_keys = ON;
 	`)
 	if err != nil {
 		log.Printf("Warning: Failed to set some database pragmas: %v", err)
@@ -81,7 +90,6 @@ func InitDB() {
 	// Only run heavy initialization if database is new
 	if !dbExists {
 		log.Println("📊 Setting up new database...")
-		migrateDatabase()
 		createTables()
 		insertDefaultIngredients()
 		insertDefaultTags()
@@ -90,9 +98,8 @@ func InitDB() {
 		fmt.Println("✅ New database initialized successfully")
 	} else {
 		log.Println("📊 Using existing database...")
-		// Just ensure tables exist and run critical migrations
-		createTables()        // This is idempotent
-		migrateServingUnits() // Run any necessary migrations
+		// Ensure tables exist and apply any pending migrations
+		createTables()
 	}
 
 	// Prepare statements after database is ready
@@ -101,11 +108,81 @@ func InitDB() {
 	fmt.Println("🚀 Database ready for connections")
 }
 
+// Defaults for connecting at startup, see DB_CONNECT_ATTEMPTS and DB_CONNECT_RETRY_DELAY
+const (
+	defaultConnectAttempts   = 5
+	defaultConnectRetryDelay = 500 * time.Millisecond
+)
+
+// openWithRetry opens and pings the database, retrying with exponential backoff so that
+// storage which is still being mounted when the container starts does not stop the server
+func openWithRetry(dbPath string) (*sql.DB, error) {
+	attempts, delay := connectRetryConfig()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var db *sql.DB
+		db, err = sql.Open(driverName, connectionDSN(dbPath))
+		if err == nil {
+			if err = db.Ping(); err == nil {
+				return db, nil
+			}
+			db.Close()
+		}
+
+		if attempt == attempts {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+		}
+
+		log.Printf("⚠️  Database connection attempt %d/%d failed: %v (retrying in %v)", attempt, attempts, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// connectionDSN adds the connection settings to a database path. Pragmas only apply to
+// the connection they run on, so the driver runs them on each one it opens:
+//   - foreign keys let ON DELETE CASCADE clean up after deletes
+//   - transactions begin IMMEDIATE, taking the write lock up front, so two writers never
+//     both act on what they read before one of them committed
+//   - a busy timeout makes the second writer wait for the lock instead of failing
+func connectionDSN(dbPath string) string {
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return dbPath + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate"
+}
+
+// connectRetryConfig reads DB_CONNECT_ATTEMPTS and DB_CONNECT_RETRY_DELAY, the delay before
+// the first retry, which doubles after every further failure
+func connectRetryConfig() (int, time.Duration) {
+	attempts := defaultConnectAttempts
+	if value := os.Getenv("DB_CONNECT_ATTEMPTS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			log.Fatalf("❌ Invalid DB_CONNECT_ATTEMPTS %q: expected a positive whole number", value)
+		}
+		attempts = n
+	}
+
+	delay := defaultConnectRetryDelay
+	if value := os.Getenv("DB_CONNECT_RETRY_DELAY"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			log.Fatalf("❌ Invalid DB_CONNECT_RETRY_DELAY %q: expected a positive duration such as 500ms", value)
+		}
+		delay = d
+	}
+
+	return attempts, delay
+}
+
 func prepareStatements() {
 	var err error
 
 	// User-related statements
-	stmtGetUser, err = DB.Prepare("SELECT id, username, email, password FROM users WHERE username = ?")
+	stmtGetUser, err = DB.Prepare("SELECT id, username, email, password, COALESCE(token_version, 0) FROM users WHERE username = ?")
 	if err != nil {
 		log.Fatal("Failed to prepare stmtGetUser:", err)
 	}
@@ -117,61 +194,31 @@ func prepareStatements() {
 
 	// Recipe-related statements
 	stmtGetRecipeByID, err = DB.Prepare(`
-		SELECT r.id, r.title, r.description, r.instructions, r.prep_time, r.cook_time, 
-		       r.servings, COALESCE(r.serving_unit, 'people'), r.created_by, r.created_at, u.username
+		SELECT ` + recipeColumns + `
 		FROM recipes r
 		JOIN users u ON r.created_by = u.id
-		WHERE r.id = ?
+		WHERE r.id = ? AND r.deleted_at IS NULL
 	`)
 	if err != nil {
 		log.Fatal("Failed to prepare stmtGetRecipeByID:", err)
 	}
 
-	stmtSearchRecipes, err = DB.Prepare(`
-		SELECT DISTINCT r.id, r.title, r.description, r.instructions, r.prep_time, r.cook_time, 
-		       r.servings, COALESCE(r.serving_unit, 'people'), r.created_by, r.created_at, u.username
-		FROM recipes r
-		JOIN users u ON r.created_by = u.id
-		LEFT JOIN recipe_ingredients ri ON r.id = ri.recipe_id
-		LEFT JOIN ingredients i ON ri.ingredient_id = i.id
-		LEFT JOIN recipe_tags rt ON r.id = rt.recipe_id
-		LEFT JOIN tags t ON rt.tag_id = t.id
-		WHERE r.title LIKE ? 
-		   OR r.description LIKE ? 
-		   OR r.instructions LIKE ?
-		   OR i.name LIKE ?
-		   OR t.name LIKE ?
-		ORDER BY 
-		   CASE WHEN r.title LIKE ? THEN 0 ELSE 1 END,
-		   r.created_at DESC
-	`)
-	if err != nil {
-		log.Fatal("Failed to prepare stmtSearchRecipes:", err)
-	}
-
 	stmtCreateRecipe, err = DB.Prepare(`
-		INSERT INTO recipes (title, description, instructions, prep_time, cook_time, servings, serving_unit, created_by)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO recipes (title, slug, description, instructions, prep_time, cook_time, servings, serving_unit, yield_quantity, yield_unit, cuisine, source_url, video_url, is_public, status, created_by, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 	`)
 	if err != nil {
 		log.Fatal("Failed to prepare stmtCreateRecipe:", err)
 	}
 
-	stmtUpdateRecipe, err = DB.Prepare(`
-		UPDATE recipes SET title = ?, description = ?, instructions = ?, 
-		prep_time = ?, cook_time = ?, servings = ?, serving_unit = ? WHERE id = ? AND created_by = ?
-	`)
-	if err != nil {
-		log.Fatal("Failed to prepare stmtUpdateRecipe:", err)
-	}
-
-	stmtDeleteRecipe, err = DB.Prepare("DELETE FROM recipes WHERE id = ? AND created_by = ?")
+	// Deleting a recipe moves it to the recycle bin; PurgeOldDeletedRecipes removes it for good
+	stmtDeleteRecipe, err = DB.Prepare("UPDATE recipes SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND created_by = ? AND deleted_at IS NULL")
 	if err != nil {
 		log.Fatal("Failed to prepare stmtDeleteRecipe:", err)
 	}
 
 	// Ingredient statements
-	stmtCreateIngredient, err = DB.Prepare("INSERT INTO ingredients (name) VALUES (?)")
+	stmtCreateIngredient, err = DB.Prepare("INSERT INTO ingredients (name, category) VALUES (?, ?)")
 	if err != nil {
 		log.Fatal("Failed to prepare stmtCreateIngredient:", err)
 	}
@@ -191,32 +238,59 @@ func prepareStatements() {
 	if err != nil {
 		log.Fatal("Failed to prepare stmtDeleteTag:", err)
 	}
-}
 
-func migrateDatabase() {
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('ingredients') WHERE name='unit'").Scan(&count)
-	if err == nil && count > 0 {
-		fmt.Println("🔄 Migrating database schema...")
-		DB.Exec("DROP TABLE IF EXISTS recipe_ingredients")
-		DB.Exec("DROP TABLE IF EXISTS ingredients")
-		fmt.Println("✅ Database migration completed")
+	stmtUpdateTag, err = DB.Prepare("UPDATE tags SET name = ?, color = ? WHERE id = ?")
+	if err != nil {
+		log.Fatal("Failed to prepare stmtUpdateTag:", err)
 	}
 }
 
-func createTables() {
-	createTables := `
+// recipesTableSchema defines the recipes table; %s is the table name so that
+// the add_recipe_status migration can rebuild the table under a temporary name.
+// Drafts may be saved without instructions.
+const recipesTableSchema = `CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL CHECK(length(title) >= 1 AND length(title) <= 200),
+		slug TEXT CHECK(length(slug) <= 100),
+		description TEXT CHECK(length(description) <= 1000),
+		instructions TEXT NOT NULL CHECK(length(instructions) <= 10000 AND (length(instructions) >= 1 OR status = 'draft')),
+		prep_time INTEGER CHECK(prep_time >= 0 AND prep_time <= 1440),
+		cook_time INTEGER CHECK(cook_time >= 0 AND cook_time <= 1440),
+		servings INTEGER CHECK(servings >= 1 AND servings <= 100),
+		serving_unit TEXT DEFAULT 'people' CHECK(length(serving_unit) <= 20),
+		yield_quantity REAL NOT NULL DEFAULT 0 CHECK(yield_quantity >= 0 AND yield_quantity <= 10000),
+		yield_unit TEXT NOT NULL DEFAULT '' CHECK(length(yield_unit) <= 20),
+		cuisine TEXT DEFAULT '' CHECK(length(cuisine) <= 50),
+		source_url TEXT NOT NULL DEFAULT '' CHECK(length(source_url) <= 2048),
+		video_url TEXT NOT NULL DEFAULT '' CHECK(length(video_url) <= 2048),
+		view_count INTEGER NOT NULL DEFAULT 0,
+		is_public BOOLEAN DEFAULT 1,
+		status TEXT NOT NULL DEFAULT 'published' CHECK(status IN ('draft', 'published')),
+		created_by INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		deleted_at DATETIME,
+		FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE
+	);`
+
+// schema creates every table and index that does not exist yet. It describes the current
+// schema; older databases are brought up to date by the migrations in migrations.go.
+var schema = `
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		username TEXT UNIQUE NOT NULL CHECK(length(username) >= 3 AND length(username) <= 30),
 		email TEXT UNIQUE NOT NULL CHECK(length(email) <= 254),
 		password TEXT NOT NULL CHECK(length(password) >= 6),
+		is_admin BOOLEAN DEFAULT 0,
+		email_verified BOOLEAN DEFAULT 0,
+		token_version INTEGER NOT NULL DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 	
 	CREATE TABLE IF NOT EXISTS ingredients (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT UNIQUE NOT NULL CHECK(length(name) >= 1 AND length(name) <= 100)
+		name TEXT UNIQUE NOT NULL CHECK(length(name) >= 1 AND length(name) <= 100),
+		category TEXT NOT NULL DEFAULT 'other' CHECK(length(category) <= 20)
 	);
 
 	CREATE TABLE IF NOT EXISTS tags (
@@ -226,25 +300,16 @@ func createTables() {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 	
-	CREATE TABLE IF NOT EXISTS recipes (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL CHECK(length(title) >= 1 AND length(title) <= 200),
-		description TEXT CHECK(length(description) <= 1000),
-		instructions TEXT NOT NULL CHECK(length(instructions) >= 1 AND length(instructions) <= 10000),
-		prep_time INTEGER CHECK(prep_time >= 0 AND prep_time <= 1440),
-		cook_time INTEGER CHECK(cook_time >= 0 AND cook_time <= 1440),
-		servings INTEGER CHECK(servings >= 1 AND servings <= 100),
-		serving_unit TEXT DEFAULT 'people' CHECK(length(serving_unit) <= 20),
-		created_by INTEGER NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE
-	);
+	` + fmt.Sprintf(recipesTableSchema, "recipes") + `
 	
 	CREATE TABLE IF NOT EXISTS recipe_ingredients (
 		recipe_id INTEGER,
 		ingredient_id INTEGER,
 		quantity REAL NOT NULL CHECK(quantity > 0 AND quantity <= 10000),
 		unit TEXT NOT NULL CHECK(length(unit) >= 1 AND length(unit) <= 20),
+		position INTEGER NOT NULL DEFAULT 0, -- order in which the cook listed the ingredients
+		preparation TEXT NOT NULL DEFAULT '' CHECK(length(preparation) <= 100),
+		optional BOOLEAN NOT NULL DEFAULT 0,
 		PRIMARY KEY (recipe_id, ingredient_id),
 		FOREIGN KEY (recipe_id) REFERENCES recipes (id) ON DELETE CASCADE,
 		FOREIGN KEY (ingredient_id) REFERENCES ingredients (id) ON DELETE CASCADE
@@ -264,52 +329,159 @@ func createTables() {
 		filename TEXT NOT NULL CHECK(length(filename) <= 255),
 		caption TEXT CHECK(length(caption) <= 200),
 		display_order INTEGER DEFAULT 0,
+		is_primary BOOLEAN DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (recipe_id) REFERENCES recipes (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS favorites (
+		user_id INTEGER NOT NULL,
+		recipe_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, recipe_id),
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+		FOREIGN KEY (recipe_id) REFERENCES recipes (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS recipe_ratings (
+		recipe_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		rating INTEGER NOT NULL CHECK(rating BETWEEN 1 AND 5),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (recipe_id, user_id),
+		FOREIGN KEY (recipe_id) REFERENCES recipes (id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS recipe_comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		recipe_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		body TEXT NOT NULL CHECK(length(body) >= 1 AND length(body) <= 1000),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (recipe_id) REFERENCES recipes (id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS recipe_nutrition (
+		recipe_id INTEGER PRIMARY KEY,
+		calories REAL CHECK(calories >= 0),
+		protein_g REAL CHECK(protein_g >= 0),
+		carbs_g REAL CHECK(carbs_g >= 0),
+		fat_g REAL CHECK(fat_g >= 0),
+		FOREIGN KEY (recipe_id) REFERENCES recipes (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS meal_plans (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		recipe_id INTEGER NOT NULL,
+		plan_date DATE NOT NULL,
+		meal_type TEXT NOT NULL CHECK(meal_type IN ('breakfast', 'lunch', 'dinner', 'snack')),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+		FOREIGN KEY (recipe_id) REFERENCES recipes (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS recipe_views (
+		user_id INTEGER NOT NULL,
+		recipe_id INTEGER NOT NULL,
+		viewed_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, recipe_id),
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+		FOREIGN KEY (recipe_id) REFERENCES recipes (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		user_id INTEGER NOT NULL,
+		key TEXT NOT NULL CHECK(length(key) <= 128),
+		recipe_id INTEGER NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, key),
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
 		FOREIGN KEY (recipe_id) REFERENCES recipes (id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		event_type TEXT NOT NULL CHECK(length(event_type) <= 100),
+		user_id INTEGER,
+		client_ip TEXT CHECK(length(client_ip) <= 100),
+		details TEXT CHECK(length(details) <= 2000)
+	);
+
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		username TEXT PRIMARY KEY,
+		failures INTEGER NOT NULL DEFAULT 0,
+		window_start DATETIME NOT NULL,
+		locked_until DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		token TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS email_verification_tokens (
+		token TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+	);
+
 	-- Create indexes for better performance and security
 	CREATE INDEX IF NOT EXISTS idx_recipes_created_by ON recipes(created_by);
 	CREATE INDEX IF NOT EXISTS idx_recipes_title ON recipes(title);
 	CREATE INDEX IF NOT EXISTS idx_recipe_ingredients_recipe_id ON recipe_ingredients(recipe_id);
 	CREATE INDEX IF NOT EXISTS idx_recipe_tags_recipe_id ON recipe_tags(recipe_id);
 	CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
-	CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);`
+	CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+	CREATE INDEX IF NOT EXISTS idx_favorites_recipe_id ON favorites(recipe_id);
+	CREATE INDEX IF NOT EXISTS idx_recipe_comments_recipe_id ON recipe_comments(recipe_id);
+	CREATE INDEX IF NOT EXISTS idx_meal_plans_user_date ON meal_plans(user_id, plan_date);
+	CREATE INDEX IF NOT EXISTS idx_recipe_views_user_viewed ON recipe_views(user_id, viewed_at);
+	CREATE INDEX IF NOT EXISTS idx_recipe_views_recipe_id ON recipe_views(recipe_id);
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+	CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+	CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user_id ON email_verification_tokens(user_id);`
 
-	_, err := DB.Exec(createTables)
-	if err != nil {
+func createTables() {
+	if _, err := DB.Exec(schema); err != nil {
 		log.Fatal("Failed to create tables:", err)
 	}
 
-	migrateServingUnits()
+	if err := RunMigrations(); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
 }
 
-func migrateServingUnits() {
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('recipes') WHERE name='serving_unit'").Scan(&count)
-	if err != nil || count == 0 {
-		fmt.Println("🔄 Adding serving_unit column to recipes...")
-		_, err = DB.Exec("ALTER TABLE recipes ADD COLUMN serving_unit TEXT DEFAULT 'people'")
-		if err != nil {
-			log.Printf("Error adding serving_unit column: %v", err)
-		} else {
-			fmt.Println("✅ Added serving_unit column successfully")
-		}
-	}
+// defaultIngredients are created with a new database, with their categories
+var defaultIngredients = []struct {
+	Name     string
+	Category string
+}{
+	{"Salt", "spice"}, {"Pepper", "spice"}, {"Sugar", "pantry"}, {"Flour", "pantry"},
+	{"Butter", "dairy"}, {"Eggs", "dairy"}, {"Milk", "dairy"}, {"Oil", "pantry"},
+	{"Onion", "produce"}, {"Garlic", "produce"}, {"Tomato", "produce"}, {"Cheese", "dairy"},
+	{"Rice", "pantry"}, {"Pasta", "pantry"}, {"Chicken", "meat"}, {"Beef", "meat"},
+	{"Olive Oil", "pantry"}, {"Lemon", "produce"}, {"Basil", "produce"}, {"Oregano", "spice"},
+	{"Thyme", "spice"}, {"Rosemary", "spice"}, {"Parsley", "produce"},
+	{"Potatoes", "produce"}, {"Carrots", "produce"}, {"Bell Pepper", "produce"},
+	{"Mushrooms", "produce"}, {"Spinach", "produce"}, {"Broccoli", "produce"},
 }
 
 func insertDefaultIngredients() {
-	defaultIngredients := []string{
-		"Salt", "Pepper", "Sugar", "Flour", "Butter", "Eggs", "Milk", "Oil",
-		"Onion", "Garlic", "Tomato", "Cheese", "Rice", "Pasta", "Chicken", "Beef",
-		"Olive Oil", "Lemon", "Basil", "Oregano", "Thyme", "Rosemary", "Parsley",
-		"Potatoes", "Carrots", "Bell Pepper", "Mushrooms", "Spinach", "Broccoli",
-	}
-
-	for _, name := range defaultIngredients {
+	for _, ingredient := range defaultIngredients {
 		// Validate each ingredient name before inserting
-		if validation := utils.ValidateIngredientName(name); validation.Valid {
-			DB.Exec("INSERT OR IGNORE INTO ingredients (name) VALUES (?)", name)
+		if validation := utils.ValidateIngredientName(ingredient.Name); validation.Valid {
+			DB.Exec("INSERT OR IGNORE INTO ingredients (name, category) VALUES (?, ?)", ingredient.Name, ingredient.Category)
 		}
 	}
 }
@@ -349,7 +521,7 @@ func insertDefaultRecipes() {
 	err := DB.QueryRow("SELECT id FROM users WHERE username = 'admin'").Scan(&userID)
 	if err != nil {
 		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
-		result, err := DB.Exec("INSERT INTO users (username, email, password) VALUES (?, ?, ?)",
+		result, err := DB.Exec("INSERT INTO users (username, email, password, is_admin, email_verified) VALUES (?, ?, ?, 1, 1)",
 			"admin", "admin@recipebook.com", string(hashedPassword))
 		if err != nil {
 			log.Printf("Could not create admin user: %v", err)
@@ -516,10 +688,16 @@ func insertDefaultRecipes() {
 	fmt.Println("🍳 Adding default recipes...")
 
 	for _, recipe := range defaultRecipes {
+		slug, err := uniqueRecipeSlug(DB, recipe.Title, 0)
+		if err != nil {
+			log.Printf("Error choosing a slug for recipe %s: %v", recipe.Title, err)
+			continue
+		}
+
 		result, err := DB.Exec(`
-			INSERT INTO recipes (title, description, instructions, prep_time, cook_time, servings, serving_unit, created_by)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		`, recipe.Title, recipe.Description, recipe.Instructions, recipe.PrepTime, recipe.CookTime, recipe.Servings, recipe.ServingUnit, userID)
+			INSERT INTO recipes (title, slug, description, instructions, prep_time, cook_time, servings, serving_unit, created_by, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, recipe.Title, slug, recipe.Description, recipe.Instructions, recipe.PrepTime, recipe.CookTime, recipe.Servings, recipe.ServingUnit, userID)
 
 		if err != nil {
 			log.Printf("Error inserting recipe %s: %v", recipe.Title, err)
@@ -529,7 +707,7 @@ func insertDefaultRecipes() {
 		recipeID, _ := result.LastInsertId()
 
 		// Add ingredients
-		for _, ingredient := range recipe.Ingredients {
+		for position, ingredient := range recipe.Ingredients {
 			var ingredientID int
 			err := DB.QueryRow("SELECT id FROM ingredients WHERE name = ?", ingredient.Name).Scan(&ingredientID)
 			if err != nil {
@@ -537,8 +715,8 @@ func insertDefaultRecipes() {
 				continue
 			}
 
-			_, err = DB.Exec("INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity, unit) VALUES (?, ?, ?, ?)",
-				recipeID, ingredientID, ingredient.Quantity, ingredient.Unit)
+			_, err = DB.Exec("INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity, unit, position) VALUES (?, ?, ?, ?, ?)",
+				recipeID, ingredientID, ingredient.Quantity, ingredient.Unit, position)
 			if err != nil {
 				log.Printf("Error inserting ingredient %s for recipe %s: %v", ingredient.Name, recipe.Title, err)
 			}
@@ -565,19 +743,24 @@ func insertDefaultRecipes() {
 	fmt.Println("🎉 Default recipes loaded successfully!")
 }
 
-// Secure user creation with prepared statements
-func CreateUserSecure(username, email, hashedPassword string) error {
+// Secure user creation with prepared statements. Returns the new user's ID.
+func CreateUserSecure(username, email, hashedPassword string) (int, error) {
 	// Validate inputs
 	if validation := utils.ValidateUsername(username); !validation.Valid {
-		return fmt.Errorf("invalid username: %s", validation.Message)
+		return 0, fmt.Errorf("invalid username: %s", validation.Message)
 	}
 
 	if validation := utils.ValidateEmail(email); !validation.Valid {
-		return fmt.Errorf("invalid email: %s", validation.Message)
+		return 0, fmt.Errorf("invalid email: %s", validation.Message)
 	}
 
-	_, err := stmtCreateUser.Exec(username, email, hashedPassword)
-	return err
+	result, err := stmtCreateUser.Exec(username, email, hashedPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
 }
 
 // Secure user lookup with prepared statements
@@ -590,7 +773,7 @@ func GetUserByUsernameSecure(username string) (*models.User, string, error) {
 	var user models.User
 	var hashedPassword string
 
-	err := stmtGetUser.QueryRow(username).Scan(&user.ID, &user.Username, &user.Email, &hashedPassword)
+	err := stmtGetUser.QueryRow(username).Scan(&user.ID, &user.Username, &user.Email, &hashedPassword, &user.TokenVersion)
 	if err != nil {
 		return nil, "", err
 	}
@@ -598,258 +781,1011 @@ func GetUserByUsernameSecure(username string) (*models.User, string, error) {
 	return &user, hashedPassword, nil
 }
 
-// Secure recipe creation
-func CreateRecipeSecure(title, description, instructions string, prepTime, cookTime, servings int, servingUnit string, userID int) (int64, error) {
-	// Validate all inputs
+// validateRecipeFields validates the scalar recipe columns before they are written.
+// Drafts may leave the instructions empty.
+func validateRecipeFields(title, description, instructions string, prepTime, cookTime, servings int, servingUnit, cuisine string, draft bool) error {
 	if validation := utils.ValidateRecipeTitle(title); !validation.Valid {
-		return 0, fmt.Errorf("invalid title: %s", validation.Message)
+		return fmt.Errorf("invalid title: %s", validation.Message)
 	}
 
 	if validation := utils.ValidateRecipeDescription(description); !validation.Valid {
-		return 0, fmt.Errorf("invalid description: %s", validation.Message)
+		return fmt.Errorf("invalid description: %s", validation.Message)
 	}
 
-	if validation := utils.ValidateRecipeInstructions(instructions); !validation.Valid {
-		return 0, fmt.Errorf("invalid instructions: %s", validation.Message)
+	// Drafts may be saved without instructions; PublishRecipe checks them again
+	if !draft || strings.TrimSpace(instructions) != "" {
+		if validation := utils.ValidateRecipeInstructions(instructions); !validation.Valid {
+			return fmt.Errorf("invalid instructions: %s", validation.Message)
+		}
 	}
 
 	if validation := utils.ValidateServingUnit(servingUnit); !validation.Valid {
-		return 0, fmt.Errorf("invalid serving unit: %s", validation.Message)
+		return fmt.Errorf("invalid serving unit: %s", validation.Message)
+	}
+
+	if validation := utils.ValidateCuisine(cuisine); !validation.Valid {
+		return fmt.Errorf("invalid cuisine: %s", validation.Message)
 	}
 
 	// Validate numeric inputs
 	if validation := utils.ValidateNumericInput(prepTime, 0, 1440, "Prep time"); !validation.Valid {
-		return 0, fmt.Errorf("invalid prep time: %s", validation.Message)
+		return fmt.Errorf("invalid prep time: %s", validation.Message)
 	}
 
 	if validation := utils.ValidateNumericInput(cookTime, 0, 1440, "Cook time"); !validation.Valid {
-		return 0, fmt.Errorf("invalid cook time: %s", validation.Message)
+		return fmt.Errorf("invalid cook time: %s", validation.Message)
 	}
 
 	if validation := utils.ValidateNumericInput(servings, 1, 100, "Servings"); !validation.Valid {
-		return 0, fmt.Errorf("invalid servings: %s", validation.Message)
+		return fmt.Errorf("invalid servings: %s", validation.Message)
 	}
 
-	result, err := stmtCreateRecipe.Exec(title, description, instructions, prepTime, cookTime, servings, servingUnit, userID)
-	if err != nil {
-		return 0, err
-	}
+	return nil
+}
 
-	return result.LastInsertId()
+// CreateRecipeWithRelations inserts a recipe together with its ingredients and tags
+// in a single transaction. Nothing is written unless every insert succeeds.
+// Recipes without a status are published.
+func CreateRecipeWithRelations(recipe *models.Recipe, tagIDs []int) (int64, error) {
+	recipeID, _, err := CreateRecipeOnce(recipe, tagIDs, "")
+	return recipeID, err
 }
 
-// Database query functions
-func GetAllRecipes() ([]models.Recipe, error) {
-	rows, err := DB.Query(`
-		SELECT r.id, r.title, r.description, r.instructions, r.prep_time, r.cook_time, 
-		       r.servings, COALESCE(r.serving_unit, 'people'), r.created_by, r.created_at, u.username
-		FROM recipes r
-		JOIN users u ON r.created_by = u.id
-		ORDER BY r.created_at DESC
-	`)
+// CreateRecipeOnce is CreateRecipeWithRelations for requests a client may retry. When the
+// recipe's creator already created a recipe with idempotencyKey within IdempotencyKeyTTL,
+// that recipe's ID is returned with created false and nothing is written, even when the
+// requests run concurrently. An empty key always creates the recipe.
+func CreateRecipeOnce(recipe *models.Recipe, tagIDs []int, idempotencyKey string) (int64, bool, error) {
+	if recipe.Status == "" {
+		recipe.Status = models.RecipeStatusPublished
+	}
+	if err := validateRecipe(recipe); err != nil {
+		return 0, false, err
+	}
+
+	tx, err := DB.Begin()
 	if err != nil {
-		return nil, err
+		return 0, false, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var recipes []models.Recipe
-	for rows.Next() {
-		var recipe models.Recipe
-		err := rows.Scan(&recipe.ID, &recipe.Title, &recipe.Description, &recipe.Instructions,
-			&recipe.PrepTime, &recipe.CookTime, &recipe.Servings, &recipe.ServingUnit, &recipe.CreatedBy,
-			&recipe.CreatedAt, &recipe.AuthorName)
+	if idempotencyKey != "" {
+		existingID, err := recipeForIdempotencyKey(tx, recipe.CreatedBy, idempotencyKey)
 		if err != nil {
-			continue
+			return 0, false, err
+		}
+		if existingID != 0 {
+			return existingID, false, nil
 		}
-
-		recipe.Ingredients = GetRecipeIngredients(recipe.ID)
-		recipe.Images = GetRecipeImages(recipe.ID)
-		recipe.Tags = GetRecipeTags(recipe.ID)
-		recipes = append(recipes, recipe)
 	}
 
-	return recipes, nil
-}
-
-func GetRecipeByID(id int) (*models.Recipe, error) {
-	var recipe models.Recipe
-	err := DB.QueryRow(`
-		SELECT r.id, r.title, r.description, r.instructions, r.prep_time, r.cook_time, 
-		       r.servings, COALESCE(r.serving_unit, 'people'), r.created_by, r.created_at, u.username
-		FROM recipes r
-		JOIN users u ON r.created_by = u.id
-		WHERE r.id = ?
-	`, id).Scan(&recipe.ID, &recipe.Title, &recipe.Description, &recipe.Instructions,
-		&recipe.PrepTime, &recipe.CookTime, &recipe.Servings, &recipe.ServingUnit, &recipe.CreatedBy,
-		&recipe.CreatedAt, &recipe.AuthorName)
-
+	recipe.Slug, err = uniqueRecipeSlug(tx, recipe.Title, 0)
 	if err != nil {
-		return nil, err
+		return 0, false, err
 	}
 
-	recipe.Ingredients = GetRecipeIngredients(recipe.ID)
-	recipe.Images = GetRecipeImages(recipe.ID)
-	recipe.Tags = GetRecipeTags(recipe.ID)
-	return &recipe, nil
-}
-
-// Secure recipe search
-func SearchRecipes(query string) ([]models.Recipe, error) {
-	// Validate search query
-	if validation := utils.ValidateSearchQuery(query); !validation.Valid {
-		return nil, fmt.Errorf("invalid search query: %s", validation.Message)
+	result, err := tx.Stmt(stmtCreateRecipe).Exec(recipe.Title, recipe.Slug, recipe.Description, recipe.Instructions,
+		recipe.PrepTime, recipe.CookTime, recipe.Servings, recipe.ServingUnit, recipe.YieldQuantity, recipe.YieldUnit, recipe.Cuisine,
+		recipe.SourceURL, recipe.VideoURL, recipe.IsPublic, recipe.Status, recipe.CreatedBy)
+	if err != nil {
+		return 0, false, err
 	}
 
-	searchPattern := "%" + query + "%"
-	rows, err := stmtSearchRecipes.Query(searchPattern, searchPattern, searchPattern, searchPattern, searchPattern, searchPattern)
+	recipeID, err := result.LastInsertId()
 	if err != nil {
-		return nil, err
+		return 0, false, err
 	}
-	defer rows.Close()
-
-	var recipes []models.Recipe
-	seenRecipes := make(map[int]bool)
 
-	for rows.Next() {
-		var recipe models.Recipe
-		err := rows.Scan(&recipe.ID, &recipe.Title, &recipe.Description, &recipe.Instructions,
-			&recipe.PrepTime, &recipe.CookTime, &recipe.Servings, &recipe.ServingUnit, &recipe.CreatedBy,
-			&recipe.CreatedAt, &recipe.AuthorName)
-		if err != nil {
-			continue
-		}
+	if err := insertRecipeRelations(tx, recipeID, recipe.Ingredients, tagIDs); err != nil {
+		return 0, false, err
+	}
 
-		if seenRecipes[recipe.ID] {
-			continue
+	if idempotencyKey != "" {
+		if _, err := tx.Exec("INSERT INTO idempotency_keys (user_id, key, recipe_id) VALUES (?, ?, ?)",
+			recipe.CreatedBy, idempotencyKey, recipeID); err != nil {
+			return 0, false, err
 		}
-
-		recipe.Ingredients = GetRecipeIngredients(recipe.ID)
-		recipe.Images = GetRecipeImages(recipe.ID)
-		recipe.Tags = GetRecipeTags(recipe.ID)
-		recipes = append(recipes, recipe)
-		seenRecipes[recipe.ID] = true
 	}
 
-	return recipes, nil
-}
-
-// Secure ingredient creation
-func CreateIngredientSecure(name string) error {
-	// Validate ingredient name
-	if validation := utils.ValidateIngredientName(name); !validation.Valid {
-		return fmt.Errorf("invalid ingredient name: %s", validation.Message)
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
 	}
 
-	_, err := stmtCreateIngredient.Exec(name)
-	return err
+	return recipeID, true, nil
 }
 
-// Secure tag creation
-func CreateTagSecure(name, color string) error {
-	// Validate tag name
-	if validation := utils.ValidateTagName(name); !validation.Valid {
-		return fmt.Errorf("invalid tag name: %s", validation.Message)
+// UpdateRecipeWithRelations replaces a recipe's fields, ingredients and tags in a single
+// transaction, leaving the recipe as it was unless every write succeeds. The recipe is
+// identified by its ID and creator; its status is only used to validate it as a draft.
+// A retitled recipe gets a new slug. Returns ErrRecipeNotFound if the creator has no
+// such recipe.
+func UpdateRecipeWithRelations(recipe *models.Recipe, tagIDs []int) error {
+	if !utils.IsValidID(recipe.ID) {
+		return fmt.Errorf("invalid recipe ID")
 	}
-
-	// Basic color validation
-	if color == "" || len(color) != 7 || !strings.HasPrefix(color, "#") {
-		color = "#ff6b6b"
+	if err := validateRecipe(recipe); err != nil {
+		return err
 	}
 
-	_, err := stmtCreateTag.Exec(name, color)
-	return err
-}
-
-// Secure recipe deletion (with ownership check)
-func DeleteRecipeSecure(recipeID, userID int) error {
-	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
-		return fmt.Errorf("invalid recipe or user ID")
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	result, err := stmtDeleteRecipe.Exec(recipeID, userID)
+	// Taking the write lock first keeps the slug chosen below free until commit
+	result, err := tx.Exec("UPDATE recipes SET updated_at = CURRENT_TIMESTAMP WHERE id = ? AND created_by = ?",
+		recipe.ID, recipe.CreatedBy)
 	if err != nil {
 		return err
 	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return ErrRecipeNotFound
+	}
 
-	rowsAffected, err := result.RowsAffected()
+	recipe.Slug, err = recipeSlugForUpdate(tx, recipe.ID, recipe.Title)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("recipe not found or access denied")
+	if _, err := tx.Exec(`
+		UPDATE recipes SET title = ?, slug = ?, description = ?, instructions = ?,
+		prep_time = ?, cook_time = ?, servings = ?, serving_unit = ?, yield_quantity = ?, yield_unit = ?, cuisine = ?,
+		source_url = ?, video_url = ?, is_public = ?
+		WHERE id = ?
+	`, recipe.Title, recipe.Slug, recipe.Description, recipe.Instructions, recipe.PrepTime, recipe.CookTime, recipe.Servings,
+		recipe.ServingUnit, recipe.YieldQuantity, recipe.YieldUnit, recipe.Cuisine, recipe.SourceURL, recipe.VideoURL,
+		recipe.IsPublic, recipe.ID); err != nil {
+		return err
 	}
 
-	return nil
+	if _, err := tx.Exec("DELETE FROM recipe_tags WHERE recipe_id = ?", recipe.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recipe_ingredients WHERE recipe_id = ?", recipe.ID); err != nil {
+		return err
+	}
+	if err := insertRecipeRelations(tx, int64(recipe.ID), recipe.Ingredients, tagIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// Secure ingredient deletion (with usage check)
-func DeleteIngredientSecure(ingredientID int) error {
-	if !utils.IsValidID(ingredientID) {
-		return fmt.Errorf("invalid ingredient ID")
+// validateRecipe validates a recipe about to be written, normalizing its video URL
+func validateRecipe(recipe *models.Recipe) error {
+	if !IsValidRecipeStatus(recipe.Status) {
+		return fmt.Errorf("invalid status")
 	}
 
-	// Check if ingredient is used in any recipes
-	var recipeCount int
-	err := DB.QueryRow("SELECT COUNT(*) FROM recipe_ingredients WHERE ingredient_id = ?", ingredientID).Scan(&recipeCount)
-	if err != nil {
+	if err := validateRecipeFields(recipe.Title, recipe.Description, recipe.Instructions,
+		recipe.PrepTime, recipe.CookTime, recipe.Servings, recipe.ServingUnit, recipe.Cuisine,
+		recipe.Status == models.RecipeStatusDraft); err != nil {
 		return err
 	}
 
-	if recipeCount > 0 {
-		return fmt.Errorf("ingredient is used in %d recipe(s) and cannot be deleted", recipeCount)
+	if validation := utils.ValidateYield(recipe.YieldQuantity, recipe.YieldUnit); !validation.Valid {
+		return fmt.Errorf("invalid yield: %s", validation.Message)
 	}
 
-	_, err = stmtDeleteIngredient.Exec(ingredientID)
-	return err
-}
-
-// Get recipe by ID with ownership validation
-func GetRecipeByIDSecure(id int) (*models.Recipe, error) {
-	if !utils.IsValidID(id) {
-		return nil, fmt.Errorf("invalid recipe ID")
+	if validation := utils.ValidateURL(recipe.SourceURL); !validation.Valid {
+		return fmt.Errorf("invalid source URL: %s", validation.Message)
 	}
 
-	var recipe models.Recipe
-	err := stmtGetRecipeByID.QueryRow(id).Scan(&recipe.ID, &recipe.Title, &recipe.Description,
-		&recipe.Instructions, &recipe.PrepTime, &recipe.CookTime, &recipe.Servings, &recipe.ServingUnit,
-		&recipe.CreatedBy, &recipe.CreatedAt, &recipe.AuthorName)
+	if recipe.VideoURL != "" {
+		videoURL, ok := utils.NormalizeVideoURL(recipe.VideoURL)
+		if !ok {
+			return fmt.Errorf("invalid video URL")
+		}
+		recipe.VideoURL = videoURL
+	}
 
-	if err != nil {
-		return nil, err
+	if !utils.IsValidID(recipe.CreatedBy) {
+		return fmt.Errorf("invalid user ID")
 	}
 
-	recipe.Ingredients = GetRecipeIngredients(recipe.ID)
-	recipe.Images = GetRecipeImages(recipe.ID)
-	recipe.Tags = GetRecipeTags(recipe.ID)
-	return &recipe, nil
+	return nil
 }
 
-// Check if user owns recipe
-func UserOwnsRecipe(recipeID, userID int) (bool, error) {
-	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
-		return false, fmt.Errorf("invalid recipe or user ID")
+// insertRecipeRelations adds a recipe's ingredients, in order, and its tags within tx
+func insertRecipeRelations(tx *sql.Tx, recipeID int64, ingredients []models.RecipeIngredient, tagIDs []int) error {
+	for _, tagID := range tagIDs {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO recipe_tags (recipe_id, tag_id) VALUES (?, ?)", recipeID, tagID); err != nil {
+			return fmt.Errorf("error adding tag %d: %w", tagID, err)
+		}
 	}
 
-	var createdBy int
-	err := DB.QueryRow("SELECT created_by FROM recipes WHERE id = ?", recipeID).Scan(&createdBy)
-	if err != nil {
+	for position, ingredient := range ingredients {
+		if _, err := tx.Exec("INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity, unit, position, preparation, optional) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			recipeID, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit, position, ingredient.Preparation, ingredient.Optional); err != nil {
+			return fmt.Errorf("error adding ingredient %d: %w", ingredient.IngredientID, err)
+		}
+	}
+
+	return nil
+}
+
+// Columns selected for a recipe joined with its author (aliases r and u)
+const recipeColumns = `r.id, r.title, COALESCE(r.slug, ''), r.description, r.instructions, r.prep_time, r.cook_time,
+		       r.servings, COALESCE(r.serving_unit, 'people'), r.yield_quantity, r.yield_unit, COALESCE(r.cuisine, ''), r.source_url, r.video_url, r.view_count, COALESCE(r.is_public, 1),
+		       COALESCE(r.status, 'published'), r.created_by, r.created_at, r.updated_at, u.username`
+
+// publishedAndPublic matches recipes (alias r) that anyone may see
+const publishedAndPublic = `(COALESCE(r.is_public, 1) = 1 AND COALESCE(r.status, 'published') = 'published')`
+
+// visibleToViewer restricts recipes (alias r) to public, published ones plus the viewer's own.
+// It takes the viewer's user ID as a parameter; anonymous viewers pass 0.
+const visibleToViewer = `(` + publishedAndPublic + ` OR r.created_by = ?)`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRecipe scans a row selected with recipeColumns into a recipe. Columns selected
+// after recipeColumns are scanned into extra.
+func scanRecipe(row rowScanner, recipe *models.Recipe, extra ...interface{}) error {
+	dest := []interface{}{&recipe.ID, &recipe.Title, &recipe.Slug, &recipe.Description, &recipe.Instructions,
+		&recipe.PrepTime, &recipe.CookTime, &recipe.Servings, &recipe.ServingUnit, &recipe.YieldQuantity, &recipe.YieldUnit, &recipe.Cuisine,
+		&recipe.SourceURL, &recipe.VideoURL, &recipe.ViewCount, &recipe.IsPublic, &recipe.Status, &recipe.CreatedBy, &recipe.CreatedAt, &recipe.UpdatedAt, &recipe.AuthorName}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	recipe.TotalTime = recipe.PrepTime + recipe.CookTime
+	return nil
+}
+
+// Database query functions
+
+// CountRecipes returns the number of recipes visible to the viewer
+func CountRecipes(viewerID int) (int, error) {
+	var total int
+	err := DB.QueryRow("SELECT COUNT(*) FROM recipes r WHERE r.deleted_at IS NULL AND "+visibleToViewer, viewerID).Scan(&total)
+	return total, err
+}
+
+// DefaultRecipeSort is used when no or an unknown sort key is given
+const DefaultRecipeSort = "newest"
+
+// recipeSortOrders maps the accepted sort keys to fixed ORDER BY clauses.
+// Only these clauses are ever put into SQL, never the raw key.
+var recipeSortOrders = map[string]string{
+	"newest":     "r.created_at DESC, r.id DESC",
+	"oldest":     "r.created_at ASC, r.id ASC",
+	"title":      "r.title COLLATE NOCASE ASC, r.id ASC",
+	"prep_time":  "r.prep_time ASC, r.id ASC",
+	"total_time": "(r.prep_time + r.cook_time) ASC, r.id ASC",
+	"updated":    "COALESCE(r.updated_at, r.created_at) DESC, r.id DESC",
+	"popular":    "r.view_count DESC, r.id DESC",
+}
+
+// RecipeSortKeys lists the accepted sort keys, for error messages
+var RecipeSortKeys = []string{"newest", "oldest", "title", "prep_time", "total_time", "updated", "popular"}
+
+// IsValidRecipeSort reports whether sortKey is one of RecipeSortKeys
+func IsValidRecipeSort(sortKey string) bool {
+	_, ok := recipeSortOrders[sortKey]
+	return ok
+}
+
+func GetRecipeByID(id int) (*models.Recipe, error) {
+	var recipe models.Recipe
+	row := DB.QueryRow(`
+		SELECT `+recipeColumns+`
+		FROM recipes r
+		JOIN users u ON r.created_by = u.id
+		WHERE r.id = ? AND r.deleted_at IS NULL
+	`, id)
+	err := scanRecipe(row, &recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	recipe.Ingredients = GetRecipeIngredients(recipe.ID)
+	recipe.SetImages(GetRecipeImages(recipe.ID))
+	recipe.Tags = GetRecipeTags(recipe.ID)
+	return &recipe, nil
+}
+
+// Secure ingredient creation
+func CreateIngredientSecure(name, category string) error {
+	// Validate ingredient name
+	if validation := utils.ValidateIngredientName(name); !validation.Valid {
+		return fmt.Errorf("invalid ingredient name: %s", validation.Message)
+	}
+
+	if validation := utils.ValidateIngredientCategory(category); !validation.Valid {
+		return fmt.Errorf("invalid category: %s", validation.Message)
+	}
+	if category == "" {
+		category = utils.DefaultIngredientCategory
+	}
+
+	_, err := stmtCreateIngredient.Exec(name, category)
+	return err
+}
+
+// CreateIngredientsBulk inserts the named ingredients in a single transaction, filed under
+// the default category. Names must already be validated. It returns the names that were
+// created and those that already existed.
+func CreateIngredientsBulk(names []string) (created, duplicates []string, err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO ingredients (name, category) VALUES (?, ?)")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stmt.Close()
+
+	created = make([]string, 0, len(names))
+	duplicates = make([]string, 0)
+	for _, name := range names {
+		result, err := stmt.Exec(name, utils.DefaultIngredientCategory)
+		if err != nil {
+			return nil, nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, nil, err
+		}
+		if affected == 0 {
+			duplicates = append(duplicates, name)
+		} else {
+			created = append(created, name)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return created, duplicates, nil
+}
+
+// GetIngredientByID looks up an ingredient, returning ErrIngredientNotFound if it does not exist
+func GetIngredientByID(id int) (*models.Ingredient, error) {
+	var ingredient models.Ingredient
+	err := DB.QueryRow("SELECT id, name, category FROM ingredients WHERE id = ?", id).
+		Scan(&ingredient.ID, &ingredient.Name, &ingredient.Category)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrIngredientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ingredient, nil
+}
+
+// GetRecipesUsingIngredient returns a page of the viewer's visible recipes that use an
+// ingredient, sorted by title, along with how many there are in total
+func GetRecipesUsingIngredient(ingredientID, viewerID, limit, offset int) ([]models.RecipeSummary, int, error) {
+	var total int
+	err := DB.QueryRow(`
+		SELECT COUNT(*)
+		FROM recipes r
+		JOIN recipe_ingredients ri ON r.id = ri.recipe_id
+		WHERE ri.ingredient_id = ? AND r.deleted_at IS NULL AND `+visibleToViewer,
+		ingredientID, viewerID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The cover image is picked the same way as Recipe.SetImages
+	rows, err := DB.Query(`
+		SELECT r.id, r.title,
+		       i.id, COALESCE(i.filename, ''), COALESCE(i.caption, ''), COALESCE(i.display_order, 0), COALESCE(i.is_primary, 0)
+		FROM recipes r
+		JOIN recipe_ingredients ri ON r.id = ri.recipe_id
+		LEFT JOIN recipe_images i ON i.id = (
+			SELECT id FROM recipe_images
+			WHERE recipe_id = r.id
+			ORDER BY is_primary DESC, display_order ASC, id ASC
+			LIMIT 1
+		)
+		WHERE ri.ingredient_id = ? AND r.deleted_at IS NULL AND `+visibleToViewer+`
+		ORDER BY r.title COLLATE NOCASE, r.id
+		LIMIT ? OFFSET ?
+	`, ingredientID, viewerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	recipes := []models.RecipeSummary{}
+	for rows.Next() {
+		var recipe models.RecipeSummary
+		var imageID sql.NullInt64
+		var image models.RecipeImage
+		if err := rows.Scan(&recipe.ID, &recipe.Title,
+			&imageID, &image.Filename, &image.Caption, &image.Order, &image.IsPrimary); err != nil {
+			continue
+		}
+
+		if imageID.Valid {
+			image.ID = int(imageID.Int64)
+			image.RecipeID = recipe.ID
+			recipe.CoverImage = &image
+		}
+
+		recipes = append(recipes, recipe)
+	}
+
+	return recipes, total, nil
+}
+
+// GetIngredientByName looks up an ingredient by name, ignoring case
+func GetIngredientByName(name string) (*models.Ingredient, error) {
+	var ingredient models.Ingredient
+	err := DB.QueryRow("SELECT id, name, category FROM ingredients WHERE name = ? COLLATE NOCASE", strings.TrimSpace(name)).
+		Scan(&ingredient.ID, &ingredient.Name, &ingredient.Category)
+	if err != nil {
+		return nil, err
+	}
+	return &ingredient, nil
+}
+
+// Secure tag creation
+func CreateTagSecure(name, color string) error {
+	// Validate tag name
+	if validation := utils.ValidateTagName(name); !validation.Valid {
+		return fmt.Errorf("invalid tag name: %s", validation.Message)
+	}
+
+	// Basic color validation
+	if color == "" || len(color) != 7 || !strings.HasPrefix(color, "#") {
+		color = "#ff6b6b"
+	}
+
+	_, err := stmtCreateTag.Exec(name, color)
+	return err
+}
+
+// ErrTagNotFound is returned when a tag does not exist
+var ErrTagNotFound = errors.New("tag not found")
+
+// ErrTagNameTaken is returned when a tag is renamed to the name of another tag
+var ErrTagNameTaken = errors.New("tag name already taken")
+
+// UpdateTag renames and recolors a tag in place, so its recipes keep it
+func UpdateTag(id int, name, color string) error {
+	if validation := utils.ValidateTagName(name); !validation.Valid {
+		return fmt.Errorf("invalid tag name: %s", validation.Message)
+	}
+
+	result, err := stmtUpdateTag.Exec(name, color, id)
+	if err != nil {
+		if IsUniqueViolation(err) {
+			return ErrTagNameTaken
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// MergeTags moves every recipe tagged with sourceID over to targetID and deletes the source
+// tag. Recipes that already carry both tags just lose the source one. It returns how many
+// recipes were moved to the target tag.
+func MergeTags(sourceID, targetID int) (int, error) {
+	if !utils.IsValidID(sourceID) || !utils.IsValidID(targetID) || sourceID == targetID {
+		return 0, fmt.Errorf("invalid tag IDs")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tags WHERE id IN (?, ?)", sourceID, targetID).Scan(&found); err != nil {
+		return 0, err
+	}
+	if found != 2 {
+		return 0, ErrTagNotFound
+	}
+
+	// OR IGNORE leaves rows that would duplicate an existing (recipe_id, target) pair in place
+	result, err := tx.Exec("UPDATE OR IGNORE recipe_tags SET tag_id = ? WHERE tag_id = ?", targetID, sourceID)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// Deleting the source cascades to the rows OR IGNORE left in place
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", sourceID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(moved), nil
+}
+
+// CountTagRecipes returns how many recipes outside the trash carry a tag, matching GetAllTags
+func CountTagRecipes(tagID int) (int, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*)
+		FROM recipe_tags rt
+		JOIN recipes r ON r.id = rt.recipe_id
+		WHERE rt.tag_id = ? AND r.deleted_at IS NULL
+	`, tagID).Scan(&count)
+	return count, err
+}
+
+// GetTagUsage returns how many recipes carry a tag, including ones in the trash,
+// and the titles of up to limit of them
+func GetTagUsage(tagID, limit int) (int, []string, error) {
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM recipe_tags WHERE tag_id = ?", tagID).Scan(&count); err != nil {
+		return 0, nil, err
+	}
+	if count == 0 {
+		return 0, nil, nil
+	}
+
+	rows, err := DB.Query(`
+		SELECT r.title
+		FROM recipes r
+		JOIN recipe_tags rt ON r.id = rt.recipe_id
+		WHERE rt.tag_id = ?
+		ORDER BY r.title
+		LIMIT ?
+	`, tagID, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var titles []string
+	for rows.Next() {
+		var title string
+		if rows.Scan(&title) == nil {
+			titles = append(titles, title)
+		}
+	}
+	return count, titles, rows.Err()
+}
+
+// DeleteTag deletes a tag, which removes it from every recipe through ON DELETE CASCADE
+func DeleteTag(tagID int) error {
+	if !utils.IsValidID(tagID) {
+		return fmt.Errorf("invalid tag ID")
+	}
+
+	result, err := stmtDeleteTag.Exec(tagID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// IsUniqueViolation reports whether err comes from a UNIQUE constraint, such as inserting
+// a username, ingredient or tag name that is already taken. Other errors are genuine
+// database failures.
+func IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// Secure recipe deletion (with ownership check)
+func DeleteRecipeSecure(recipeID, userID int) error {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid recipe or user ID")
+	}
+
+	result, err := stmtDeleteRecipe.Exec(recipeID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("recipe not found or access denied")
+	}
+
+	return nil
+}
+
+// Outcomes of deleting one recipe in a bulk delete
+const (
+	BulkDeleteDeleted   = "deleted"
+	BulkDeleteForbidden = "forbidden"
+	BulkDeleteNotFound  = "not_found"
+)
+
+// BulkDeleteResult reports what happened to one recipe in a bulk delete
+type BulkDeleteResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+// DeleteRecipesBulk moves the user's recipes among recipeIDs to the recycle bin in a single
+// transaction. Recipes owned by someone else are left alone and reported as forbidden.
+func DeleteRecipesBulk(recipeIDs []int, userID int) ([]BulkDeleteResult, error) {
+	if !utils.IsValidID(userID) {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]BulkDeleteResult, 0, len(recipeIDs))
+	for _, id := range recipeIDs {
+		result := BulkDeleteResult{ID: id, Status: BulkDeleteNotFound}
+		if !utils.IsValidID(id) {
+			results = append(results, result)
+			continue
+		}
+
+		var createdBy int
+		err := tx.QueryRow("SELECT created_by FROM recipes WHERE id = ? AND deleted_at IS NULL", id).Scan(&createdBy)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return nil, err
+		case createdBy != userID:
+			result.Status = BulkDeleteForbidden
+		default:
+			if _, err := tx.Exec("UPDATE recipes SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?", id); err != nil {
+				return nil, err
+			}
+			result.Status = BulkDeleteDeleted
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Secure ingredient deletion (with usage check)
+func DeleteIngredientSecure(ingredientID int) error {
+	if !utils.IsValidID(ingredientID) {
+		return fmt.Errorf("invalid ingredient ID")
+	}
+
+	// Check if ingredient is used in any recipes
+	var recipeCount int
+	err := DB.QueryRow("SELECT COUNT(*) FROM recipe_ingredients WHERE ingredient_id = ?", ingredientID).Scan(&recipeCount)
+	if err != nil {
+		return err
+	}
+
+	if recipeCount > 0 {
+		return fmt.Errorf("ingredient is used in %d recipe(s) and cannot be deleted", recipeCount)
+	}
+
+	_, err = stmtDeleteIngredient.Exec(ingredientID)
+	return err
+}
+
+// ErrIngredientNotFound is returned when an ingredient does not exist
+var ErrIngredientNotFound = errors.New("ingredient not found")
+
+// maxIngredientQuantity matches the recipe_ingredients quantity CHECK constraint
+const maxIngredientQuantity = 10000
+
+// MergeIngredients moves every recipe using sourceID over to targetID and deletes the source
+// ingredient. When a recipe already uses both, the source quantity is added to the target's,
+// converted to the target's unit where needed; amounts in units that cannot be converted are
+// dropped in favour of the target's. It returns how many recipes were affected.
+func MergeIngredients(sourceID, targetID int) (int, error) {
+	if !utils.IsValidID(sourceID) || !utils.IsValidID(targetID) || sourceID == targetID {
+		return 0, fmt.Errorf("invalid ingredient IDs")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM ingredients WHERE id IN (?, ?)", sourceID, targetID).Scan(&found); err != nil {
+		return 0, err
+	}
+	if found != 2 {
+		return 0, ErrIngredientNotFound
+	}
+
+	// Recipes that already use the target keep one row with the quantities combined
+	rows, err := tx.Query(`
+		SELECT s.recipe_id, s.quantity, s.unit, t.quantity, t.unit
+		FROM recipe_ingredients s
+		JOIN recipe_ingredients t ON t.recipe_id = s.recipe_id AND t.ingredient_id = ?
+		WHERE s.ingredient_id = ?
+	`, targetID, sourceID)
+	if err != nil {
+		return 0, err
+	}
+
+	type combinedRow struct {
+		recipeID int
+		quantity float64
+	}
+	var combined []combinedRow
+	for rows.Next() {
+		var recipeID int
+		var sourceQuantity, targetQuantity float64
+		var sourceUnit, targetUnit string
+		if err := rows.Scan(&recipeID, &sourceQuantity, &sourceUnit, &targetQuantity, &targetUnit); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		quantity := targetQuantity
+		if strings.EqualFold(sourceUnit, targetUnit) {
+			quantity += sourceQuantity
+		} else if converted, err := utils.ConvertUnit(sourceQuantity, sourceUnit, targetUnit); err == nil {
+			quantity += converted
+		}
+		combined = append(combined, combinedRow{recipeID, math.Min(quantity, maxIngredientQuantity)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, row := range combined {
+		if _, err := tx.Exec("UPDATE recipe_ingredients SET quantity = ? WHERE recipe_id = ? AND ingredient_id = ?",
+			row.quantity, row.recipeID, targetID); err != nil {
+			return 0, err
+		}
+	}
+
+	// OR IGNORE skips the recipes combined above; deleting the source cascades to their rows
+	result, err := tx.Exec("UPDATE OR IGNORE recipe_ingredients SET ingredient_id = ? WHERE ingredient_id = ?", targetID, sourceID)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM ingredients WHERE id = ?", sourceID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(moved) + len(combined), nil
+}
+
+// Get recipe by ID. Private recipes are only returned to their owner; other
+// viewers (0 for anonymous) get ErrRecipeNotFound.
+func GetRecipeByIDSecure(id, viewerID int) (*models.Recipe, error) {
+	if !utils.IsValidID(id) {
+		return nil, fmt.Errorf("invalid recipe ID")
+	}
+
+	var recipe models.Recipe
+	err := scanRecipe(stmtGetRecipeByID.QueryRow(id), &recipe)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if (!recipe.IsPublic || recipe.Status == models.RecipeStatusDraft) && recipe.CreatedBy != viewerID {
+		return nil, ErrRecipeNotFound
+	}
+
+	recipe.Ingredients = GetRecipeIngredients(recipe.ID)
+	recipe.SetImages(GetRecipeImages(recipe.ID))
+	recipe.Tags = GetRecipeTags(recipe.ID)
+	return &recipe, nil
+}
+
+// GetRecipesByIDs returns the recipes with the given IDs that the viewer (0 for anonymous)
+// may see, in the order of ids. IDs that are invalid, unknown, in the trash or hidden from
+// the viewer are left out, as are repeats.
+func GetRecipesByIDs(ids []int, viewerID int) ([]models.Recipe, error) {
+	var args []interface{}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if utils.IsValidID(id) && !seen[id] {
+			seen[id] = true
+			args = append(args, id)
+		}
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	rows, err := DB.Query(`
+		SELECT `+recipeColumns+`
+		FROM recipes r
+		JOIN users u ON r.created_by = u.id
+		WHERE r.id IN (`+placeholders+`) AND r.deleted_at IS NULL AND `+visibleToViewer,
+		append(args, viewerID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[int]models.Recipe, len(args))
+	for rows.Next() {
+		var recipe models.Recipe
+		if err := scanRecipe(rows, &recipe); err != nil {
+			continue
+		}
+		found[recipe.ID] = recipe
+	}
+
+	recipes := make([]models.Recipe, 0, len(found))
+	for _, id := range args {
+		if recipe, ok := found[id.(int)]; ok {
+			recipes = append(recipes, recipe)
+		}
+	}
+
+	attachRecipeRelations(recipes)
+	return recipes, nil
+}
+
+// Check if user owns recipe
+func UserOwnsRecipe(recipeID, userID int) (bool, error) {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
+		return false, fmt.Errorf("invalid recipe or user ID")
+	}
+
+	var createdBy int
+	err := DB.QueryRow("SELECT created_by FROM recipes WHERE id = ? AND deleted_at IS NULL", recipeID).Scan(&createdBy)
+	if err != nil {
 		return false, err
 	}
 
 	return createdBy == userID, nil
 }
 
-func GetRecipesByTag(tagID int) ([]models.Recipe, error) {
+// SetRecipeVisibility makes one of the user's recipes public or private
+func SetRecipeVisibility(recipeID, userID int, isPublic bool) error {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid recipe or user ID")
+	}
+
+	result, err := DB.Exec("UPDATE recipes SET is_public = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND created_by = ? AND deleted_at IS NULL",
+		isPublic, recipeID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecipeNotFound
+	}
+
+	return nil
+}
+
+// ErrRecipeAlreadyPublished is returned when publishing a recipe that is not a draft
+var ErrRecipeAlreadyPublished = errors.New("recipe is already published")
+
+// ErrRecipeIncomplete is returned when a draft does not pass validation for publishing
+var ErrRecipeIncomplete = errors.New("recipe is not ready to publish")
+
+// IsValidRecipeStatus reports whether status is draft or published
+func IsValidRecipeStatus(status string) bool {
+	return status == models.RecipeStatusDraft || status == models.RecipeStatusPublished
+}
+
+// PublishRecipe runs the full recipe validation on one of the user's drafts and publishes it.
+// Validation failures wrap ErrRecipeIncomplete.
+func PublishRecipe(recipeID, userID int) error {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid recipe or user ID")
+	}
+
+	var recipe models.Recipe
+	err := scanRecipe(stmtGetRecipeByID.QueryRow(recipeID), &recipe)
+	if err == sql.ErrNoRows || (err == nil && recipe.CreatedBy != userID) {
+		return ErrRecipeNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if recipe.Status != models.RecipeStatusDraft {
+		return ErrRecipeAlreadyPublished
+	}
+
+	if err := validateRecipeFields(recipe.Title, recipe.Description, recipe.Instructions,
+		recipe.PrepTime, recipe.CookTime, recipe.Servings, recipe.ServingUnit, recipe.Cuisine, false); err != nil {
+		return fmt.Errorf("%w: %v", ErrRecipeIncomplete, err)
+	}
+
+	result, err := DB.Exec("UPDATE recipes SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND created_by = ? AND status = ? AND deleted_at IS NULL",
+		models.RecipeStatusPublished, recipeID, userID, models.RecipeStatusDraft)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecipeAlreadyPublished
+	}
+
+	return nil
+}
+
+// FindRecipesByAvailableIngredients returns the viewer's visible recipes using at least one
+// of the given ingredients, best matches first. Each match lists the ingredients still missing.
+func FindRecipesByAvailableIngredients(ingredientIDs []int, viewerID int) ([]models.RecipeMatch, error) {
+	available := make(map[int]bool, len(ingredientIDs))
+	var args []interface{}
+	for _, ingredientID := range ingredientIDs {
+		if !utils.IsValidID(ingredientID) {
+			return nil, fmt.Errorf("invalid ingredient ID")
+		}
+		if !available[ingredientID] {
+			available[ingredientID] = true
+			args = append(args, ingredientID)
+		}
+	}
+
+	if len(args) == 0 {
+		return []models.RecipeMatch{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	args = append(args, viewerID)
+
 	rows, err := DB.Query(`
-		SELECT DISTINCT r.id, r.title, r.description, r.instructions, r.prep_time, r.cook_time, 
-		       r.servings, COALESCE(r.serving_unit, 'people'), r.created_by, r.created_at, u.username
+		SELECT `+recipeColumns+`,
+		       SUM(CASE WHEN ri.ingredient_id IN (`+placeholders+`) THEN 1 ELSE 0 END) AS matched,
+		       COUNT(ri.ingredient_id) AS required
 		FROM recipes r
 		JOIN users u ON r.created_by = u.id
-		JOIN recipe_tags rt ON r.id = rt.recipe_id
-		WHERE rt.tag_id = ?
-		ORDER BY r.created_at DESC
-	`, tagID)
+		JOIN recipe_ingredients ri ON r.id = ri.recipe_id
+		WHERE r.deleted_at IS NULL AND `+visibleToViewer+`
+		GROUP BY r.id
+		HAVING matched > 0
+		ORDER BY matched * 1.0 / required DESC, matched DESC, r.created_at DESC
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -858,24 +1794,45 @@ func GetRecipesByTag(tagID int) ([]models.Recipe, error) {
 	var recipes []models.Recipe
 	for rows.Next() {
 		var recipe models.Recipe
-		err := rows.Scan(&recipe.ID, &recipe.Title, &recipe.Description, &recipe.Instructions,
-			&recipe.PrepTime, &recipe.CookTime, &recipe.Servings, &recipe.ServingUnit, &recipe.CreatedBy,
-			&recipe.CreatedAt, &recipe.AuthorName)
-		if err != nil {
+		var matched, required int
+		if err := scanRecipe(rows, &recipe, &matched, &required); err != nil {
 			continue
 		}
 
-		recipe.Ingredients = GetRecipeIngredients(recipe.ID)
-		recipe.Images = GetRecipeImages(recipe.ID)
-		recipe.Tags = GetRecipeTags(recipe.ID)
 		recipes = append(recipes, recipe)
 	}
 
-	return recipes, nil
+	attachRecipeRelations(recipes)
+
+	matches := make([]models.RecipeMatch, 0, len(recipes))
+	for _, recipe := range recipes {
+		missing := []models.RecipeIngredient{}
+		for _, ingredient := range recipe.Ingredients {
+			if !available[ingredient.IngredientID] {
+				missing = append(missing, ingredient)
+			}
+		}
+
+		percentage := 0.0
+		if len(recipe.Ingredients) > 0 {
+			have := len(recipe.Ingredients) - len(missing)
+			percentage = utils.ScaleQuantity(float64(have)*100/float64(len(recipe.Ingredients)), 1)
+		}
+
+		matches = append(matches, models.RecipeMatch{
+			Recipe:             recipe,
+			MatchPercentage:    percentage,
+			MissingIngredients: missing,
+		})
+	}
+
+	return matches, nil
 }
 
-func GetAllIngredients() ([]models.Ingredient, error) {
-	rows, err := DB.Query("SELECT id, name FROM ingredients ORDER BY name")
+// GetAllIngredients returns the ingredients ordered by name, only those in the given
+// category unless it is empty
+func GetAllIngredients(category string) ([]models.Ingredient, error) {
+	rows, err := DB.Query("SELECT id, name, category FROM ingredients WHERE ? = '' OR category = ? ORDER BY name", category, category)
 	if err != nil {
 		return nil, err
 	}
@@ -884,7 +1841,7 @@ func GetAllIngredients() ([]models.Ingredient, error) {
 	var ingredients []models.Ingredient
 	for rows.Next() {
 		var ingredient models.Ingredient
-		err := rows.Scan(&ingredient.ID, &ingredient.Name)
+		err := rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.Category)
 		if err != nil {
 			continue
 		}
@@ -894,17 +1851,69 @@ func GetAllIngredients() ([]models.Ingredient, error) {
 	return ingredients, nil
 }
 
-func GetAllTags() ([]models.Tag, error) {
-	rows, err := DB.Query("SELECT id, name, color FROM tags ORDER BY name")
+// GetIngredientUsageCounts returns a page of the ingredients with how many recipes use
+// each, most used first and unused ones last, along with the number of ingredients.
+// Recipes in the trash count, as an ingredient they use cannot be deleted.
+func GetIngredientUsageCounts(limit, offset int) ([]models.IngredientUsage, int, error) {
+	if limit < 1 || offset < 0 {
+		return nil, 0, fmt.Errorf("invalid limit or offset")
+	}
+
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM ingredients").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT i.id, i.name, i.category, COUNT(DISTINCT ri.recipe_id) AS recipe_count
+		FROM ingredients i
+		LEFT JOIN recipe_ingredients ri ON ri.ingredient_id = i.id
+		GROUP BY i.id
+		ORDER BY recipe_count DESC, i.name
+		LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var ingredients []models.IngredientUsage
+	for rows.Next() {
+		var ingredient models.IngredientUsage
+		err := rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.Category, &ingredient.RecipeCount)
+		if err != nil {
+			continue
+		}
+		ingredients = append(ingredients, ingredient)
+	}
+
+	return ingredients, total, nil
+}
+
+// GetAllTags returns every tag with how many recipes outside the trash use it,
+// ordered by name or, when sortByCount is set, most used first
+func GetAllTags(sortByCount bool) ([]models.TagUsage, error) {
+	orderBy := "t.name"
+	if sortByCount {
+		orderBy = "recipe_count DESC, t.name"
+	}
+
+	rows, err := DB.Query(`
+		SELECT t.id, t.name, t.color, COUNT(r.id) AS recipe_count
+		FROM tags t
+		LEFT JOIN recipe_tags rt ON rt.tag_id = t.id
+		LEFT JOIN recipes r ON r.id = rt.recipe_id AND r.deleted_at IS NULL
+		GROUP BY t.id
+		ORDER BY ` + orderBy)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tags []models.Tag
+	var tags []models.TagUsage
 	for rows.Next() {
-		var tag models.Tag
-		err := rows.Scan(&tag.ID, &tag.Name, &tag.Color)
+		var tag models.TagUsage
+		err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.RecipeCount)
 		if err != nil {
 			continue
 		}
@@ -916,11 +1925,11 @@ func GetAllTags() ([]models.Tag, error) {
 
 func GetRecipeIngredients(recipeID int) []models.RecipeIngredient {
 	rows, err := DB.Query(`
-		SELECT ri.ingredient_id, i.name, ri.unit, ri.quantity
+		SELECT ri.ingredient_id, i.name, ri.unit, ri.quantity, ri.preparation, ri.optional
 		FROM recipe_ingredients ri
 		JOIN ingredients i ON ri.ingredient_id = i.id
 		WHERE ri.recipe_id = ?
-		ORDER BY i.name
+		ORDER BY ri.position, i.name
 	`, recipeID)
 
 	if err != nil {
@@ -931,7 +1940,7 @@ func GetRecipeIngredients(recipeID int) []models.RecipeIngredient {
 	var ingredients []models.RecipeIngredient
 	for rows.Next() {
 		var ing models.RecipeIngredient
-		err := rows.Scan(&ing.IngredientID, &ing.Name, &ing.Unit, &ing.Quantity)
+		err := rows.Scan(&ing.IngredientID, &ing.Name, &ing.Unit, &ing.Quantity, &ing.Preparation, &ing.Optional)
 		if err != nil {
 			continue
 		}
@@ -968,12 +1977,120 @@ func GetRecipeTags(recipeID int) []models.Tag {
 	return tags
 }
 
+// recipeIDPlaceholders builds the "?, ?, ..." list and arguments for an IN clause
+func recipeIDPlaceholders(recipes []models.Recipe) (string, []interface{}) {
+	args := make([]interface{}, len(recipes))
+	for i, recipe := range recipes {
+		args[i] = recipe.ID
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(recipes)), ", "), args
+}
+
+// attachRecipeRelations loads ingredients, images and tags for a list of recipes
+// using one batched query per relation instead of three queries per recipe
+func attachRecipeRelations(recipes []models.Recipe) {
+	if len(recipes) == 0 {
+		return
+	}
+
+	placeholders, args := recipeIDPlaceholders(recipes)
+	ingredients := getIngredientsForRecipes(placeholders, args)
+	images := getImagesForRecipes(placeholders, args)
+	tags := getTagsForRecipes(placeholders, args)
+
+	for i := range recipes {
+		id := recipes[i].ID
+		recipes[i].Ingredients = ingredients[id]
+		recipes[i].SetImages(images[id])
+		recipes[i].Tags = tags[id]
+	}
+}
+
+func getIngredientsForRecipes(placeholders string, args []interface{}) map[int][]models.RecipeIngredient {
+	result := make(map[int][]models.RecipeIngredient)
+
+	rows, err := DB.Query(`
+		SELECT ri.recipe_id, ri.ingredient_id, i.name, ri.unit, ri.quantity, ri.preparation, ri.optional
+		FROM recipe_ingredients ri
+		JOIN ingredients i ON ri.ingredient_id = i.id
+		WHERE ri.recipe_id IN (`+placeholders+`)
+		ORDER BY ri.recipe_id, ri.position, i.name
+	`, args...)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipeID int
+		var ing models.RecipeIngredient
+		if err := rows.Scan(&recipeID, &ing.IngredientID, &ing.Name, &ing.Unit, &ing.Quantity, &ing.Preparation, &ing.Optional); err != nil {
+			continue
+		}
+		result[recipeID] = append(result[recipeID], ing)
+	}
+
+	return result
+}
+
+func getImagesForRecipes(placeholders string, args []interface{}) map[int][]models.RecipeImage {
+	result := make(map[int][]models.RecipeImage)
+
+	rows, err := DB.Query(`
+		SELECT id, recipe_id, filename, caption, display_order, COALESCE(is_primary, 0)
+		FROM recipe_images
+		WHERE recipe_id IN (`+placeholders+`)
+		ORDER BY recipe_id, is_primary DESC, display_order ASC, id ASC
+	`, args...)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var img models.RecipeImage
+		if err := rows.Scan(&img.ID, &img.RecipeID, &img.Filename, &img.Caption, &img.Order, &img.IsPrimary); err != nil {
+			continue
+		}
+		result[img.RecipeID] = append(result[img.RecipeID], img)
+	}
+
+	return result
+}
+
+func getTagsForRecipes(placeholders string, args []interface{}) map[int][]models.Tag {
+	result := make(map[int][]models.Tag)
+
+	rows, err := DB.Query(`
+		SELECT rt.recipe_id, t.id, t.name, t.color
+		FROM recipe_tags rt
+		JOIN tags t ON rt.tag_id = t.id
+		WHERE rt.recipe_id IN (`+placeholders+`)
+		ORDER BY rt.recipe_id, t.name
+	`, args...)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipeID int
+		var tag models.Tag
+		if err := rows.Scan(&recipeID, &tag.ID, &tag.Name, &tag.Color); err != nil {
+			continue
+		}
+		result[recipeID] = append(result[recipeID], tag)
+	}
+
+	return result
+}
+
 func GetRecipeImages(recipeID int) []models.RecipeImage {
 	rows, err := DB.Query(`
-		SELECT id, recipe_id, filename, caption, display_order
+		SELECT id, recipe_id, filename, caption, display_order, COALESCE(is_primary, 0)
 		FROM recipe_images
 		WHERE recipe_id = ?
-		ORDER BY display_order ASC, id ASC
+		ORDER BY is_primary DESC, display_order ASC, id ASC
 	`, recipeID)
 
 	if err != nil {
@@ -984,7 +2101,7 @@ func GetRecipeImages(recipeID int) []models.RecipeImage {
 	var images []models.RecipeImage
 	for rows.Next() {
 		var img models.RecipeImage
-		err := rows.Scan(&img.ID, &img.RecipeID, &img.Filename, &img.Caption, &img.Order)
+		err := rows.Scan(&img.ID, &img.RecipeID, &img.Filename, &img.Caption, &img.Order, &img.IsPrimary)
 		if err != nil {
 			continue
 		}
@@ -994,10 +2111,178 @@ func GetRecipeImages(recipeID int) []models.RecipeImage {
 	return images
 }
 
+// TouchRecipe records that a recipe's images or other related data changed
+func TouchRecipe(recipeID int) error {
+	_, err := DB.Exec("UPDATE recipes SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", recipeID)
+	return err
+}
+
+// CountRecipeImages returns how many images a recipe has
+func CountRecipeImages(recipeID int) (int, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM recipe_images WHERE recipe_id = ?", recipeID).Scan(&count)
+	return count, err
+}
+
+// AddRecipeImages attaches uploaded images to a recipe until it has limit images, and
+// returns the images that were added with their IDs set. The images that did not fit are
+// left out; their files are the caller's to remove. The count and the inserts happen in
+// one transaction, so concurrent uploads cannot take a recipe over the limit.
+func AddRecipeImages(recipeID, limit int, images []models.RecipeImage) ([]models.RecipeImage, error) {
+	if !utils.IsValidID(recipeID) {
+		return nil, fmt.Errorf("invalid recipe ID")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the database's write lock before the images are counted
+	if _, err := tx.Exec("UPDATE recipes SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", recipeID); err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM recipe_images WHERE recipe_id = ?", recipeID).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	var added []models.RecipeImage
+	for _, img := range images {
+		if count+len(added) >= limit {
+			break
+		}
+
+		result, err := tx.Exec("INSERT INTO recipe_images (recipe_id, filename, caption, display_order) VALUES (?, ?, ?, ?)",
+			recipeID, img.Filename, img.Caption, img.Order)
+		if err != nil {
+			return nil, err
+		}
+		imageID, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		img.ID = int(imageID)
+		img.RecipeID = recipeID
+		added = append(added, img)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// ErrImageOrderMismatch is returned when a reorder request does not list exactly the recipe's images
+var ErrImageOrderMismatch = errors.New("image IDs do not match the recipe's images")
+
+// ReorderRecipeImages sets display_order for a recipe's images to their index in imageIDs.
+// imageIDs must contain every image attached to the recipe exactly once.
+func ReorderRecipeImages(recipeID int, imageIDs []int) error {
+	if !utils.IsValidID(recipeID) {
+		return fmt.Errorf("invalid recipe ID")
+	}
+
+	current := GetRecipeImages(recipeID)
+	if len(current) != len(imageIDs) {
+		return ErrImageOrderMismatch
+	}
+
+	attached := make(map[int]bool, len(current))
+	for _, img := range current {
+		attached[img.ID] = true
+	}
+
+	seen := make(map[int]bool, len(imageIDs))
+	for _, id := range imageIDs {
+		if !attached[id] || seen[id] {
+			return ErrImageOrderMismatch
+		}
+		seen[id] = true
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for index, id := range imageIDs {
+		if _, err := tx.Exec("UPDATE recipe_images SET display_order = ? WHERE id = ? AND recipe_id = ?", index, id, recipeID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SetPrimaryImage marks an image as its recipe's cover, clearing the flag on the recipe's other images
+func SetPrimaryImage(recipeID, imageID int) error {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(imageID) {
+		return fmt.Errorf("invalid recipe or image ID")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE recipe_images SET is_primary = 0 WHERE recipe_id = ?", recipeID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE recipe_images SET is_primary = 1 WHERE id = ? AND recipe_id = ?", imageID, recipeID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return fmt.Errorf("image not found")
+	}
+
+	return tx.Commit()
+}
+
+// UpdateImageCaption changes an image's caption and returns the updated image
+func UpdateImageCaption(imageID int, caption string) (*models.RecipeImage, error) {
+	if !utils.IsValidID(imageID) {
+		return nil, fmt.Errorf("invalid image ID")
+	}
+
+	result, err := DB.Exec("UPDATE recipe_images SET caption = ? WHERE id = ?", caption, imageID)
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rowsAffected == 0 {
+		return nil, fmt.Errorf("image not found")
+	}
+
+	var img models.RecipeImage
+	err = DB.QueryRow(`
+		SELECT id, recipe_id, filename, COALESCE(caption, ''), display_order, COALESCE(is_primary, 0)
+		FROM recipe_images
+		WHERE id = ?
+	`, imageID).Scan(&img.ID, &img.RecipeID, &img.Filename, &img.Caption, &img.Order, &img.IsPrimary)
+	if err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
 func GetTagByID(id int) (*models.Tag, error) {
 	var tag models.Tag
 	err := DB.QueryRow("SELECT id, name, color FROM tags WHERE id = ?", id).
 		Scan(&tag.ID, &tag.Name, &tag.Color)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTagNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,507 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// queryCountingDriverName is the sqlite driver wrapped so that tests can count queries
+const queryCountingDriverName = "sqlite-query-counting"
+
+var (
+	queriesRun          atomic.Int64
+	registerQueryDriver sync.Once
+)
+
+// queryCountingDriver counts every query sent through its connections
+type queryCountingDriver struct {
+	driver.Driver
+}
+
+func (d queryCountingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return queryCountingConn{conn}, nil
+}
+
+type queryCountingConn struct {
+	driver.Conn
+}
+
+func (c queryCountingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queriesRun.Add(1)
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c queryCountingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c queryCountingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+// setupTestDB initializes a fresh, seeded database in a temporary directory, which also
+// becomes the working directory so uploads stay out of the source tree
+func setupTestDB(tb testing.TB) {
+	tb.Helper()
+
+	registerQueryDriver.Do(func() {
+		sql.Register(queryCountingDriverName, queryCountingDriver{&sqlite.Driver{}})
+	})
+	previousDriver := driverName
+	driverName = queryCountingDriverName
+
+	dir := tb.TempDir()
+	tb.Chdir(dir)
+	tb.Setenv("DB_PATH", filepath.Join(dir, "recipes.db"))
+	InitDB()
+
+	tb.Cleanup(func() {
+		DB.Close()
+		driverName = previousDriver
+	})
+}
+
+// createTestRecipes adds count published recipes by the seeded admin, each with two
+// ingredients and a tag, and returns them with only their IDs set
+func createTestRecipes(tb testing.TB, count int) []models.Recipe {
+	tb.Helper()
+
+	recipes := make([]models.Recipe, count)
+	for i := range recipes {
+		recipe := models.Recipe{
+			Title:        fmt.Sprintf("Test recipe %d", i),
+			Instructions: "Mix and bake",
+			Servings:     4,
+			ServingUnit:  "people",
+			CreatedBy:    1,
+			IsPublic:     true,
+			Ingredients: []models.RecipeIngredient{
+				{IngredientID: 1, Quantity: 1, Unit: "tsp"},
+				{IngredientID: 5, Quantity: 100, Unit: "g"},
+			},
+		}
+		id, err := CreateRecipeWithRelations(&recipe, []int{1})
+		if err != nil {
+			tb.Fatalf("creating recipe %d: %v", i, err)
+		}
+		recipes[i] = models.Recipe{ID: int(id)}
+	}
+	return recipes
+}
+
+// BenchmarkRecipeRelations compares loading the relations of a recipe list with one
+// batched query per relation against three queries per recipe
+func BenchmarkRecipeRelations(b *testing.B) {
+	setupTestDB(b)
+	recipes := createTestRecipes(b, 200)
+
+	b.Run("batched", func(b *testing.B) {
+		queriesRun.Store(0)
+		for b.Loop() {
+			attachRecipeRelations(recipes)
+		}
+		b.ReportMetric(float64(queriesRun.Load())/float64(b.N), "queries/op")
+	})
+
+	b.Run("per-recipe", func(b *testing.B) {
+		queriesRun.Store(0)
+		for b.Loop() {
+			for i := range recipes {
+				recipes[i].Ingredients = GetRecipeIngredients(recipes[i].ID)
+				recipes[i].SetImages(GetRecipeImages(recipes[i].ID))
+				recipes[i].Tags = GetRecipeTags(recipes[i].ID)
+			}
+		}
+		b.ReportMetric(float64(queriesRun.Load())/float64(b.N), "queries/op")
+	})
+}
+
+func TestAttachRecipeRelationsUsesOneQueryPerRelation(t *testing.T) {
+	setupTestDB(t)
+	recipes := createTestRecipes(t, 20)
+
+	queriesRun.Store(0)
+	attachRecipeRelations(recipes)
+
+	if got := queriesRun.Load(); got != 3 {
+		t.Errorf("attachRecipeRelations ran %d queries for %d recipes, want 3", got, len(recipes))
+	}
+	for _, recipe := range recipes {
+		if len(recipe.Ingredients) != 2 || len(recipe.Tags) != 1 {
+			t.Errorf("recipe %d got %d ingredients and %d tags, want 2 and 1",
+				recipe.ID, len(recipe.Ingredients), len(recipe.Tags))
+		}
+	}
+}
+
+func TestAddRecipeImagesStopsAtLimit(t *testing.T) {
+	setupTestDB(t)
+	recipeID := createTestRecipes(t, 1)[0].ID
+
+	images := []models.RecipeImage{{Filename: "a.png"}, {Filename: "b.png"}, {Filename: "c.png"}}
+	added, err := AddRecipeImages(recipeID, 2, images)
+	if err != nil {
+		t.Fatalf("AddRecipeImages: %v", err)
+	}
+	if len(added) != 2 || added[0].Filename != "a.png" || added[1].Filename != "b.png" {
+		t.Errorf("added %+v, want a.png and b.png", added)
+	}
+
+	// An upload that passed the handler's early check before the first one finished
+	added, err = AddRecipeImages(recipeID, 2, []models.RecipeImage{{Filename: "d.png"}})
+	if err != nil {
+		t.Fatalf("AddRecipeImages: %v", err)
+	}
+	if len(added) != 0 {
+		t.Errorf("added %d images to a full recipe", len(added))
+	}
+}
+
+func TestCreateRecipeInstructionsLimit(t *testing.T) {
+	setupTestDB(t)
+
+	tests := []struct {
+		length int
+		valid  bool
+	}{
+		{utils.MaxRecipeInstructionsLength, true},
+		{utils.MaxRecipeInstructionsLength + 1, false},
+	}
+
+	for _, tt := range tests {
+		// Two bytes per character, so a byte count would be off by half
+		recipe := models.Recipe{
+			Title:        fmt.Sprintf("Crème brûlée %d", tt.length),
+			Instructions: strings.Repeat("é", tt.length),
+			Servings:     4,
+			ServingUnit:  "people",
+			CreatedBy:    1,
+		}
+		id, err := CreateRecipeWithRelations(&recipe, nil)
+
+		if !tt.valid {
+			if err == nil || !strings.HasPrefix(err.Error(), "invalid instructions") {
+				t.Errorf("%d characters: got %v, want the validation error rather than the CHECK constraint", tt.length, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d characters: %v", tt.length, err)
+		}
+
+		var stored int
+		if err := DB.QueryRow("SELECT length(instructions) FROM recipes WHERE id = ?", id).Scan(&stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored != tt.length {
+			t.Errorf("stored %d characters, want %d", stored, tt.length)
+		}
+	}
+}
+
+func TestUpdateRecipeWithRelations(t *testing.T) {
+	setupTestDB(t)
+	recipeID := createTestRecipes(t, 1)[0].ID
+	original, err := GetRecipeByID(recipeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := func(title string, ingredients []models.RecipeIngredient, tagIDs []int, userID int) error {
+		recipe := *original
+		recipe.Title, recipe.Ingredients, recipe.CreatedBy = title, ingredients, userID
+		return UpdateRecipeWithRelations(&recipe, tagIDs)
+	}
+	chopped := []models.RecipeIngredient{{IngredientID: 5, Quantity: 2, Unit: "g", Preparation: "chopped", Optional: true}}
+
+	tests := []struct {
+		name        string
+		title       string
+		ingredients []models.RecipeIngredient
+		tagIDs      []int
+		userID      int
+		wantErr     error // nil for success, errAny for any other error
+	}{
+		{"another user's recipe", "Stolen", chopped, nil, 2, ErrRecipeNotFound},
+		{"unknown ingredient", "Half saved", []models.RecipeIngredient{chopped[0], {IngredientID: 99999, Quantity: 1, Unit: "g"}}, nil, 1, errAny},
+		{"unknown tag", "Half saved", chopped, []int{99999}, 1, errAny},
+		{"invalid title", "", chopped, nil, 1, errAny},
+		{"valid", "Renamed recipe", chopped, nil, 1, nil},
+	}
+
+	for _, tt := range tests {
+		err := update(tt.title, tt.ingredients, tt.tagIDs, tt.userID)
+		switch {
+		case tt.wantErr == nil && err != nil:
+			t.Fatalf("%s: %v", tt.name, err)
+		case tt.wantErr == errAny && err == nil:
+			t.Errorf("%s: update succeeded, want an error", tt.name)
+		case tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr == nil {
+			continue
+		}
+
+		// A failed update leaves the recipe and its relations as they were
+		recipe, err := GetRecipeByID(recipeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recipe.Title != original.Title || recipe.Slug != original.Slug ||
+			len(recipe.Ingredients) != len(original.Ingredients) || len(recipe.Tags) != len(original.Tags) {
+			t.Errorf("%s: recipe changed to %q (%s) with %d ingredients and %d tags, want it unchanged",
+				tt.name, recipe.Title, recipe.Slug, len(recipe.Ingredients), len(recipe.Tags))
+		}
+	}
+
+	recipe, err := GetRecipeByID(recipeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipe.Title != "Renamed recipe" || recipe.Slug != "renamed-recipe" {
+		t.Errorf("recipe is %q with slug %q, want the new title and slug", recipe.Title, recipe.Slug)
+	}
+	if len(recipe.Tags) != 0 {
+		t.Errorf("recipe kept %d tags, want none", len(recipe.Tags))
+	}
+	if len(recipe.Ingredients) != 1 || recipe.Ingredients[0].IngredientID != 5 ||
+		recipe.Ingredients[0].Preparation != "chopped" || !recipe.Ingredients[0].Optional {
+		t.Errorf("ingredients = %+v, want only the chopped, optional ingredient 5", recipe.Ingredients)
+	}
+}
+
+// errAny stands for any error in test tables
+var errAny = errors.New("any error")
+
+func TestForeignKeysOnEveryConnection(t *testing.T) {
+	setupTestDB(t)
+	ctx := context.Background()
+
+	// Holding each connection open makes the pool open a new one for the next
+	for i := 0; i < 4; i++ {
+		conn, err := DB.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		var enabled bool
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&enabled); err != nil {
+			t.Fatal(err)
+		}
+		if !enabled {
+			t.Errorf("foreign keys are off on pooled connection %d", i+1)
+		}
+	}
+}
+
+func TestTagDeletesCascade(t *testing.T) {
+	setupTestDB(t)
+	recipes := createTestRecipes(t, 2)
+
+	// The first recipe already has the target tag, so its source row is left for the cascade
+	if _, err := DB.Exec("INSERT INTO recipe_tags (recipe_id, tag_id) VALUES (?, 2)", recipes[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var wantMoved int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM recipe_tags WHERE tag_id = 1
+		AND recipe_id NOT IN (SELECT recipe_id FROM recipe_tags WHERE tag_id = 2)`).Scan(&wantMoved); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := MergeTags(1, 2)
+	if err != nil {
+		t.Fatalf("MergeTags: %v", err)
+	}
+	if moved != wantMoved {
+		t.Errorf("MergeTags moved %d recipes, want %d", moved, wantMoved)
+	}
+
+	if err := DeleteTag(3); err != nil {
+		t.Fatalf("DeleteTag: %v", err)
+	}
+	if err := DeleteTag(3); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("deleting a deleted tag returned %v, want ErrTagNotFound", err)
+	}
+
+	var orphans int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM recipe_tags WHERE tag_id NOT IN (SELECT id FROM tags)").Scan(&orphans); err != nil {
+		t.Fatal(err)
+	}
+	if orphans != 0 {
+		t.Errorf("%d recipe_tags rows point at deleted tags", orphans)
+	}
+	for _, recipe := range recipes {
+		if tags := GetRecipeTags(recipe.ID); len(tags) != 1 || tags[0].ID != 2 {
+			t.Errorf("recipe %d has tags %+v, want only tag 2", recipe.ID, tags)
+		}
+	}
+}
+
+// recipeChildTables are the tables whose rows belong to a recipe through recipe_id
+var recipeChildTables = []string{"recipe_ingredients", "recipe_images", "recipe_tags", "favorites", "recipe_ratings", "recipe_comments", "recipe_nutrition", "meal_plans", "recipe_views", "idempotency_keys"}
+
+// countRecipeRows counts the rows recipeID still has in each of recipeChildTables
+func countRecipeRows(tb testing.TB, recipeID int) int {
+	tb.Helper()
+
+	total := 0
+	for _, table := range recipeChildTables {
+		var count int
+		if err := DB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE recipe_id = ?", recipeID).Scan(&count); err != nil {
+			tb.Fatalf("counting %s: %v", table, err)
+		}
+		total += count
+	}
+	return total
+}
+
+func TestPurgeCascadesToRelatedRows(t *testing.T) {
+	setupTestDB(t)
+	recipes := createTestRecipes(t, 2)
+
+	for _, recipe := range recipes {
+		if err := AddFavorite(1, recipe.ID); err != nil {
+			t.Fatalf("AddFavorite: %v", err)
+		}
+		if _, err := AddComment(recipe.ID, 1, "Lovely"); err != nil {
+			t.Fatalf("AddComment: %v", err)
+		}
+		if err := RecordRecipeView(1, recipe.ID); err != nil {
+			t.Fatalf("RecordRecipeView: %v", err)
+		}
+	}
+
+	if err := DeleteRecipeSecure(recipes[0].ID, 1); err != nil {
+		t.Fatalf("DeleteRecipeSecure: %v", err)
+	}
+	if _, err := DB.Exec("UPDATE recipes SET deleted_at = datetime('now', '-31 days') WHERE id = ?", recipes[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := PurgeOldDeletedRecipes(TrashRetention)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeOldDeletedRecipes = %d, %v; want 1, nil", purged, err)
+	}
+
+	if left := countRecipeRows(t, recipes[0].ID); left != 0 {
+		t.Errorf("purged recipe left %d related rows", left)
+	}
+	if kept := countRecipeRows(t, recipes[1].ID); kept == 0 {
+		t.Error("purge removed the related rows of a recipe still in use")
+	}
+}
+
+func TestDeleteUserAccountCascades(t *testing.T) {
+	setupTestDB(t)
+	recipes := createTestRecipes(t, 2)
+
+	userID, err := CreateUserSecure("leaving_cook", "leaving@example.com", "not-a-real-hash")
+	if err != nil {
+		t.Fatalf("CreateUserSecure: %v", err)
+	}
+	if _, err := DB.Exec("UPDATE recipes SET created_by = ? WHERE id = ?", userID, recipes[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddFavorite(userID, recipes[1].ID); err != nil {
+		t.Fatalf("AddFavorite: %v", err)
+	}
+	for locked := false; !locked; {
+		if locked, err = RecordFailedLogin("leaving_cook"); err != nil {
+			t.Fatalf("RecordFailedLogin: %v", err)
+		}
+	}
+
+	if err := DeleteUserAccount(userID); err != nil {
+		t.Fatalf("DeleteUserAccount: %v", err)
+	}
+
+	if left := countRecipeRows(t, recipes[0].ID); left != 0 {
+		t.Errorf("deleted user's recipe left %d related rows", left)
+	}
+	var favorites int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM favorites WHERE user_id = ?", userID).Scan(&favorites); err != nil {
+		t.Fatal(err)
+	}
+	if favorites != 0 {
+		t.Errorf("deleted user still has %d favorites", favorites)
+	}
+	if kept := countRecipeRows(t, recipes[1].ID); kept == 0 {
+		t.Error("deleting the user removed the related rows of someone else's recipe")
+	}
+
+	// Whoever registers the name next starts without the old lock
+	if _, err := CreateUserSecure("leaving_cook", "next@example.com", "not-a-real-hash"); err != nil {
+		t.Fatalf("CreateUserSecure: %v", err)
+	}
+	if lockedFor, err := LoginLockedFor("leaving_cook"); err != nil || lockedFor != 0 {
+		t.Errorf("LoginLockedFor = %v, %v; want 0, nil", lockedFor, err)
+	}
+}
+
+func TestCreateRecipeOnceConcurrently(t *testing.T) {
+	setupTestDB(t)
+
+	// Holding the write lock lines every request up before any of them reads the key
+	blocker, err := DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blocker.Exec("UPDATE tags SET name = name WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	const requests = 4
+	ids := make([]int64, requests)
+	created := make([]bool, requests)
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recipe := models.Recipe{Title: "Retried soup", Instructions: "Stir", Servings: 2, ServingUnit: "people", CreatedBy: 1, IsPublic: true}
+			ids[i], created[i], errs[i] = CreateRecipeOnce(&recipe, nil, "retry-key")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := blocker.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	createdCount := 0
+	for i := range requests {
+		if errs[i] != nil {
+			t.Errorf("request %d failed: %v", i, errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Errorf("request %d got recipe %d, want %d like the first", i, ids[i], ids[0])
+		}
+		if created[i] {
+			createdCount++
+		}
+	}
+	if createdCount != 1 {
+		t.Errorf("%d requests created the recipe, want 1", createdCount)
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"recipe-book/utils"
+	"time"
+)
+
+// Errors returned when an email verification token cannot be used
+var (
+	ErrVerificationTokenInvalid = errors.New("invalid email verification token")
+	ErrVerificationTokenUsed    = errors.New("email verification token already used")
+	ErrVerificationTokenExpired = errors.New("email verification token expired")
+)
+
+// CreateEmailVerificationToken stores a verification token for the user, replacing any
+// earlier unused ones. Used tokens are kept so reusing a link gets a clear answer.
+func CreateEmailVerificationToken(userID int, token string, expiresAt time.Time) error {
+	if !utils.IsValidID(userID) || token == "" {
+		return fmt.Errorf("invalid user ID or token")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM email_verification_tokens WHERE user_id = ? AND used_at IS NULL", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO email_verification_tokens (token, user_id, expires_at) VALUES (?, ?, ?)",
+		hashToken(token), userID, expiresAt.UTC().Format(auditTimeLayout)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// VerifyEmail marks the token's user as verified and consumes the token. It returns the
+// user ID, or one of the ErrVerificationToken errors when the token cannot be used.
+func VerifyEmail(token string) (int, error) {
+	if token == "" {
+		return 0, ErrVerificationTokenInvalid
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	hashed := hashToken(token)
+	var userID int
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRow("SELECT user_id, expires_at, used_at FROM email_verification_tokens WHERE token = ?", hashed).
+		Scan(&userID, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrVerificationTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if usedAt.Valid {
+		return 0, ErrVerificationTokenUsed
+	}
+	if time.Now().After(expiresAt) {
+		return 0, ErrVerificationTokenExpired
+	}
+
+	if _, err := tx.Exec("UPDATE users SET email_verified = 1 WHERE id = ?", userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP WHERE token = ?", hashed); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
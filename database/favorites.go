@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// ErrRecipeNotFound is returned when an operation references a recipe that does not exist
+var ErrRecipeNotFound = errors.New("recipe not found")
+
+// recipeVisible reports whether a recipe exists, is not in the recycle bin, and is
+// public or owned by the viewer (0 for anonymous)
+func recipeVisible(recipeID, viewerID int) (bool, error) {
+	var id int
+	err := DB.QueryRow("SELECT r.id FROM recipes r WHERE r.id = ? AND r.deleted_at IS NULL AND "+visibleToViewer,
+		recipeID, viewerID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddFavorite bookmarks a recipe for a user. Favoriting twice is a no-op.
+func AddFavorite(userID, recipeID int) error {
+	if !utils.IsValidID(userID) || !utils.IsValidID(recipeID) {
+		return fmt.Errorf("invalid recipe or user ID")
+	}
+
+	exists, err := recipeVisible(recipeID, userID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRecipeNotFound
+	}
+
+	_, err = DB.Exec("INSERT OR IGNORE INTO favorites (user_id, recipe_id) VALUES (?, ?)", userID, recipeID)
+	return err
+}
+
+// RemoveFavorite removes a bookmark. Removing a missing favorite is a no-op.
+func RemoveFavorite(userID, recipeID int) error {
+	if !utils.IsValidID(userID) || !utils.IsValidID(recipeID) {
+		return fmt.Errorf("invalid recipe or user ID")
+	}
+
+	_, err := DB.Exec("DELETE FROM favorites WHERE user_id = ? AND recipe_id = ?", userID, recipeID)
+	return err
+}
+
+// GetFavoriteRecipes returns a user's bookmarked recipes, most recently favorited first
+func GetFavoriteRecipes(userID int) ([]models.Recipe, error) {
+	if !utils.IsValidID(userID) {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	rows, err := DB.Query(`
+		SELECT `+recipeColumns+`
+		FROM favorites f
+		JOIN recipes r ON f.recipe_id = r.id
+		JOIN users u ON r.created_by = u.id
+		WHERE f.user_id = ? AND r.deleted_at IS NULL AND `+visibleToViewer+`
+		ORDER BY f.created_at DESC, r.id DESC
+	`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipes []models.Recipe
+	for rows.Next() {
+		var recipe models.Recipe
+		if err := scanRecipe(rows, &recipe); err != nil {
+			continue
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	attachRecipeRelations(recipes)
+	return recipes, nil
+}
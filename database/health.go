@@ -0,0 +1,21 @@
+package database
+
+import (
+	"context"
+	"errors"
+)
+
+// HealthCheck verifies that the database is initialized and answering queries
+func HealthCheck(ctx context.Context) error {
+	// InitDB runs in the background, so DB may not be set yet during startup
+	if DB == nil {
+		return errors.New("database not initialized")
+	}
+
+	if err := DB.PingContext(ctx); err != nil {
+		return err
+	}
+
+	var one int
+	return DB.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
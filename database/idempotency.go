@@ -0,0 +1,45 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IdempotencyKeyTTL is how long a client's idempotency key keeps pointing at the recipe
+// it created. Retrying with the key after that creates a new recipe.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyCutoff is the datetime('now', ?) modifier for keys older than the TTL
+func idempotencyCutoff() string {
+	return fmt.Sprintf("-%d seconds", int64(IdempotencyKeyTTL.Seconds()))
+}
+
+// recipeForIdempotencyKey returns the recipe a user created with key within the TTL, or
+// 0 if there is none. Expired entries for the key are removed first, which also makes
+// the transaction a writer so that concurrent retries are serialized.
+func recipeForIdempotencyKey(tx *sql.Tx, userID int, key string) (int64, error) {
+	if _, err := tx.Exec("DELETE FROM idempotency_keys WHERE user_id = ? AND key = ? AND created_at <= datetime('now', ?)",
+		userID, key, idempotencyCutoff()); err != nil {
+		return 0, err
+	}
+
+	var recipeID int64
+	err := tx.QueryRow("SELECT recipe_id FROM idempotency_keys WHERE user_id = ? AND key = ?", userID, key).Scan(&recipeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return recipeID, err
+}
+
+// DeleteExpiredIdempotencyKeys removes the idempotency keys older than IdempotencyKeyTTL
+func DeleteExpiredIdempotencyKeys() (int, error) {
+	result, err := DB.Exec("DELETE FROM idempotency_keys WHERE created_at <= datetime('now', ?)", idempotencyCutoff())
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// LoginLockoutPolicy locks an account once too many logins to it fail, wherever they
+// come from, complementing the per-IP rate limits
+type LoginLockoutPolicy struct {
+	MaxFailures int           // failures within Window that lock the account; 0 disables lockout
+	Window      time.Duration // how far back failures count
+	Duration    time.Duration // how long the account stays locked
+}
+
+var loginLockout = LoginLockoutPolicy{MaxFailures: 5, Window: 15 * time.Minute, Duration: 15 * time.Minute}
+
+// InitLoginLockout loads the lockout policy from LOGIN_LOCKOUT_ATTEMPTS, LOGIN_LOCKOUT_WINDOW
+// and LOGIN_LOCKOUT_DURATION, exiting if any of them is invalid
+func InitLoginLockout() {
+	if value := os.Getenv("LOGIN_LOCKOUT_ATTEMPTS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Fatalf("❌ Invalid LOGIN_LOCKOUT_ATTEMPTS %q: expected a whole number, or 0 to disable lockout", value)
+		}
+		loginLockout.MaxFailures = n
+	}
+
+	for _, setting := range []struct {
+		key      string
+		duration *time.Duration
+	}{
+		{"LOGIN_LOCKOUT_WINDOW", &loginLockout.Window},
+		{"LOGIN_LOCKOUT_DURATION", &loginLockout.Duration},
+	} {
+		if value := os.Getenv(setting.key); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < time.Second {
+				log.Fatalf("❌ Invalid %s %q: expected a duration of at least a second, such as 15m", setting.key, value)
+			}
+			*setting.duration = d
+		}
+	}
+
+	if loginLockout.MaxFailures == 0 {
+		log.Println("⚠️  Account lockout after failed logins is disabled")
+	}
+}
+
+// sqliteOffset is the datetime() modifier for d from now
+func sqliteOffset(d time.Duration) string {
+	return fmt.Sprintf("%+d seconds", int64(d.Seconds()))
+}
+
+// LoginLockedFor returns how long logins to username remain locked, or 0 if they aren't.
+// Usernames with no account are tracked like any other, so a lock reveals nothing.
+func LoginLockedFor(username string) (time.Duration, error) {
+	var seconds int64
+	err := DB.QueryRow(`
+		SELECT CAST(strftime('%s', locked_until) AS INTEGER) - CAST(strftime('%s', 'now') AS INTEGER)
+		FROM login_attempts WHERE username = ? AND locked_until > CURRENT_TIMESTAMP`, username).Scan(&seconds)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// RecordFailedLogin counts a failed login to username, locking it once the policy's limit
+// is reached. It reports whether the account is now locked.
+func RecordFailedLogin(username string) (bool, error) {
+	if loginLockout.MaxFailures == 0 {
+		return false, nil
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Failures older than the window no longer count
+	windowStart := sqliteOffset(-loginLockout.Window)
+	var failures int
+	err = tx.QueryRow(`
+		INSERT INTO login_attempts (username, failures, window_start) VALUES (?1, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT(username) DO UPDATE SET
+			failures = CASE WHEN window_start <= datetime('now', ?2) THEN 1 ELSE failures + 1 END,
+			window_start = CASE WHEN window_start <= datetime('now', ?2) THEN CURRENT_TIMESTAMP ELSE window_start END
+		RETURNING failures`, username, windowStart).Scan(&failures)
+	if err != nil {
+		return false, err
+	}
+
+	locked := failures >= loginLockout.MaxFailures
+	if locked {
+		// The count starts over once the lock expires
+		if _, err := tx.Exec(`
+			UPDATE login_attempts SET failures = 0, window_start = CURRENT_TIMESTAMP, locked_until = datetime('now', ?)
+			WHERE username = ?`, sqliteOffset(loginLockout.Duration), username); err != nil {
+			return false, err
+		}
+	}
+
+	return locked, tx.Commit()
+}
+
+// ClearFailedLogins forgets the failed logins to username, after a successful one
+func ClearFailedLogins(username string) error {
+	_, err := DB.Exec("DELETE FROM login_attempts WHERE username = ?", username)
+	return err
+}
+
+// DeleteStaleLoginAttempts removes failed logins that no longer count towards or hold a lock
+func DeleteStaleLoginAttempts() (int, error) {
+	result, err := DB.Exec(`
+		DELETE FROM login_attempts
+		WHERE window_start <= datetime('now', ?) AND (locked_until IS NULL OR locked_until <= CURRENT_TIMESTAMP)`,
+		sqliteOffset(-loginLockout.Window))
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
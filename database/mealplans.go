@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"time"
+)
+
+// MealPlanDateLayout is the format of meal plan dates
+const MealPlanDateLayout = "2006-01-02"
+
+// ErrMealPlanNotFound is returned when a meal plan entry does not exist
+var ErrMealPlanNotFound = errors.New("meal plan entry not found")
+
+// ErrMealPlanAccessDenied is returned when a user may not change a meal plan entry
+var ErrMealPlanAccessDenied = errors.New("meal plan access denied")
+
+// AddMealPlan schedules a recipe visible to the user for a meal on the given day
+func AddMealPlan(userID, recipeID int, planDate time.Time, mealType string) (int64, error) {
+	if !utils.IsValidID(userID) || !utils.IsValidID(recipeID) {
+		return 0, fmt.Errorf("invalid user or recipe ID")
+	}
+
+	if validation := utils.ValidateMealType(mealType); !validation.Valid {
+		return 0, fmt.Errorf("invalid meal type: %s", validation.Message)
+	}
+
+	exists, err := recipeVisible(recipeID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrRecipeNotFound
+	}
+
+	result, err := DB.Exec("INSERT INTO meal_plans (user_id, recipe_id, plan_date, meal_type) VALUES (?, ?, ?, ?)",
+		userID, recipeID, planDate.Format(MealPlanDateLayout), mealType)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// GetMealPlans returns the user's meal plan entries between from and to (inclusive), ordered by
+// day and then by meal. Entries for recipes that were deleted or are no longer visible are left out.
+func GetMealPlans(userID int, from, to time.Time) ([]models.MealPlanEntry, error) {
+	if !utils.IsValidID(userID) {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	// The cover image is picked the same way as Recipe.SetImages
+	rows, err := DB.Query(`
+		SELECT m.id, m.recipe_id, r.title, strftime('%Y-%m-%d', m.plan_date), m.meal_type,
+		       i.id, COALESCE(i.filename, ''), COALESCE(i.caption, ''), COALESCE(i.display_order, 0), COALESCE(i.is_primary, 0)
+		FROM meal_plans m
+		JOIN recipes r ON m.recipe_id = r.id
+		LEFT JOIN recipe_images i ON i.id = (
+			SELECT id FROM recipe_images
+			WHERE recipe_id = r.id
+			ORDER BY is_primary DESC, display_order ASC, id ASC
+			LIMIT 1
+		)
+		WHERE m.user_id = ? AND m.plan_date BETWEEN ? AND ?
+		      AND r.deleted_at IS NULL AND `+visibleToViewer+`
+		ORDER BY m.plan_date ASC,
+		         CASE m.meal_type WHEN 'breakfast' THEN 1 WHEN 'lunch' THEN 2 WHEN 'dinner' THEN 3 ELSE 4 END,
+		         m.id ASC
+	`, userID, from.Format(MealPlanDateLayout), to.Format(MealPlanDateLayout), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.MealPlanEntry{}
+	for rows.Next() {
+		var entry models.MealPlanEntry
+		var imageID sql.NullInt64
+		var image models.RecipeImage
+		if err := rows.Scan(&entry.ID, &entry.RecipeID, &entry.RecipeTitle, &entry.PlanDate, &entry.MealType,
+			&imageID, &image.Filename, &image.Caption, &image.Order, &image.IsPrimary); err != nil {
+			continue
+		}
+
+		if imageID.Valid {
+			image.ID = int(imageID.Int64)
+			image.RecipeID = entry.RecipeID
+			entry.CoverImage = &image
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// DeleteMealPlan removes one of the user's meal plan entries
+func DeleteMealPlan(entryID, userID int) error {
+	if !utils.IsValidID(entryID) || !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid meal plan or user ID")
+	}
+
+	var ownerID int
+	err := DB.QueryRow("SELECT user_id FROM meal_plans WHERE id = ?", entryID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return ErrMealPlanNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if ownerID != userID {
+		return ErrMealPlanAccessDenied
+	}
+
+	_, err = DB.Exec("DELETE FROM meal_plans WHERE id = ? AND user_id = ?", entryID, userID)
+	return err
+}
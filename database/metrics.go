@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"modernc.org/sqlite"
+)
+
+// countingDriverName is the sqlite driver wrapped so that failed queries are counted
+const countingDriverName = "sqlite-counting"
+
+// driverName is the driver InitDB opens the database with
+var driverName = "sqlite"
+
+var (
+	queryErrors          atomic.Uint64
+	registerCountingOnce sync.Once
+)
+
+// CountQueryErrors makes InitDB open the database through a driver that counts failed
+// queries, see QueryErrors. It must be called before InitDB.
+func CountQueryErrors() {
+	registerCountingOnce.Do(func() {
+		sql.Register(countingDriverName, countingDriver{&sqlite.Driver{}})
+	})
+	driverName = countingDriverName
+}
+
+// QueryErrors returns how many database queries have failed since startup.
+// It stays at zero unless CountQueryErrors was called.
+func QueryErrors() uint64 {
+	return queryErrors.Load()
+}
+
+func countQueryError(err error) {
+	if err == nil || errors.Is(err, driver.ErrSkip) || errors.Is(err, context.Canceled) {
+		return
+	}
+	queryErrors.Add(1)
+}
+
+// countingDriver wraps a driver and counts the errors returned by its connections and statements
+type countingDriver struct {
+	driver.Driver
+}
+
+func (d countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		countQueryError(err)
+		return nil, err
+	}
+	return countingConn{conn}, nil
+}
+
+type countingConn struct {
+	driver.Conn
+}
+
+func (c countingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		countQueryError(err)
+		return nil, err
+	}
+	return countingStmt{stmt}, nil
+}
+
+func (c countingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	var err error
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	countQueryError(err)
+	return tx, err
+}
+
+func (c countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	result, err := execer.ExecContext(ctx, query, args)
+	countQueryError(err)
+	return result, err
+}
+
+func (c countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	countQueryError(err)
+	return rows, err
+}
+
+func (c countingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		err := pinger.Ping(ctx)
+		countQueryError(err)
+		return err
+	}
+	return nil
+}
+
+func (c countingConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c countingConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+type countingStmt struct {
+	driver.Stmt
+}
+
+func (s countingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(namedValuesToValues(args))
+	}
+	countQueryError(err)
+	return result, err
+}
+
+func (s countingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValuesToValues(args))
+	}
+	countQueryError(err)
+	return rows, err
+}
+
+func namedValuesToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
@@ -0,0 +1,315 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// migration is one upgrade of the schema. Each migration runs once, in its own
+// transaction, and is recorded in schema_migrations when it succeeds.
+//
+// Databases created before schema_migrations existed were upgraded by inspecting the
+// schema at every start, so migrations must still check whether their change is
+// already present instead of assuming an old schema.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations are applied in order. Append new ones with the next version number and
+// never change or reorder those that have been released.
+var migrations = []migration{
+	{1, "reset_ingredients_with_unit", resetIngredientsWithUnit},
+	{2, "add_recipe_serving_unit", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "recipes", "serving_unit", "TEXT DEFAULT 'people'")
+		return err
+	}},
+	{3, "add_recipe_soft_delete", func(tx *sql.Tx) error {
+		if _, err := addColumnIfMissing(tx, "recipes", "deleted_at", "DATETIME"); err != nil {
+			return err
+		}
+		_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_recipes_deleted_at ON recipes(deleted_at)")
+		return err
+	}},
+	{4, "add_image_is_primary", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "recipe_images", "is_primary", "BOOLEAN DEFAULT 0")
+		return err
+	}},
+	{5, "add_recipe_cuisine", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "recipes", "cuisine", "TEXT DEFAULT ''")
+		return err
+	}},
+	{6, "add_recipe_is_public", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "recipes", "is_public", "BOOLEAN DEFAULT 1")
+		return err
+	}},
+	{7, "add_recipe_status", addRecipeStatus},
+	{8, "add_recipe_updated_at", addRecipeUpdatedAt},
+	{9, "add_user_admin_flag", addUserAdminFlag},
+	{10, "add_user_email_verified", addUserEmailVerified},
+	{11, "add_recipe_source_url", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "recipes", "source_url", "TEXT NOT NULL DEFAULT '' CHECK(length(source_url) <= 2048)")
+		return err
+	}},
+	{12, "add_recipe_video_url", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "recipes", "video_url", "TEXT NOT NULL DEFAULT '' CHECK(length(video_url) <= 2048)")
+		return err
+	}},
+	{13, "add_recipe_view_count", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "recipes", "view_count", "INTEGER NOT NULL DEFAULT 0")
+		return err
+	}},
+	{14, "add_ingredient_category", addIngredientCategory},
+	{15, "add_recipe_slug", addRecipeSlug},
+	{16, "add_user_token_version", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "users", "token_version", "INTEGER NOT NULL DEFAULT 0")
+		return err
+	}},
+	{17, "add_recipe_ingredient_position", addRecipeIngredientPosition},
+	{18, "add_recipe_ingredient_preparation", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "recipe_ingredients", "preparation", "TEXT NOT NULL DEFAULT '' CHECK(length(preparation) <= 100)")
+		return err
+	}},
+	{19, "add_recipe_ingredient_optional", func(tx *sql.Tx) error {
+		_, err := addColumnIfMissing(tx, "recipe_ingredients", "optional", "BOOLEAN NOT NULL DEFAULT 0")
+		return err
+	}},
+	{20, "add_recipe_yield", func(tx *sql.Tx) error {
+		if _, err := addColumnIfMissing(tx, "recipes", "yield_quantity", "REAL NOT NULL DEFAULT 0 CHECK(yield_quantity >= 0 AND yield_quantity <= 10000)"); err != nil {
+			return err
+		}
+		_, err := addColumnIfMissing(tx, "recipes", "yield_unit", "TEXT NOT NULL DEFAULT '' CHECK(length(yield_unit) <= 20)")
+		return err
+	}},
+}
+
+// RunMigrations applies the migrations that schema_migrations does not list yet.
+// Foreign keys are switched off while migrating so that rebuilding a table does not
+// cascade into the tables referencing it.
+func RunMigrations() error {
+	ctx := context.Background()
+
+	// Pragmas are per connection, so every migration runs on the same one
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return err
+	}
+
+	applied := make(map[int]bool)
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var foreignKeys bool
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	if foreignKeys {
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		log.Printf("✅ Applied migration %d (%s)", m.version, m.name)
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already present,
+// reporting whether it was added
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) (bool, error) {
+	exists, err := columnExists(tx, table, column)
+	if err != nil || exists {
+		return false, err
+	}
+
+	fmt.Printf("🔄 Adding %s column to %s...\n", column, table)
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	return count > 0, err
+}
+
+// resetIngredientsWithUnit drops ingredients from the very first schema, which kept the
+// unit on the ingredient rather than on each recipe's use of it, and recreates the tables
+func resetIngredientsWithUnit(tx *sql.Tx) error {
+	oldSchema, err := columnExists(tx, "ingredients", "unit")
+	if err != nil || !oldSchema {
+		return err
+	}
+
+	fmt.Println("🔄 Resetting ingredients from the old schema...")
+	for _, statement := range []string{
+		"DROP TABLE IF EXISTS recipe_ingredients",
+		"DROP TABLE IF EXISTS ingredients",
+		schema,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRecipeStatus adds the status column to recipes. SQLite cannot change the existing
+// instructions CHECK constraint, so the table is rebuilt instead of altered.
+func addRecipeStatus(tx *sql.Tx) error {
+	exists, err := columnExists(tx, "recipes", "status")
+	if err != nil || exists {
+		return err
+	}
+
+	fmt.Println("🔄 Adding status column to recipes...")
+
+	const columns = `id, title, description, instructions, prep_time, cook_time, servings,
+		serving_unit, cuisine, is_public, created_by, created_at, deleted_at`
+
+	statements := []string{
+		fmt.Sprintf(recipesTableSchema, "recipes_new"),
+		"INSERT INTO recipes_new (" + columns + ", status, updated_at) SELECT " + columns + ", 'published', created_at FROM recipes",
+		// Keep AUTOINCREMENT from handing out IDs of recipes that were purged
+		`UPDATE sqlite_sequence SET seq = (SELECT seq FROM sqlite_sequence WHERE name = 'recipes')
+		 WHERE name = 'recipes_new' AND EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = 'recipes')`,
+		"DROP TABLE recipes",
+		"ALTER TABLE recipes_new RENAME TO recipes",
+		"CREATE INDEX IF NOT EXISTS idx_recipes_created_by ON recipes(created_by)",
+		"CREATE INDEX IF NOT EXISTS idx_recipes_title ON recipes(title)",
+		"CREATE INDEX IF NOT EXISTS idx_recipes_deleted_at ON recipes(deleted_at)",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRecipeUpdatedAt adds the updated_at column to recipes, starting it at created_at.
+// Columns added by ALTER TABLE cannot default to CURRENT_TIMESTAMP, so inserts set it explicitly.
+func addRecipeUpdatedAt(tx *sql.Tx) error {
+	if _, err := addColumnIfMissing(tx, "recipes", "updated_at", "DATETIME"); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE recipes SET updated_at = created_at WHERE updated_at IS NULL")
+	return err
+}
+
+// addUserAdminFlag adds the is_admin column to users. The seeded admin account
+// becomes the first administrator.
+func addUserAdminFlag(tx *sql.Tx) error {
+	added, err := addColumnIfMissing(tx, "users", "is_admin", "BOOLEAN DEFAULT 0")
+	if err != nil || !added {
+		return err
+	}
+	_, err = tx.Exec("UPDATE users SET is_admin = 1 WHERE username = 'admin'")
+	return err
+}
+
+// addUserEmailVerified adds the email_verified column to users. Accounts created before
+// email verification existed are treated as verified.
+func addUserEmailVerified(tx *sql.Tx) error {
+	added, err := addColumnIfMissing(tx, "users", "email_verified", "BOOLEAN DEFAULT 0")
+	if err != nil || !added {
+		return err
+	}
+	_, err = tx.Exec("UPDATE users SET email_verified = 1")
+	return err
+}
+
+// addIngredientCategory adds the category column to ingredients and files the default
+// ingredients under their categories. Everything else starts out as "other".
+func addIngredientCategory(tx *sql.Tx) error {
+	added, err := addColumnIfMissing(tx, "ingredients", "category", "TEXT NOT NULL DEFAULT 'other' CHECK(length(category) <= 20)")
+	if err != nil || !added {
+		return err
+	}
+
+	for _, ingredient := range defaultIngredients {
+		if _, err := tx.Exec("UPDATE ingredients SET category = ? WHERE name = ?", ingredient.Category, ingredient.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRecipeIngredientPosition adds the position column to recipe_ingredients. The order
+// in which existing recipes were entered is lost, so they keep the alphabetical order
+// they were listed in until then.
+func addRecipeIngredientPosition(tx *sql.Tx) error {
+	added, err := addColumnIfMissing(tx, "recipe_ingredients", "position", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil || !added {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE recipe_ingredients SET position = ordered.position
+		FROM (
+			SELECT ri.recipe_id, ri.ingredient_id,
+			       ROW_NUMBER() OVER (PARTITION BY ri.recipe_id ORDER BY i.name, ri.ingredient_id) - 1 AS position
+			FROM recipe_ingredients ri
+			JOIN ingredients i ON ri.ingredient_id = i.id
+		) AS ordered
+		WHERE recipe_ingredients.recipe_id = ordered.recipe_id AND recipe_ingredients.ingredient_id = ordered.ingredient_id`)
+	return err
+}
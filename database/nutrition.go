@@ -0,0 +1,60 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// GetRecipeNutrition returns the nutritional values of a recipe, or nil when none were provided
+func GetRecipeNutrition(recipeID int) (*models.Nutrition, error) {
+	if !utils.IsValidID(recipeID) {
+		return nil, fmt.Errorf("invalid recipe ID")
+	}
+
+	var nutrition models.Nutrition
+	err := DB.QueryRow("SELECT calories, protein_g, carbs_g, fat_g FROM recipe_nutrition WHERE recipe_id = ?", recipeID).
+		Scan(&nutrition.Calories, &nutrition.ProteinG, &nutrition.CarbsG, &nutrition.FatG)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if nutrition.IsEmpty() {
+		return nil, nil
+	}
+	return &nutrition, nil
+}
+
+// SetRecipeNutrition replaces the nutritional values of one of the user's recipes.
+// Setting every value to nil removes the nutrition information.
+func SetRecipeNutrition(recipeID, userID int, nutrition models.Nutrition) error {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid recipe or user ID")
+	}
+
+	owns, err := UserOwnsRecipe(recipeID, userID)
+	if err == sql.ErrNoRows || (err == nil && !owns) {
+		return ErrRecipeNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if nutrition.IsEmpty() {
+		_, err = DB.Exec("DELETE FROM recipe_nutrition WHERE recipe_id = ?", recipeID)
+		return err
+	}
+
+	_, err = DB.Exec(`
+		INSERT INTO recipe_nutrition (recipe_id, calories, protein_g, carbs_g, fat_g)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(recipe_id) DO UPDATE SET
+			calories = excluded.calories, protein_g = excluded.protein_g,
+			carbs_g = excluded.carbs_g, fat_g = excluded.fat_g
+	`, recipeID, nutrition.Calories, nutrition.ProteinG, nutrition.CarbsG, nutrition.FatG)
+	return err
+}
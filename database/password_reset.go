@@ -0,0 +1,110 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strings"
+	"time"
+)
+
+// ErrResetTokenInvalid is returned when a password reset token does not exist or has expired
+var ErrResetTokenInvalid = errors.New("invalid or expired password reset token")
+
+// hashToken returns the form emailed tokens are stored in, so a leaked
+// database cannot be used to reset passwords or verify emails
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetUserByEmail looks up a user by email address, ignoring case
+func GetUserByEmail(email string) (*models.User, error) {
+	if validation := utils.ValidateEmail(email); !validation.Valid {
+		return nil, fmt.Errorf("invalid email format")
+	}
+
+	var user models.User
+	err := DB.QueryRow("SELECT id, username, email FROM users WHERE lower(email) = lower(?)", strings.TrimSpace(email)).
+		Scan(&user.ID, &user.Username, &user.Email)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreatePasswordResetToken stores a reset token for the user, replacing any earlier ones
+func CreatePasswordResetToken(userID int, token string, expiresAt time.Time) error {
+	if !utils.IsValidID(userID) || token == "" {
+		return fmt.Errorf("invalid user ID or token")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM password_reset_tokens WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO password_reset_tokens (token, user_id, expires_at) VALUES (?, ?, ?)",
+		hashToken(token), userID, expiresAt.UTC().Format(auditTimeLayout)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResetPassword replaces the password of the user the token belongs to and deletes the token.
+// Every session of the user is revoked, see RevokeUserSessions. It returns the user ID, or ErrResetTokenInvalid when the token is unknown or expired.
+func ResetPassword(token, hashedPassword string) (int, error) {
+	if token == "" {
+		return 0, ErrResetTokenInvalid
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	hashed := hashToken(token)
+	var userID int
+	var expiresAt time.Time
+	err = tx.QueryRow("SELECT user_id, expires_at FROM password_reset_tokens WHERE token = ?", hashed).
+		Scan(&userID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if time.Now().After(expiresAt) {
+		// Expired tokens are useless, so clean them up right away
+		if _, err := tx.Exec("DELETE FROM password_reset_tokens WHERE token = ?", hashed); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+		return 0, ErrResetTokenInvalid
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password = ?, token_version = token_version + 1 WHERE id = ?", hashedPassword, userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("DELETE FROM password_reset_tokens WHERE user_id = ?", userID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
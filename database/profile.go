@@ -0,0 +1,123 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// ErrUserNotFound is returned when a user ID does not match any user
+var ErrUserNotFound = errors.New("user not found")
+
+// GetUserPublicProfile returns a user's profile with the number of published public recipes they have
+// shared. The email is included; callers must drop it when showing other users' profiles.
+func GetUserPublicProfile(userID int) (*models.UserProfile, error) {
+	if !utils.IsValidID(userID) {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	var profile models.UserProfile
+	err := DB.QueryRow("SELECT id, username, email, created_at FROM users WHERE id = ?", userID).
+		Scan(&profile.ID, &profile.Username, &profile.Email, &profile.JoinedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = DB.QueryRow(`
+		SELECT COUNT(*) FROM recipes r
+		WHERE r.created_by = ? AND r.deleted_at IS NULL AND `+publishedAndPublic+`
+	`, userID).Scan(&profile.RecipeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// GetUserPublicRecipes returns one page of a user's published public recipes, newest first
+func GetUserPublicRecipes(userID, limit, offset int) ([]models.Recipe, error) {
+	if !utils.IsValidID(userID) {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	if limit <= 0 || offset < 0 {
+		return nil, fmt.Errorf("invalid limit or offset")
+	}
+
+	rows, err := DB.Query(`
+		SELECT `+recipeColumns+`
+		FROM recipes r
+		JOIN users u ON r.created_by = u.id
+		WHERE r.created_by = ? AND r.deleted_at IS NULL AND `+publishedAndPublic+`
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipes []models.Recipe
+	for rows.Next() {
+		var recipe models.Recipe
+		if err := scanRecipe(rows, &recipe); err != nil {
+			continue
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	attachRecipeRelations(recipes)
+	return recipes, nil
+}
+
+// CountRecipesByUser returns how many recipes the user owns, including private ones
+func CountRecipesByUser(userID int) (int, error) {
+	var total int
+	err := DB.QueryRow("SELECT COUNT(*) FROM recipes WHERE created_by = ? AND deleted_at IS NULL", userID).Scan(&total)
+	return total, err
+}
+
+// GetRecipesByUser returns one page of the user's own recipes, including private ones,
+// in the order named by sortKey. Unknown keys fall back to DefaultRecipeSort (newest first).
+func GetRecipesByUser(userID int, sortKey string, limit, offset int) ([]models.Recipe, error) {
+	if !utils.IsValidID(userID) {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	if limit <= 0 || offset < 0 {
+		return nil, fmt.Errorf("invalid limit or offset")
+	}
+
+	orderBy, ok := recipeSortOrders[sortKey]
+	if !ok {
+		orderBy = recipeSortOrders[DefaultRecipeSort]
+	}
+
+	rows, err := DB.Query(`
+		SELECT `+recipeColumns+`
+		FROM recipes r
+		JOIN users u ON r.created_by = u.id
+		WHERE r.created_by = ? AND r.deleted_at IS NULL
+		ORDER BY `+orderBy+`
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipes []models.Recipe
+	for rows.Next() {
+		var recipe models.Recipe
+		if err := scanRecipe(rows, &recipe); err != nil {
+			continue
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	attachRecipeRelations(recipes)
+	return recipes, nil
+}
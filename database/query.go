@@ -0,0 +1,158 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// MaxRecipeTotalTime is the longest total time a recipe can have, as prep and cook
+// time are each limited to a day
+const MaxRecipeTotalTime = 2 * 1440
+
+// RecipeFilter selects the recipes QueryRecipes returns. Filters left at their zero
+// value are not applied; those that are set must all match.
+type RecipeFilter struct {
+	Search       string // text in the title, ingredients, tags, description or instructions
+	TagIDs       []int  // recipes carrying every one of these tags
+	Cuisine      string
+	MaxTotalTime int // minutes of prep plus cook time
+
+	// Sort is one of RecipeSortKeys. Without one, searches list the most relevant
+	// recipes first, time-limited lists the quickest and anything else the newest.
+	Sort string
+
+	Limit  int // 0 returns every match
+	Offset int
+
+	ViewerID int // 0 for anonymous viewers
+}
+
+// searchRelevance scores how well a recipe (alias r) matches a LIKE pattern, which it
+// takes as five parameters. A title match scores 3, an ingredient or tag match 2 each and
+// a description or instructions match 1 each. Recipes scoring 0 do not match at all.
+const searchRelevance = `
+	  CASE WHEN r.title LIKE ? THEN 3 ELSE 0 END
+	+ CASE WHEN EXISTS (
+	      SELECT 1 FROM recipe_ingredients ri
+	      JOIN ingredients i ON ri.ingredient_id = i.id
+	      WHERE ri.recipe_id = r.id AND i.name LIKE ?) THEN 2 ELSE 0 END
+	+ CASE WHEN EXISTS (
+	      SELECT 1 FROM recipe_tags rt
+	      JOIN tags t ON rt.tag_id = t.id
+	      WHERE rt.recipe_id = r.id AND t.name LIKE ?) THEN 2 ELSE 0 END
+	+ CASE WHEN r.description LIKE ? THEN 1 ELSE 0 END
+	+ CASE WHEN r.instructions LIKE ? THEN 1 ELSE 0 END`
+
+// QueryRecipes returns the recipes visible to the filter's viewer that match it, along
+// with the number of matches before Limit and Offset are applied. Searches set each
+// recipe's Relevance.
+func QueryRecipes(filter RecipeFilter) ([]models.Recipe, int, error) {
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, 0, fmt.Errorf("invalid limit or offset")
+	}
+
+	conditions := []string{"r.deleted_at IS NULL", visibleToViewer}
+	args := []interface{}{filter.ViewerID}
+
+	relevance := "0"
+	var relevanceArgs []interface{}
+	if filter.Search != "" {
+		if validation := utils.ValidateSearchQuery(filter.Search); !validation.Valid {
+			return nil, 0, fmt.Errorf("invalid search query: %s", validation.Message)
+		}
+		pattern := "%" + filter.Search + "%"
+		relevance = searchRelevance
+		relevanceArgs = []interface{}{pattern, pattern, pattern, pattern, pattern}
+
+		conditions = append(conditions, "("+searchRelevance+") > 0")
+		args = append(args, relevanceArgs...)
+	}
+
+	if len(filter.TagIDs) > 0 {
+		unique := make(map[int]bool, len(filter.TagIDs))
+		var tagArgs []interface{}
+		for _, tagID := range filter.TagIDs {
+			if !utils.IsValidID(tagID) {
+				return nil, 0, fmt.Errorf("invalid tag ID")
+			}
+			if !unique[tagID] {
+				unique[tagID] = true
+				tagArgs = append(tagArgs, tagID)
+			}
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tagArgs)), ", ")
+		conditions = append(conditions, `r.id IN (
+			SELECT recipe_id FROM recipe_tags WHERE tag_id IN (`+placeholders+`)
+			GROUP BY recipe_id HAVING COUNT(DISTINCT tag_id) = ?)`)
+		args = append(append(args, tagArgs...), len(tagArgs))
+	}
+
+	if cuisine := strings.TrimSpace(filter.Cuisine); cuisine != "" {
+		if validation := utils.ValidateCuisine(cuisine); !validation.Valid {
+			return nil, 0, fmt.Errorf("invalid cuisine")
+		}
+		conditions = append(conditions, "r.cuisine = ? COLLATE NOCASE")
+		args = append(args, cuisine)
+	}
+
+	if filter.MaxTotalTime != 0 {
+		if filter.MaxTotalTime < 1 || filter.MaxTotalTime > MaxRecipeTotalTime {
+			return nil, 0, fmt.Errorf("invalid total time")
+		}
+		conditions = append(conditions, "r.prep_time + r.cook_time <= ?")
+		args = append(args, filter.MaxTotalTime)
+	}
+
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM recipes r WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	orderBy, ok := recipeSortOrders[filter.Sort]
+	switch {
+	case ok:
+	case filter.Search != "":
+		orderBy = "relevance DESC, r.created_at DESC, r.id DESC"
+	case filter.MaxTotalTime != 0:
+		orderBy = recipeSortOrders["total_time"]
+	default:
+		orderBy = recipeSortOrders[DefaultRecipeSort]
+	}
+
+	query := `
+		SELECT ` + recipeColumns + `, ` + relevance + ` AS relevance
+		FROM recipes r
+		JOIN users u ON r.created_by = u.id
+		WHERE ` + where + `
+		ORDER BY ` + orderBy
+	args = append(relevanceArgs, args...)
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var recipes []models.Recipe
+	for rows.Next() {
+		var recipe models.Recipe
+		if err := scanRecipe(rows, &recipe, &recipe.Relevance); err != nil {
+			continue
+		}
+
+		recipes = append(recipes, recipe)
+	}
+
+	attachRecipeRelations(recipes)
+	return recipes, total, nil
+}
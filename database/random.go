@@ -0,0 +1,55 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"recipe-book/utils"
+)
+
+// RandomRecipeID picks a public, published recipe at random, optionally limited to those
+// carrying the tag and of the cuisine. Zero values leave a filter out. Rather than sorting
+// every match by RANDOM(), it counts the matches and skips a random number of them. It
+// returns ErrRecipeNotFound when no recipe matches.
+func RandomRecipeID(tagID int, cuisine string) (int, error) {
+	conditions := []string{"r.deleted_at IS NULL", publishedAndPublic}
+	var args []interface{}
+
+	if tagID != 0 {
+		if !utils.IsValidID(tagID) {
+			return 0, fmt.Errorf("invalid tag ID")
+		}
+		conditions = append(conditions, "r.id IN (SELECT recipe_id FROM recipe_tags WHERE tag_id = ?)")
+		args = append(args, tagID)
+	}
+
+	if cuisine = strings.TrimSpace(cuisine); cuisine != "" {
+		if validation := utils.ValidateCuisine(cuisine); !validation.Valid {
+			return 0, fmt.Errorf("invalid cuisine")
+		}
+		conditions = append(conditions, "r.cuisine = ? COLLATE NOCASE")
+		args = append(args, cuisine)
+	}
+
+	where := strings.Join(conditions, " AND ")
+
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM recipes r WHERE "+where, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrRecipeNotFound
+	}
+
+	var id int
+	err := DB.QueryRow("SELECT r.id FROM recipes r WHERE "+where+" ORDER BY r.id LIMIT 1 OFFSET ?",
+		append(args, rand.IntN(count))...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Recipes were removed since they were counted
+		return 0, ErrRecipeNotFound
+	}
+	return id, err
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"fmt"
+	"recipe-book/utils"
+)
+
+// SetRating stores a user's 1-5 star rating for a recipe, replacing any previous rating
+func SetRating(recipeID, userID, rating int) error {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid recipe or user ID")
+	}
+
+	if validation := utils.ValidateNumericInput(rating, 1, 5, "Rating"); !validation.Valid {
+		return fmt.Errorf("invalid rating: %s", validation.Message)
+	}
+
+	exists, err := recipeVisible(recipeID, userID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRecipeNotFound
+	}
+
+	_, err = DB.Exec("INSERT OR REPLACE INTO recipe_ratings (recipe_id, user_id, rating) VALUES (?, ?, ?)",
+		recipeID, userID, rating)
+	return err
+}
+
+// GetAverageRating returns the average rating and number of ratings for a recipe
+func GetAverageRating(recipeID int) (float64, int, error) {
+	var average float64
+	var count int
+	err := DB.QueryRow("SELECT COALESCE(AVG(rating), 0), COUNT(*) FROM recipe_ratings WHERE recipe_id = ?", recipeID).
+		Scan(&average, &count)
+	if err != nil {
+		return 0, 0, err
+	}
+	return average, count, nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"fmt"
+
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// GetRelatedRecipes returns up to limit recipes visible to the viewer (0 for anonymous)
+// that share tags or ingredients with the given recipe, scored by the number of tags
+// plus the number of ingredients they share. The best scores come first, and the newest
+// recipe among equal scores. It returns ErrRecipeNotFound unless the viewer may see the
+// recipe itself.
+func GetRelatedRecipes(recipeID, limit, viewerID int) ([]models.Recipe, error) {
+	if !utils.IsValidID(recipeID) {
+		return nil, fmt.Errorf("invalid recipe ID")
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit")
+	}
+
+	visible, err := recipeVisible(recipeID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, ErrRecipeNotFound
+	}
+
+	rows, err := DB.Query(`
+		SELECT `+recipeColumns+`
+		FROM (
+			SELECT recipe_id, COUNT(*) AS score FROM (
+				SELECT DISTINCT rt.recipe_id, rt.tag_id
+				FROM recipe_tags rt
+				WHERE rt.tag_id IN (SELECT tag_id FROM recipe_tags WHERE recipe_id = ?1)
+				UNION ALL
+				SELECT DISTINCT ri.recipe_id, ri.ingredient_id
+				FROM recipe_ingredients ri
+				WHERE ri.ingredient_id IN (SELECT ingredient_id FROM recipe_ingredients WHERE recipe_id = ?1)
+			)
+			GROUP BY recipe_id
+		) s
+		JOIN recipes r ON s.recipe_id = r.id
+		JOIN users u ON r.created_by = u.id
+		WHERE r.id != ?1 AND r.deleted_at IS NULL AND `+visibleToViewer+`
+		ORDER BY s.score DESC, r.created_at DESC, r.id DESC
+		LIMIT ?3
+	`, recipeID, viewerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipes []models.Recipe
+	for rows.Next() {
+		var recipe models.Recipe
+		if err := scanRecipe(rows, &recipe); err != nil {
+			continue
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	attachRecipeRelations(recipes)
+	return recipes, nil
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"recipe-book/utils"
+)
+
+// reservedSlugs are pages the frontend serves under /recipe/, which no recipe may take
+var reservedSlugs = map[string]bool{"new": true}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// IsRecipeSlug reports whether s has the form of a recipe slug. Recipe IDs and the
+// frontend's own pages under /recipe/ never do.
+func IsRecipeSlug(s string) bool {
+	if s == "" || reservedSlugs[s] || strings.Trim(s, "0123456789") == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return !strings.HasPrefix(s, "-") && !strings.HasSuffix(s, "-")
+}
+
+// recipeSlugBase is the slug for title before collisions are resolved. Titles whose slug
+// would look like an ID or a frontend page, such as "1984", become "recipe-1984".
+func recipeSlugBase(title string) string {
+	slug := utils.SlugifyTitle(title)
+	if !IsRecipeSlug(slug) {
+		slug = "recipe-" + slug
+	}
+	return slug
+}
+
+// uniqueRecipeSlug returns a slug for title that no recipe other than recipeID uses,
+// appending -2, -3, ... on collision. Pass 0 for a recipe that is not inserted yet.
+// Recipes in the trash keep their slugs so that restoring them keeps their URLs.
+func uniqueRecipeSlug(q queryRower, title string, recipeID int) (string, error) {
+	base := recipeSlugBase(title)
+	for n := 1; ; n++ {
+		slug := base
+		if n > 1 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+
+		var taken bool
+		if err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM recipes WHERE slug = ? AND id != ?)", slug, recipeID).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return slug, nil
+		}
+	}
+}
+
+// recipeSlugForUpdate returns the slug a recipe should have once retitled to title. A
+// recipe whose title is unchanged keeps its slug, so that links to it keep working.
+func recipeSlugForUpdate(q queryRower, recipeID int, title string) (string, error) {
+	var currentTitle, currentSlug string
+	err := q.QueryRow("SELECT title, COALESCE(slug, '') FROM recipes WHERE id = ?", recipeID).Scan(&currentTitle, &currentSlug)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrRecipeNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if currentTitle == title && currentSlug != "" {
+		return currentSlug, nil
+	}
+	return uniqueRecipeSlug(q, title, recipeID)
+}
+
+// GetRecipeIDBySlug looks up the recipe with the given slug, returning ErrRecipeNotFound
+// unless it exists and the viewer (0 for anonymous) may see it
+func GetRecipeIDBySlug(slug string, viewerID int) (int, error) {
+	var id int
+	err := DB.QueryRow(`
+		SELECT r.id FROM recipes r
+		WHERE r.slug = ? AND r.deleted_at IS NULL AND `+visibleToViewer, slug, viewerID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrRecipeNotFound
+	}
+	return id, err
+}
+
+// addRecipeSlug adds the slug column to recipes and gives every recipe without one a slug,
+// older recipes first so that they get the unsuffixed slugs
+func addRecipeSlug(tx *sql.Tx) error {
+	if _, err := addColumnIfMissing(tx, "recipes", "slug", "TEXT CHECK(length(slug) <= 100)"); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id, title FROM recipes WHERE slug IS NULL OR slug = '' ORDER BY id")
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id    int
+		title string
+	}
+	var recipes []pending
+	for rows.Next() {
+		var recipe pending
+		if err := rows.Scan(&recipe.id, &recipe.title); err != nil {
+			rows.Close()
+			return err
+		}
+		recipes = append(recipes, recipe)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, recipe := range recipes {
+		slug, err := uniqueRecipeSlug(tx, recipe.title, recipe.id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE recipes SET slug = ? WHERE id = ?", slug, recipe.id); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_recipes_slug ON recipes(slug)")
+	return err
+}
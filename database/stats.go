@@ -0,0 +1,108 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"recipe-book/models"
+)
+
+const (
+	// SiteStatsTTL is how long GetSiteStats reuses the stats it computed
+	SiteStatsTTL = time.Minute
+	// siteStatsTop is how many tags and ingredients the stats rank
+	siteStatsTop = 10
+)
+
+// The stats scan every recipe, so they are computed at most once per SiteStatsTTL
+var (
+	siteStatsMu     sync.Mutex
+	siteStatsCached *models.SiteStats
+)
+
+// GetSiteStats returns the site's totals and its most used tags and ingredients. The
+// result may be up to SiteStatsTTL old and is shared between callers, which must not
+// modify it.
+func GetSiteStats() (*models.SiteStats, error) {
+	siteStatsMu.Lock()
+	defer siteStatsMu.Unlock()
+
+	if siteStatsCached != nil && time.Since(siteStatsCached.GeneratedAt) < SiteStatsTTL {
+		return siteStatsCached, nil
+	}
+
+	stats, err := computeSiteStats()
+	if err != nil {
+		return nil, err
+	}
+	siteStatsCached = stats
+	return stats, nil
+}
+
+func computeSiteStats() (*models.SiteStats, error) {
+	stats := &models.SiteStats{
+		TopTags:        []models.TagUsage{},
+		TopIngredients: []models.IngredientUsage{},
+		GeneratedAt:    time.Now(),
+	}
+
+	err := DB.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM recipes r WHERE r.deleted_at IS NULL AND `+publishedAndPublic+`),
+			(SELECT COUNT(*) FROM ingredients),
+			(SELECT COUNT(*) FROM tags),
+			(SELECT COUNT(*) FROM users)
+	`).Scan(&stats.Recipes, &stats.Ingredients, &stats.Tags, &stats.Users)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT t.id, t.name, t.color, COUNT(*) AS recipe_count
+		FROM recipe_tags rt
+		JOIN tags t ON rt.tag_id = t.id
+		JOIN recipes r ON rt.recipe_id = r.id
+		WHERE r.deleted_at IS NULL AND `+publishedAndPublic+`
+		GROUP BY t.id
+		ORDER BY recipe_count DESC, t.name
+		LIMIT ?
+	`, siteStatsTop)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var tag models.TagUsage
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.RecipeCount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.TopTags = append(stats.TopTags, tag)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = DB.Query(`
+		SELECT i.id, i.name, i.category, COUNT(DISTINCT r.id) AS recipe_count
+		FROM recipe_ingredients ri
+		JOIN ingredients i ON ri.ingredient_id = i.id
+		JOIN recipes r ON ri.recipe_id = r.id
+		WHERE r.deleted_at IS NULL AND `+publishedAndPublic+`
+		GROUP BY i.id
+		ORDER BY recipe_count DESC, i.name
+		LIMIT ?
+	`, siteStatsTop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ingredient models.IngredientUsage
+		if err := rows.Scan(&ingredient.ID, &ingredient.Name, &ingredient.Category, &ingredient.RecipeCount); err != nil {
+			return nil, err
+		}
+		stats.TopIngredients = append(stats.TopIngredients, ingredient)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"time"
+)
+
+// TrashRetention is how long a deleted recipe stays in the recycle bin before it is purged
+const TrashRetention = 30 * 24 * time.Hour
+
+// GetDeletedRecipesByUser returns the recipes a user has moved to the recycle bin, most recently deleted first
+func GetDeletedRecipesByUser(userID int) ([]models.Recipe, error) {
+	if !utils.IsValidID(userID) {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	rows, err := DB.Query(`
+		SELECT `+recipeColumns+`, r.deleted_at
+		FROM recipes r
+		JOIN users u ON r.created_by = u.id
+		WHERE r.created_by = ? AND r.deleted_at IS NOT NULL
+		ORDER BY r.deleted_at DESC, r.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipes []models.Recipe
+	for rows.Next() {
+		var recipe models.Recipe
+		if err := scanRecipe(rows, &recipe, &recipe.DeletedAt); err != nil {
+			continue
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	attachRecipeRelations(recipes)
+	return recipes, nil
+}
+
+// RestoreRecipe moves a recipe out of the recycle bin. Only the owner can restore it.
+func RestoreRecipe(recipeID, userID int) error {
+	if !utils.IsValidID(recipeID) || !utils.IsValidID(userID) {
+		return fmt.Errorf("invalid recipe or user ID")
+	}
+
+	result, err := DB.Exec("UPDATE recipes SET deleted_at = NULL WHERE id = ? AND created_by = ? AND deleted_at IS NOT NULL", recipeID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecipeNotFound
+	}
+
+	return nil
+}
+
+// PurgeOldDeletedRecipes permanently removes recipes that have been in the recycle bin
+// longer than olderThan, along with their related rows and image files
+func PurgeOldDeletedRecipes(olderThan time.Duration) (int, error) {
+	cutoff := fmt.Sprintf("-%d seconds", int64(olderThan.Seconds()))
+
+	rows, err := DB.Query("SELECT id FROM recipes WHERE deleted_at IS NOT NULL AND deleted_at <= datetime('now', ?)", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	var recipeIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			recipeIDs = append(recipeIDs, id)
+		}
+	}
+	rows.Close()
+
+	purged := 0
+	for _, id := range recipeIDs {
+		images := GetRecipeImages(id)
+
+		if err := purgeRecipe(id); err != nil {
+			log.Printf("Failed to purge recipe %d: %v", id, err)
+			continue
+		}
+		purged++
+
+		for _, img := range images {
+			imagePath := filepath.Join("uploads", img.Filename)
+			if err := os.Remove(imagePath); err != nil && !os.IsNotExist(err) {
+				log.Printf("Failed to remove image %s: %v", imagePath, err)
+			}
+		}
+	}
+
+	return purged, nil
+}
+
+// purgeRecipe deletes a recipe from the recycle bin. Its related rows go with it through ON DELETE CASCADE.
+func purgeRecipe(recipeID int) error {
+	_, err := DB.Exec("DELETE FROM recipes WHERE id = ? AND deleted_at IS NOT NULL", recipeID)
+	return err
+}
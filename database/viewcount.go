@@ -0,0 +1,66 @@
+package database
+
+import (
+	"sync"
+)
+
+// Views are counted in memory and written in batches by FlushRecipeViews, so that
+// opening a recipe does not cost a database write. Counts not yet flushed are lost
+// if the process stops.
+var (
+	pendingViewsMu sync.Mutex
+	pendingViews   = make(map[int]int)
+)
+
+// CountRecipeView adds one view to a recipe's view_count at the next flush
+func CountRecipeView(recipeID int) {
+	pendingViewsMu.Lock()
+	pendingViews[recipeID]++
+	pendingViewsMu.Unlock()
+}
+
+// FlushRecipeViews writes the views counted since the last flush, returning how many
+// recipes were updated. If the write fails, the views are kept for the next attempt.
+func FlushRecipeViews() (int, error) {
+	pendingViewsMu.Lock()
+	views := pendingViews
+	pendingViews = make(map[int]int)
+	pendingViewsMu.Unlock()
+
+	if len(views) == 0 {
+		return 0, nil
+	}
+
+	if err := writeRecipeViews(views); err != nil {
+		pendingViewsMu.Lock()
+		for recipeID, count := range views {
+			pendingViews[recipeID] += count
+		}
+		pendingViewsMu.Unlock()
+		return 0, err
+	}
+
+	return len(views), nil
+}
+
+func writeRecipeViews(views map[int]int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("UPDATE recipes SET view_count = view_count + ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for recipeID, count := range views {
+		if _, err := stmt.Exec(count, recipeID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"fmt"
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// MaxRecipeViewsPerUser is how many recently viewed recipes are kept for each user
+const MaxRecipeViewsPerUser = 100
+
+// RecordRecipeView notes that a user opened a recipe, moving it to the top of their
+// recently viewed list, and forgets views beyond MaxRecipeViewsPerUser
+func RecordRecipeView(userID, recipeID int) error {
+	if !utils.IsValidID(userID) || !utils.IsValidID(recipeID) {
+		return fmt.Errorf("invalid recipe or user ID")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Millisecond timestamps keep views within the same second in order
+	if _, err := tx.Exec(`
+		INSERT INTO recipe_views (user_id, recipe_id, viewed_at)
+		VALUES (?, ?, strftime('%Y-%m-%d %H:%M:%f', 'now'))
+		ON CONFLICT (user_id, recipe_id) DO UPDATE SET viewed_at = excluded.viewed_at
+	`, userID, recipeID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM recipe_views
+		WHERE user_id = ?1 AND recipe_id NOT IN (
+			SELECT recipe_id FROM recipe_views WHERE user_id = ?1 ORDER BY viewed_at DESC LIMIT ?2
+		)
+	`, userID, MaxRecipeViewsPerUser); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRecentlyViewedRecipes returns up to limit recipes the user has opened, most recent first.
+// Recipes that were trashed or made private since are left out.
+func GetRecentlyViewedRecipes(userID, limit int) ([]models.Recipe, error) {
+	if !utils.IsValidID(userID) {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit")
+	}
+
+	rows, err := DB.Query(`
+		SELECT `+recipeColumns+`
+		FROM recipe_views v
+		JOIN recipes r ON v.recipe_id = r.id
+		JOIN users u ON r.created_by = u.id
+		WHERE v.user_id = ? AND r.deleted_at IS NULL AND `+visibleToViewer+`
+		ORDER BY v.viewed_at DESC
+		LIMIT ?
+	`, userID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipes []models.Recipe
+	for rows.Next() {
+		var recipe models.Recipe
+		if err := scanRecipe(rows, &recipe); err != nil {
+			continue
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	attachRecipeRelations(recipes)
+	return recipes, nil
+}
@@ -97,7 +97,7 @@ DB_PATH=/data/recipes.db
 
 # Security
 JWT_SECRET=$(openssl rand -hex 32)
-TRUSTED_PROXIES=nginx
+TRUSTED_PROXIES=172.32.0.0/16
 
 # Docker
 COMPOSE_PROJECT_NAME=recipe-book
@@ -0,0 +1,527 @@
+// Package docs serves the OpenAPI description of the JSON API
+package docs
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"recipe-book/database"
+	"recipe-book/handlers"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower-case HTTP methods to the operations of one path
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+}
+
+var (
+	specOnce sync.Once
+	specJSON []byte
+)
+
+// OpenAPIHandler serves the OpenAPI document for the JSON API
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	specOnce.Do(func() {
+		var err error
+		specJSON, err = json.MarshalIndent(Spec(), "", "  ")
+		if err != nil {
+			log.Printf("Error encoding OpenAPI document: %v", err)
+		}
+	})
+
+	if specJSON == nil {
+		http.Error(w, "OpenAPI document unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specJSON)
+}
+
+// Spec builds the OpenAPI document. Request and response schemas are derived from the
+// structs the handlers actually decode and encode, so they follow changes to those types.
+func Spec() *Document {
+	b := &builder{schemas: map[string]*Schema{}}
+
+	b.schemas["Error"] = object(map[string]*Schema{
+		"error": {Type: "string"},
+		"field": {Type: "string", Description: "The request field that failed validation, when there is one"},
+		"errors": arrayOf(object(map[string]*Schema{
+			"field":   {Type: "string"},
+			"message": {Type: "string"},
+		})),
+	})
+	b.schemas["Success"] = object(map[string]*Schema{
+		"success": {Type: "boolean"},
+		"message": {Type: "string"},
+		"data":    {Type: "object"},
+	})
+	b.schemas["RecipePage"] = object(map[string]*Schema{
+		"results":  arrayOf(b.schemaFor(models.Recipe{})),
+		"total":    {Type: "integer"},
+		"page":     {Type: "integer"},
+		"per_page": {Type: "integer"},
+	})
+
+	recipe := b.schemaFor(models.Recipe{})
+	recipeRequest := b.schemaFor(handlers.RecipeRequest{})
+	recipePage := ref("RecipePage")
+	ingredient := b.schemaFor(models.Ingredient{})
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Recipe Book API",
+			Version:     "1.0.0",
+			Description: "Authenticated endpoints use the auth_token cookie set by /api/login.",
+		},
+		Paths: map[string]PathItem{
+			// Authentication
+			"/api/register": {
+				"post": op("Auth", "Register a new user", false).body(b.schemaFor(handlers.RegisterRequest{})).
+					ok("Registered", ref("Success")).errors(400, 409, 429),
+			},
+			"/api/login": {
+				"post": op("Auth", "Log in and receive the auth_token cookie", false).body(b.schemaFor(handlers.LoginRequest{})).
+					ok("Logged in", ref("Success")).errors(400, 401, 429),
+			},
+			"/api/logout": {
+				"post": op("Auth", "Log out", false).ok("Logged out", ref("Success")),
+			},
+			"/api/auth/check": {
+				"get": op("Auth", "Return the logged-in user", true).ok("Current user", b.schemaFor(models.User{})),
+			},
+			"/api/auth/refresh": {
+				"post": op("Auth", "Issue a fresh token for the current session", true).ok("Refreshed", ref("Success")),
+			},
+			"/api/account/logout-all": {
+				"post": op("Auth", "Log out everywhere by revoking every token issued to the user", true).ok("Logged out", ref("Success")),
+			},
+			"/api/auth/forgot-password": {
+				"post": op("Auth", "Request a password reset token; always reports success", false).
+					body(b.schemaFor(handlers.ForgotPasswordRequest{})).ok("Accepted", ref("Success")).errors(400, 429),
+			},
+			"/api/auth/reset-password": {
+				"post": op("Auth", "Set a new password with a reset token", false).
+					body(b.schemaFor(handlers.ResetPasswordRequest{})).ok("Password changed", ref("Success")).errors(400, 429),
+			},
+			"/api/auth/verify": {
+				"get": op("Auth", "Verify an email address", false).query("token", "Verification token", true, &Schema{Type: "string"}).
+					ok("Verified", ref("Success")).errors(400, 409, 410),
+			},
+			"/api/auth/resend-verification": {
+				"post": op("Auth", "Send a new email verification token", true).ok("Sent", ref("Success")).errors(409, 429),
+			},
+
+			// Recipes
+			"/api/recipes": {
+				"get": op("Recipes", "List recipes visible to the caller; the filters can be combined", false).pagination().
+					query("q", "Only recipes matching this search, most relevant first unless sorted", false, &Schema{Type: "string"}).
+					query("sort", "Sort order", false, &Schema{Type: "string", Enum: database.RecipeSortKeys}).
+					query("tag", "Only recipes with this tag ID; may be repeated", false, &Schema{Type: "integer"}).
+					query("cuisine", "Only recipes of this cuisine", false, &Schema{Type: "string"}).
+					query("max_total_time", "Only recipes taking at most this many minutes of prep and cook time, quickest first", false, &Schema{Type: "integer"}).
+					ok("A page of recipes", recipePage).errors(400),
+				"post": op("Recipes", "Create a recipe", true).body(recipeRequest).
+					header("Idempotency-Key", "Up to 128 letters, digits and . _ : -; retrying with the same key within 24 hours returns the first recipe instead of creating another", &Schema{Type: "string"}).
+					ok("Already created with this Idempotency-Key", ref("Success")).
+					created("Created", ref("Success")).errors(400, 403),
+			},
+			"/api/recipes/random": {
+				"get": op("Recipes", "Get a random public recipe, as for /api/recipes/{id}", false).
+					query("tag", "Only recipes with this tag ID", false, &Schema{Type: "integer"}).
+					query("cuisine", "Only recipes of this cuisine", false, &Schema{Type: "string"}).
+					ok("The recipe", recipe).errors(400, 404),
+			},
+			"/api/recipes/slug/{slug}": {
+				"get": op("Recipes", "Get a recipe by its slug, as for /api/recipes/{id}", false).
+					slug().
+					ok("The recipe", recipe).errors(404),
+			},
+			"/api/recipes/{id}": {
+				"get": op("Recipes", "Get a recipe; supports If-None-Match", false).id().
+					ok("The recipe", recipe).errors(404),
+				"put": op("Recipes", "Update a recipe", true).id().body(recipeRequest).
+					ok("Updated", ref("Success")).errors(400, 403, 404),
+				"delete": op("Recipes", "Move a recipe to the trash", true).id().
+					ok("Deleted", ref("Success")).errors(403),
+			},
+			"/api/recipes/import": {
+				"post": op("Recipes", "Import a recipe exported as JSON", true).body(recipeRequest).
+					created("Imported", ref("Success")).errors(400, 403),
+			},
+			"/api/recipes/bulk-delete": {
+				"post": op("Recipes", "Move several recipes to the trash", true).body(arrayOf(&Schema{Type: "integer"})).
+					ok("Per-recipe results", ref("Success")).errors(400),
+			},
+			"/api/recipes/batch": {
+				"post": op("Recipes", "Get up to 50 recipes by ID in one request, in the order asked for", false).body(arrayOf(&Schema{Type: "integer"})).
+					ok("The recipes, and the IDs of those the caller cannot see", object(map[string]*Schema{
+						"results": arrayOf(recipe),
+						"missing": arrayOf(&Schema{Type: "integer"}),
+					})).errors(400),
+			},
+			"/api/recipes/trash": {
+				"get": op("Recipes", "List the caller's deleted recipes", true).ok("Deleted recipes", arrayOf(recipe)),
+			},
+			"/api/recipes/{id}/restore": {
+				"post": op("Recipes", "Restore a recipe from the trash", true).id().ok("Restored", ref("Success")).errors(404),
+			},
+			"/api/recipes/{id}/clone": {
+				"post": op("Recipes", "Copy a visible recipe into the caller's collection", true).id().
+					created("Cloned", ref("Success")).errors(403, 404),
+			},
+			"/api/recipes/{id}/visibility": {
+				"put": op("Recipes", "Make a recipe public or private", true).id().body(b.schemaFor(handlers.VisibilityRequest{})).
+					ok("Updated", ref("Success")).errors(400, 403),
+			},
+			"/api/recipes/{id}/publish": {
+				"post": op("Recipes", "Publish a draft", true).id().ok("Published", ref("Success")).errors(400, 403, 409),
+			},
+			"/api/recipes/{id}/nutrition": {
+				"put": op("Recipes", "Set nutritional values", true).id().body(b.schemaFor(models.Nutrition{})).
+					ok("Updated", ref("Success")).errors(400, 403),
+			},
+			"/api/recipes/{id}/suggested-tags": {
+				"get": op("Tags", "Suggest existing tags for a recipe from its ingredients, instructions and total time", false).id().
+					ok("Tags the recipe doesn't have yet", arrayOf(b.schemaFor(models.Tag{}))).errors(400, 404),
+			},
+			"/api/recipes/{id}/related": {
+				"get": op("Recipes", "List the recipes sharing the most tags and ingredients with a recipe", false).id().
+					query("limit", "How many recipes to return (default 5, at most 20)", false, &Schema{Type: "integer"}).
+					ok("Related recipes, those sharing the most tags and ingredients first, then the newest", arrayOf(recipe)).errors(400, 404),
+			},
+			"/api/my/recipes": {
+				"get": op("Recipes", "List the caller's recipes, including private ones and drafts", true).pagination().
+					query("sort", "Sort order", false, &Schema{Type: "string", Enum: database.RecipeSortKeys}).
+					ok("A page of recipes", recipePage).errors(400),
+			},
+			"/api/my/recently-viewed": {
+				"get": op("Recipes", "List the recipes the caller opened most recently", true).
+					query("limit", "How many recipes to return (default 10, at most 100)", false, &Schema{Type: "integer"}).
+					ok("Recently viewed recipes, most recent first", arrayOf(recipe)).errors(400),
+			},
+
+			// Search
+			"/api/search": {
+				"get": op("Search", "Search recipes by title, ingredients, tags and text", false).
+					query("q", "Search query", true, &Schema{Type: "string"}).
+					ok("Matching recipes, most relevant first", object(map[string]*Schema{
+						"success": {Type: "boolean"},
+						"query":   {Type: "string"},
+						"results": arrayOf(recipe),
+						"count":   {Type: "integer"},
+					})).errors(400, 429),
+			},
+
+			// Ingredients
+			"/api/ingredients": {
+				"get": op("Ingredients", "List ingredients", false).
+					query("category", "Only ingredients in this category", false, &Schema{Type: "string", Enum: utils.IngredientCategories}).
+					ok("All ingredients", arrayOf(ingredient)).errors(400),
+				"post": op("Ingredients", "Create an ingredient", true).body(b.schemaFor(handlers.IngredientRequest{})).
+					ok("Created", ref("Success")).errors(400, 409),
+			},
+			"/api/ingredient-categories": {
+				"get": op("Ingredients", "List the ingredient categories", false).
+					ok("Categories, in shopping list order", arrayOf(&Schema{Type: "string"})),
+			},
+			"/api/ingredients/bulk": {
+				"post": op("Ingredients", "Create several ingredients, reporting which were created, already existed or were invalid", true).
+					body(arrayOf(&Schema{Type: "string"})).ok("Per-name results", ref("Success")).errors(400),
+			},
+			"/api/ingredients/merge": {
+				"post": op("Ingredients", "Merge an ingredient into another, moving its recipes and deleting it", true).
+					body(b.schemaFor(handlers.MergeIngredientsRequest{})).ok("Merged", ref("Success")).errors(400, 404),
+			},
+			"/api/ingredients/stats": {
+				"get": op("Ingredients", "List ingredients with how many recipes use each, most used first and unused ones last", false).pagination().
+					ok("A page of ingredients", object(map[string]*Schema{
+						"results":  arrayOf(b.schemaFor(models.IngredientUsage{})),
+						"total":    {Type: "integer"},
+						"page":     {Type: "integer"},
+						"per_page": {Type: "integer"},
+					})).errors(400),
+			},
+			"/api/ingredients/{id}": {
+				"get": op("Ingredients", "Get an ingredient with a page of the recipes that use it", false).id().pagination().
+					ok("The ingredient and its recipes", object(map[string]*Schema{
+						"ingredient": ingredient,
+						"recipes": object(map[string]*Schema{
+							"results":  arrayOf(b.schemaFor(models.RecipeSummary{})),
+							"total":    {Type: "integer"},
+							"page":     {Type: "integer"},
+							"per_page": {Type: "integer"},
+						}),
+					})).errors(400, 404),
+				"delete": op("Ingredients", "Delete an unused ingredient", true).id().ok("Deleted", ref("Success")).errors(400, 409),
+			},
+
+			// Tags
+			"/api/tags": {
+				"get": op("Tags", "List tags with how many recipes use each", false).
+					query("sort", "count lists the most used tags first; tags are sorted by name otherwise", false, &Schema{Type: "string", Enum: []string{"name", "count"}}).
+					ok("All tags", arrayOf(b.schemaFor(models.TagUsage{}))),
+				"post": op("Tags", "Create a tag", true).body(b.schemaFor(handlers.TagRequest{})).
+					ok("Created", ref("Success")).errors(400, 409),
+			},
+			"/api/tags/merge": {
+				"post": op("Tags", "Merge a tag into another, moving its recipes and deleting it", true).
+					body(b.schemaFor(handlers.MergeTagsRequest{})).ok("Merged", ref("Success")).errors(400, 404),
+			},
+			"/api/tags/{id}": {
+				"get": op("Tags", "Get a tag with how many recipes use it", false).id().
+					ok("The tag", b.schemaFor(models.TagUsage{})).errors(400, 404),
+				"put": op("Tags", "Rename or recolor a tag, keeping its recipes", true).id().
+					body(b.schemaFor(handlers.UpdateTagRequest{})).ok("Updated", ref("Success")).errors(400, 404, 409),
+				"delete": op("Tags", "Delete a tag; tags still on recipes need force=true", true).id().
+					query("force", "Delete the tag even though recipes use it", false, &Schema{Type: "boolean"}).
+					ok("Deleted", ref("Success")).errors(400, 404, 409),
+			},
+
+			// Statistics
+			"/api/stats": {
+				"get": op("Statistics", "Site totals and the 10 most used tags and ingredients, at most a minute old", false).
+					ok("Site statistics", b.schemaFor(models.SiteStats{})),
+			},
+		},
+		Components: Components{
+			Schemas: b.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				"cookieAuth": {Type: "apiKey", In: "cookie", Name: "auth_token"},
+			},
+		},
+	}
+
+	return doc
+}
+
+// op starts an operation; authenticated operations require the auth cookie and may return 401
+func op(tag, summary string, authenticated bool) *Operation {
+	o := &Operation{Summary: summary, Tags: []string{tag}, Responses: map[string]Response{}}
+	if authenticated {
+		o.Security = []map[string][]string{{"cookieAuth": {}}}
+		o.errors(401)
+	}
+	return o
+}
+
+func (o *Operation) id() *Operation {
+	o.Parameters = append(o.Parameters, Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer"}})
+	return o
+}
+
+func (o *Operation) slug() *Operation {
+	o.Parameters = append(o.Parameters, Parameter{Name: "slug", In: "path", Description: "e.g. classic-margherita-pizza", Required: true, Schema: &Schema{Type: "string"}})
+	return o
+}
+
+func (o *Operation) query(name, description string, required bool, schema *Schema) *Operation {
+	o.Parameters = append(o.Parameters, Parameter{Name: name, In: "query", Description: description, Required: required, Schema: schema})
+	return o
+}
+
+func (o *Operation) header(name, description string, schema *Schema) *Operation {
+	o.Parameters = append(o.Parameters, Parameter{Name: name, In: "header", Description: description, Schema: schema})
+	return o
+}
+
+func (o *Operation) pagination() *Operation {
+	return o.query("page", "Page number, starting at 1; pages may start at most 100,000 results in", false, &Schema{Type: "integer"}).
+		query("per_page", "Results per page (default 20, maximum 100)", false, &Schema{Type: "integer"})
+}
+
+func (o *Operation) body(schema *Schema) *Operation {
+	o.RequestBody = &RequestBody{Required: true, Content: jsonContent(schema)}
+	return o
+}
+
+func (o *Operation) ok(description string, schema *Schema) *Operation {
+	o.Responses["200"] = Response{Description: description, Content: jsonContent(schema)}
+	return o
+}
+
+func (o *Operation) created(description string, schema *Schema) *Operation {
+	o.Responses["201"] = Response{Description: description, Content: jsonContent(schema)}
+	return o
+}
+
+func (o *Operation) errors(statuses ...int) *Operation {
+	for _, status := range statuses {
+		o.Responses[strconv.Itoa(status)] = Response{
+			Description: http.StatusText(status),
+			Content:     jsonContent(ref("Error")),
+		}
+	}
+	return o
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func object(properties map[string]*Schema) *Schema {
+	return &Schema{Type: "object", Properties: properties}
+}
+
+func arrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// builder collects the named struct schemas referenced by the document
+type builder struct {
+	schemas map[string]*Schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor returns the schema of v's type. Named structs are added to the components
+// and referenced, so each one is described once.
+func (b *builder) schemaFor(v interface{}) *Schema {
+	return b.schemaForType(reflect.TypeOf(v))
+}
+
+func (b *builder) schemaForType(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := b.schemaForType(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return arrayOf(b.schemaForType(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaForType(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, exists := b.schemas[t.Name()]; !exists {
+			b.schemas[t.Name()] = &Schema{} // Placeholder so recursive types terminate
+			b.schemas[t.Name()] = b.structSchema(t)
+		}
+		return ref(t.Name())
+	default:
+		return &Schema{}
+	}
+}
+
+func (b *builder) structSchema(t reflect.Type) *Schema {
+	schema := object(map[string]*Schema{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a JSON name contribute their fields directly
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for key, value := range b.structSchema(field.Type).Properties {
+				schema.Properties[key] = value
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = b.schemaForType(field.Type)
+	}
+	return schema
+}
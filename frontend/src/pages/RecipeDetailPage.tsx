@@ -36,7 +36,7 @@ const RecipeDetailPage: React.FC = () => {
 
   useEffect(() => {
     const loadRecipe = async () => {
-      if (!id || isNaN(Number(id))) {
+      if (!id) {
         setError('Invalid recipe ID');
         setIsLoading(false);
         return;
@@ -44,7 +44,10 @@ const RecipeDetailPage: React.FC = () => {
 
       try {
         setIsLoading(true);
-        const recipeData = await apiService.getRecipe(Number(id));
+        // Recipes are linked by ID or by slug, e.g. /recipe/classic-margherita-pizza
+        const recipeData = isNaN(Number(id))
+          ? await apiService.getRecipeBySlug(id)
+          : await apiService.getRecipe(Number(id));
         setRecipe(recipeData);
         setServings(recipeData.servings);
         setOriginalServings(recipeData.servings);
@@ -211,6 +214,11 @@ const RecipeDetailPage: React.FC = () => {
               {recipe.servings > 0 ? `${recipe.servings} ${recipe.serving_unit}` : 'Not specified'}
             </div>
             <div className="text-sm text-gray-500">Servings</div>
+            {recipe.yield_quantity > 0 && (
+              <div className="text-sm text-gray-600 mt-1">
+                Makes {formatCookingQuantity(recipe.yield_quantity)} {recipe.yield_unit}
+              </div>
+            )}
           </div>
           <div className="text-center">
             <Calculator className="w-6 h-6 text-red-600 mx-auto mb-2" />
@@ -310,6 +318,12 @@ const RecipeDetailPage: React.FC = () => {
                       </span>
                       <span className="text-gray-600 ml-1">{ingredient.unit}</span>
                       <span className="text-gray-900 ml-2">{ingredient.name}</span>
+                      {ingredient.preparation && (
+                        <span className="text-gray-500">, {ingredient.preparation}</span>
+                      )}
+                      {ingredient.optional && (
+                        <span className="text-gray-400 italic ml-1">(optional)</span>
+                      )}
                     </div>
                   </li>
                 ))}
@@ -63,7 +63,9 @@ const RecipeFormPage: React.FC = () => {
       cook_time: 0,
       servings: 4,
       serving_unit: 'people',
-      ingredients: [{ ingredient_id: 0, quantity: 0, unit: '' }],
+      yield_quantity: 0,
+      yield_unit: '',
+      ingredients: [{ ingredient_id: 0, quantity: 0, unit: '', preparation: '', optional: false }],
       tags: [],
       images: null
     }
@@ -137,13 +139,17 @@ const RecipeFormPage: React.FC = () => {
         cook_time: recipe.cook_time || 0,
         servings: recipe.servings || 4,
         serving_unit: recipe.serving_unit || 'people',
+        yield_quantity: recipe.yield_quantity || 0,
+        yield_unit: recipe.yield_unit || '',
         ingredients: recipe.ingredients?.length > 0 
           ? recipe.ingredients.map(ing => ({
               ingredient_id: ing.ingredient_id || 0,
               quantity: ing.quantity || 0,
-              unit: ing.unit || ''
+              unit: ing.unit || '',
+              preparation: ing.preparation || '',
+              optional: ing.optional || false
             }))
-          : [{ ingredient_id: 0, quantity: 0, unit: '' }],
+          : [{ ingredient_id: 0, quantity: 0, unit: '', preparation: '', optional: false }],
         tags: recipe.tags?.map(tag => tag.id) || [],
         images: null
       });
@@ -207,6 +213,8 @@ const RecipeFormPage: React.FC = () => {
         cook_time: data.cook_time,
         servings: data.servings,
         serving_unit: data.serving_unit,
+        yield_quantity: data.yield_quantity || 0,
+        yield_unit: data.yield_quantity ? data.yield_unit : '',
         ingredients: validIngredients,
         tags: Array.from(selectedTags),
       };
@@ -224,6 +232,9 @@ const RecipeFormPage: React.FC = () => {
           try {
             const imageResponse = await apiService.uploadRecipeImages(recipeId, Array.from(data.images));
             uploadedImages = imageResponse.data?.images?.length || 0;
+            if ((imageResponse.data?.skipped || 0) + (imageResponse.data?.rejected?.length || 0) > 0) {
+              toast.error(imageResponse.message || 'Some images were not uploaded');
+            }
           } catch (error) {
             console.warn('Failed to upload images:', error);
             toast.error('Recipe updated but some images failed to upload');
@@ -250,6 +261,9 @@ const RecipeFormPage: React.FC = () => {
           try {
             const imageResponse = await apiService.uploadRecipeImages(recipeId, Array.from(data.images));
             uploadedImages = imageResponse.data?.images?.length || 0;
+            if ((imageResponse.data?.skipped || 0) + (imageResponse.data?.rejected?.length || 0) > 0) {
+              toast.error(imageResponse.message || 'Some images were not uploaded');
+            }
           } catch (error) {
             console.warn('Failed to upload images:', error);
             toast.error('Recipe created but some images failed to upload');
@@ -459,6 +473,33 @@ const RecipeFormPage: React.FC = () => {
               }))}
               error={errors.serving_unit?.message}
             />
+
+            <Input
+              label="Makes (optional)"
+              type="number"
+              step="0.1"
+              min="0"
+              {...register('yield_quantity', {
+                min: { value: 0, message: 'Cannot be negative' },
+                max: { value: 10000, message: 'Yield too large' },
+                valueAsNumber: true
+              })}
+              error={errors.yield_quantity?.message}
+              placeholder="e.g. 24"
+            />
+
+            <Select
+              label="Yield Unit"
+              {...register('yield_unit')}
+              options={[
+                { value: '', label: 'Select unit...' },
+                ...SERVING_UNITS.map(unit => ({
+                  value: unit.value,
+                  label: unit.label
+                }))
+              ]}
+              error={errors.yield_unit?.message}
+            />
           </div>
         </Card>
 
@@ -547,6 +588,26 @@ const RecipeFormPage: React.FC = () => {
                   />
                 </div>
 
+                <div className="w-40">
+                  <Input
+                    label={index === 0 ? "Preparation" : ""}
+                    {...register(`ingredients.${index}.preparation` as const, {
+                      maxLength: { value: 100, message: 'At most 100 characters' }
+                    })}
+                    error={errors.ingredients?.[index]?.preparation?.message}
+                    placeholder="e.g. finely chopped"
+                  />
+                </div>
+
+                <label className="flex items-center gap-1 pb-2 text-sm text-gray-700" title="Garnishes and add-ins are left off the shopping list">
+                  <input
+                    type="checkbox"
+                    {...register(`ingredients.${index}.optional` as const)}
+                    className="rounded border-gray-300 text-red-600 focus:ring-red-500"
+                  />
+                  Optional
+                </label>
+
                 <Button
                   type="button"
                   variant="danger"
@@ -562,7 +623,7 @@ const RecipeFormPage: React.FC = () => {
           <Button
             type="button"
             variant="secondary"
-            onClick={() => append({ ingredient_id: 0, quantity: 0, unit: '' })}
+            onClick={() => append({ ingredient_id: 0, quantity: 0, unit: '', preparation: '', optional: false })}
             icon={<Plus className="w-4 h-4" />}
             className="mt-3"
           >
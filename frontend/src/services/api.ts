@@ -12,7 +12,9 @@ import {
   IngredientForm,
   TagForm,
   ApiResponse,
-  SearchResponse
+  SearchResponse,
+  PaginatedResponse,
+  ImageUploadResult
 } from '@/types';
 
 // Configure axios defaults
@@ -134,14 +136,23 @@ class ApiService {
   }
 
   // Recipe API (JSON only - no images)
-  async getRecipes(): Promise<Recipe[]> {
-    return this.request('GET', '/api/recipes');
+  async getRecipes(page = 1, perPage = 100): Promise<Recipe[]> {
+    const response = await this.getRecipesPage(page, perPage);
+    return response.results;
+  }
+
+  async getRecipesPage(page = 1, perPage = 20): Promise<PaginatedResponse<Recipe>> {
+    return this.request('GET', `/api/recipes?page=${page}&per_page=${perPage}`);
   }
 
   async getRecipe(id: number): Promise<Recipe> {
     return this.request('GET', `/api/recipes/${id}`);
   }
 
+  async getRecipeBySlug(slug: string): Promise<Recipe> {
+    return this.request('GET', `/api/recipes/slug/${encodeURIComponent(slug)}`);
+  }
+
   async searchRecipes(query: string): Promise<SearchResponse> {
     return this.request('GET', `/api/search?q=${encodeURIComponent(query)}`);
   }
@@ -162,6 +173,8 @@ class ApiService {
       cook_time: jsonData.cook_time,
       servings: jsonData.servings,
       serving_unit: jsonData.serving_unit,
+      yield_quantity: jsonData.yield_quantity || 0,
+      yield_unit: jsonData.yield_unit || '',
       ingredients: jsonData.ingredients,
       tags: jsonData.tags
     };
@@ -181,6 +194,8 @@ class ApiService {
       cook_time: jsonData.cook_time,
       servings: jsonData.servings,
       serving_unit: jsonData.serving_unit,
+      yield_quantity: jsonData.yield_quantity || 0,
+      yield_unit: jsonData.yield_unit || '',
       ingredients: jsonData.ingredients,
       tags: jsonData.tags
     };
@@ -193,7 +208,7 @@ class ApiService {
   }
 
   // Image API (Form data only)
-  async uploadRecipeImages(recipeId: number, images: File[]): Promise<ApiResponse<{ images: any[] }>> {
+  async uploadRecipeImages(recipeId: number, images: File[]): Promise<ApiResponse<ImageUploadResult>> {
     if (!images || images.length === 0) {
       throw { error: 'No images provided' };
     }
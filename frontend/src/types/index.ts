@@ -13,6 +13,8 @@ export interface RecipeIngredient {
   name: string;
   unit: string;
   quantity: number;
+  preparation?: string;
+  optional?: boolean;
 }
 
 export interface RecipeImage {
@@ -21,6 +23,17 @@ export interface RecipeImage {
   filename: string;
   caption: string;
   order: number;
+  is_primary: boolean;
+}
+
+// Result of POST /api/recipes/{id}/images
+export interface ImageUploadResult {
+  images: RecipeImage[];
+  // Files left out because the recipe reached max_images
+  skipped: number;
+  // Files that were not valid images, with the reason
+  rejected: { filename: string; error: string }[];
+  max_images: number;
 }
 
 export interface Tag {
@@ -29,19 +42,39 @@ export interface Tag {
   color: string;
 }
 
+// Values that were not provided are absent
+export interface Nutrition {
+  calories?: number;
+  protein_g?: number;
+  carbs_g?: number;
+  fat_g?: number;
+}
+
 export interface Recipe {
   id: number;
   title: string;
+  slug: string;
   description: string;
   instructions: string;
   prep_time: number;
   cook_time: number;
   servings: number;
   serving_unit: string;
+  yield_quantity: number;
+  yield_unit: string;
+  cuisine: string;
+  source_url: string; // Empty when the recipe is original
+  video_url: string; // Canonical YouTube or Vimeo link, or empty
+  view_count: number;
+  is_public: boolean;
+  status: 'draft' | 'published';
   created_by: number;
   created_at: string;
   ingredients: RecipeIngredient[];
   images: RecipeImage[];
+  cover_image: RecipeImage | null;
+  relevance?: number; // Only present in search results
+  nutrition?: Nutrition; // Only present on the single recipe view
   tags: Tag[];
   author_name: string;
 }
@@ -50,6 +83,7 @@ export interface Recipe {
 export interface Ingredient {
   id: number;
   name: string;
+  category: string;
 }
 
 // Form types
@@ -68,6 +102,8 @@ export interface RecipeFormIngredient {
   ingredient_id: number;
   quantity: number;
   unit: string;
+  preparation?: string;
+  optional?: boolean;
 }
 
 export interface RecipeForm {
@@ -78,6 +114,8 @@ export interface RecipeForm {
   cook_time: number;
   servings: number;
   serving_unit: string;
+  yield_quantity?: number;
+  yield_unit?: string;
   ingredients: RecipeFormIngredient[];
   tags: number[];
   images?: File[];
@@ -93,10 +131,19 @@ export interface TagForm {
 }
 
 // API response types
+export interface FieldError {
+  field: string;
+  message: string;
+}
+
 export interface ApiResponse<T = any> {
   success: boolean;
   message?: string;
   error?: string;
+  // Request field that failed validation, e.g. "title"
+  field?: string;
+  // Every failed validation, when the endpoint checks all fields
+  errors?: FieldError[];
   data?: T;
   redirect?: string;
   // Additional properties for specific error cases
@@ -112,6 +159,13 @@ export interface SearchResponse {
   count: number;
 }
 
+export interface PaginatedResponse<T> {
+  results: T[];
+  total: number;
+  page: number;
+  per_page: number;
+}
+
 export interface ValidationError {
   field: string;
   message: string;
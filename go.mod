@@ -3,6 +3,7 @@ module recipe-book
 go 1.24.3
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/gorilla/mux v1.8.1
 	golang.org/x/crypto v0.38.0
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Admin Handlers
+
+// auditEventRegex matches security event names such as RECIPE_DELETED
+var auditEventRegex = regexp.MustCompile(`^[A-Z_]{1,100}$`)
+
+// requireAdmin returns the current user if they are an administrator. Otherwise it
+// responds with 401 or 403 and returns false.
+func requireAdmin(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return nil, false
+	}
+
+	if !user.IsAdmin {
+		utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_ADMIN_ACCESS", utils.ClientIP(r), user.ID, fmt.Sprintf("Path: %s, User: %s", r.URL.Path, user.Username))
+		sendJSONError(w, http.StatusForbidden, "Admin access required")
+		return nil, false
+	}
+
+	return user, true
+}
+
+// parseAuditTime accepts an RFC 3339 timestamp or a YYYY-MM-DD date. A date used as
+// the end of a range covers the whole day.
+func parseAuditTime(value, field string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date (YYYY-MM-DD) or an RFC 3339 timestamp", field)
+	}
+	if endOfDay {
+		date = date.Add(24*time.Hour - time.Second)
+	}
+	return date, nil
+}
+
+func GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	var filter database.AuditFilter
+
+	if event := strings.ToUpper(strings.TrimSpace(query.Get("event"))); event != "" {
+		if !auditEventRegex.MatchString(event) {
+			sendJSONError(w, http.StatusBadRequest, "Invalid event type")
+			return
+		}
+		filter.EventType = event
+	}
+	if v := query.Get("from"); v != "" {
+		if filter.From, err = parseAuditTime(v, "from", false); err != nil {
+			sendJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if filter.To, err = parseAuditTime(v, "to", true); err != nil {
+			sendJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		sendJSONError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	entries, total, err := database.GetAuditLog(filter, limit, offset)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch audit log")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"results":  entries,
+		"total":    total,
+		"page":     offset/limit + 1,
+		"per_page": limit,
+		"enabled":  utils.AuditEnabled(),
+	})
+}
+
+// BackupHandler streams a consistent snapshot of the SQLite database as a download
+func BackupHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	// VACUUM INTO refuses to overwrite files, so the snapshot goes into a fresh directory
+	dir, err := os.MkdirTemp("", "recipe-book-backup-")
+	if err != nil {
+		utils.LogUserSecurityEvent(r.Context(), "ADMIN_BACKUP_ERROR", clientIP, user.ID, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to create backup")
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "recipes-backup.db")
+	if err := database.BackupTo(path); err != nil {
+		if errors.Is(err, database.ErrBackupInProgress) {
+			sendJSONError(w, http.StatusConflict, "A backup is already in progress, try again shortly")
+			return
+		}
+		utils.LogUserSecurityEvent(r.Context(), "ADMIN_BACKUP_ERROR", clientIP, user.ID, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to create backup")
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		utils.LogUserSecurityEvent(r.Context(), "ADMIN_BACKUP_ERROR", clientIP, user.ID, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to create backup")
+		return
+	}
+	defer file.Close()
+
+	utils.LogUserSecurityEvent(r.Context(), "ADMIN_BACKUP", clientIP, user.ID, fmt.Sprintf("User: %s", user.Username))
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", "attachment; filename=recipes-backup.db")
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Error streaming backup: %v", err)
+	}
+}
+
+// CleanupImagesHandler deletes uploaded files that no recipe image refers to any more.
+// With dry_run=true it only lists them.
+func CleanupImagesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			sendJSONError(w, http.StatusBadRequest, "dry_run must be true or false")
+			return
+		}
+	}
+
+	orphans, err := database.FindOrphanedImageFiles("uploads")
+	if err != nil {
+		utils.LogUserSecurityEvent(r.Context(), "ADMIN_IMAGE_CLEANUP_ERROR", clientIP, user.ID, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to find orphaned images")
+		return
+	}
+
+	if dryRun {
+		if orphans == nil {
+			orphans = []string{}
+		}
+		sendJSONSuccess(w, fmt.Sprintf("%d orphaned image files found", len(orphans)), map[string]interface{}{
+			"dry_run": true,
+			"files":   orphans,
+		})
+		return
+	}
+
+	removed := []string{}
+	failed := []string{}
+	for _, filename := range orphans {
+		path := filepath.Join("uploads", filename)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove orphaned image %s: %v", path, err)
+			failed = append(failed, filename)
+			continue
+		}
+		log.Printf("🧹 Removed orphaned image %s", path)
+		removed = append(removed, filename)
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "ADMIN_IMAGE_CLEANUP", clientIP, user.ID,
+		fmt.Sprintf("User: %s, Removed: %d, Failed: %d", user.Username, len(removed), len(failed)))
+
+	sendJSONSuccess(w, fmt.Sprintf("%d orphaned image files removed", len(removed)), map[string]interface{}{
+		"dry_run": false,
+		"files":   removed,
+		"failed":  failed,
+	})
+}
@@ -1,16 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
 	"recipe-book/auth"
 	"recipe-book/database"
+	"recipe-book/models"
 	"recipe-book/utils"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
@@ -29,25 +35,56 @@ type LoginRequest struct {
 }
 
 type RecipeRequest struct {
-	Title        string                `json:"title"`
-	Description  string                `json:"description"`
-	Instructions string                `json:"instructions"`
-	PrepTime     int                   `json:"prep_time"`
-	CookTime     int                   `json:"cook_time"`
-	Servings     int                   `json:"servings"`
-	ServingUnit  string                `json:"serving_unit"`
-	Ingredients  []RecipeIngredientReq `json:"ingredients"`
-	Tags         []int                 `json:"tags"`
+	Title         string                `json:"title"`
+	Description   string                `json:"description"`
+	Instructions  string                `json:"instructions"`
+	PrepTime      int                   `json:"prep_time"`
+	CookTime      int                   `json:"cook_time"`
+	Servings      int                   `json:"servings"`
+	ServingUnit   string                `json:"serving_unit"`
+	YieldQuantity float64               `json:"yield_quantity,omitempty"` // what the recipe makes, e.g. 24 cookies
+	YieldUnit     string                `json:"yield_unit,omitempty"`
+	Cuisine       string                `json:"cuisine"`
+	SourceURL     string                `json:"source_url"` // Where the recipe was adapted from; http or https only
+	VideoURL      string                `json:"video_url"`  // YouTube or Vimeo link, stored in canonical form
+	IsPublic      *bool                 `json:"is_public"`  // Defaults to public; omitted on update keeps the current setting
+	Status        string                `json:"status"`     // "draft" or "published" (default); updates keep the current status
+	Ingredients   []RecipeIngredientReq `json:"ingredients"`
+	Tags          []int                 `json:"tags"`
 }
 
 type RecipeIngredientReq struct {
 	IngredientID int     `json:"ingredient_id"`
+	Name         string  `json:"name,omitempty"` // Used by import when the ID is unknown
 	Quantity     float64 `json:"quantity"`
 	Unit         string  `json:"unit"`
+	Preparation  string  `json:"preparation,omitempty"` // e.g. "finely chopped"
+	Optional     bool    `json:"optional,omitempty"`    // garnishes and add-ins the dish works without
+}
+
+// maxPreparationLength matches the recipe_ingredients preparation CHECK constraint
+const maxPreparationLength = 100
+
+type VisibilityRequest struct {
+	IsPublic bool `json:"is_public"`
+}
+
+type DeleteAccountRequest struct {
+	ConfirmUsername string `json:"confirm_username"`
+}
+
+type RatingRequest struct {
+	Rating int `json:"rating"`
 }
 
 type IngredientRequest struct {
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	Category string `json:"category"` // One of utils.IngredientCategories; defaults to "other"
+}
+
+type MergeIngredientsRequest struct {
+	SourceID int `json:"source_id"`
+	TargetID int `json:"target_id"`
 }
 
 type TagRequest struct {
@@ -55,14 +92,25 @@ type TagRequest struct {
 	Color string `json:"color"`
 }
 
+type MergeTagsRequest struct {
+	SourceID int `json:"source_id"`
+	TargetID int `json:"target_id"`
+}
+
+// UpdateTagRequest changes a tag's name and/or color; omitted fields keep their current value
+type UpdateTagRequest struct {
+	Name  *string `json:"name,omitempty"`
+	Color *string `json:"color,omitempty"`
+}
+
 // Authentication Handlers
 
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
 	var req RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogSecurityEvent("INVALID_JSON_REGISTER", clientIP, err.Error())
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_REGISTER", clientIP, err.Error())
 		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
 		return
 	}
@@ -76,49 +124,63 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	emailValidation := utils.ValidateEmail(req.Email)
 	passwordValidation := utils.ValidatePassword(req.Password)
 
+	var failures []utils.ValidationResult
 	if !usernameValidation.Valid {
-		utils.LogSecurityEvent("INVALID_REGISTRATION_USERNAME", clientIP, req.Username)
-		sendJSONError(w, http.StatusBadRequest, usernameValidation.Message)
-		return
+		utils.LogSecurityEvent(r.Context(), "INVALID_REGISTRATION_USERNAME", clientIP, req.Username)
+		failures = append(failures, usernameValidation)
 	}
 
 	if !emailValidation.Valid {
-		utils.LogSecurityEvent("INVALID_REGISTRATION_EMAIL", clientIP, req.Email)
-		sendJSONError(w, http.StatusBadRequest, emailValidation.Message)
-		return
+		utils.LogSecurityEvent(r.Context(), "INVALID_REGISTRATION_EMAIL", clientIP, req.Email)
+		failures = append(failures, emailValidation)
 	}
 
 	if !passwordValidation.Valid {
-		sendJSONError(w, http.StatusBadRequest, passwordValidation.Message)
+		failures = append(failures, passwordValidation)
+	}
+
+	if len(failures) > 0 {
+		sendJSONValidationErrors(w, failures)
 		return
 	}
 
 	// Hash password securely
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := auth.HashPassword(req.Password)
 	if err != nil {
-		utils.LogSecurityEvent("PASSWORD_HASH_ERROR", clientIP, err.Error())
+		utils.LogSecurityEvent(r.Context(), "PASSWORD_HASH_ERROR", clientIP, err.Error())
 		sendJSONError(w, http.StatusInternalServerError, "Error processing password")
 		return
 	}
 
 	// Use secure database function
-	err = database.CreateUserSecure(req.Username, req.Email, string(hashedPassword))
-	if err != nil {
-		utils.LogSecurityEvent("REGISTRATION_FAILED", clientIP, fmt.Sprintf("Username: %s, Email: %s, Error: %v", req.Username, req.Email, err))
+	userID, err := database.CreateUserSecure(req.Username, req.Email, string(hashedPassword))
+	if database.IsUniqueViolation(err) {
+		utils.LogSecurityEvent(r.Context(), "REGISTRATION_DUPLICATE", clientIP, fmt.Sprintf("Username: %s, Email: %s", req.Username, req.Email))
 		sendJSONError(w, http.StatusConflict, "Username or email already exists")
 		return
 	}
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "REGISTRATION_FAILED", clientIP, fmt.Sprintf("Username: %s, Email: %s, Error: %v", req.Username, req.Email, err))
+		sendJSONError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "USER_REGISTERED", clientIP, userID, fmt.Sprintf("Username: %s, Email: %s", req.Username, req.Email))
+
+	// The account is usable without verification, so a failed send only gets logged
+	if err := sendVerificationEmail(userID, req.Email, req.Username); err != nil {
+		utils.LogUserSecurityEvent(r.Context(), "EMAIL_VERIFICATION_SEND_ERROR", clientIP, userID, err.Error())
+	}
 
-	utils.LogSecurityEvent("USER_REGISTERED", clientIP, fmt.Sprintf("Username: %s, Email: %s", req.Username, req.Email))
-	sendJSONSuccess(w, "Registration successful! Please log in.", nil)
+	sendJSONSuccess(w, "Registration successful! Please verify your email and log in.", nil)
 }
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogSecurityEvent("INVALID_JSON_LOGIN", clientIP, err.Error())
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_LOGIN", clientIP, err.Error())
 		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
 		return
 	}
@@ -128,7 +190,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Basic validation
 	if req.Username == "" || req.Password == "" {
-		utils.LogSecurityEvent("LOGIN_EMPTY_FIELDS", clientIP, fmt.Sprintf("Username: %s", req.Username))
+		utils.LogSecurityEvent(r.Context(), "LOGIN_EMPTY_FIELDS", clientIP, fmt.Sprintf("Username: %s", req.Username))
 		sendJSONError(w, http.StatusBadRequest, "Username and password are required")
 		return
 	}
@@ -136,37 +198,55 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate username format to prevent injection attempts
 	usernameValidation := utils.ValidateUsername(req.Username)
 	if !usernameValidation.Valid {
-		utils.LogSecurityEvent("LOGIN_INVALID_USERNAME", clientIP, req.Username)
+		utils.LogSecurityEvent(r.Context(), "LOGIN_INVALID_USERNAME", clientIP, req.Username)
 		sendJSONError(w, http.StatusBadRequest, "Invalid credentials")
 		return
 	}
 
+	// Accounts locked after repeated failures refuse even the right password, whatever
+	// the source IP. Unknown usernames lock the same way, so this reveals nothing.
+	lockedFor, err := database.LoginLockedFor(req.Username)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "LOGIN_LOCKOUT_CHECK_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if lockedFor > 0 {
+		utils.LogSecurityEvent(r.Context(), "LOGIN_ACCOUNT_LOCKED", clientIP, req.Username)
+		sendLoginLocked(w, lockedFor)
+		return
+	}
+
 	// Use secure database lookup
 	user, hashedPassword, err := database.GetUserByUsernameSecure(req.Username)
 	if err != nil {
-		utils.LogSecurityEvent("LOGIN_USER_NOT_FOUND", clientIP, req.Username)
-		sendJSONError(w, http.StatusUnauthorized, "Invalid credentials")
+		utils.LogSecurityEvent(r.Context(), "LOGIN_USER_NOT_FOUND", clientIP, req.Username)
+		recordFailedLogin(r.Context(), w, req.Username, clientIP)
 		return
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password)); err != nil {
-		utils.LogSecurityEvent("LOGIN_WRONG_PASSWORD", clientIP, req.Username)
-		sendJSONError(w, http.StatusUnauthorized, "Invalid credentials")
+		utils.LogUserSecurityEvent(r.Context(), "LOGIN_WRONG_PASSWORD", clientIP, user.ID, req.Username)
+		recordFailedLogin(r.Context(), w, req.Username, clientIP)
 		return
 	}
 
+	if err := database.ClearFailedLogins(req.Username); err != nil {
+		utils.LogSecurityEvent(r.Context(), "LOGIN_LOCKOUT_CLEAR_ERROR", clientIP, err.Error())
+	}
+
 	// Create secure JWT token
 	tokenString, err := auth.CreateToken(user)
 	if err != nil {
-		utils.LogSecurityEvent("TOKEN_CREATION_ERROR", clientIP, err.Error())
+		utils.LogSecurityEvent(r.Context(), "TOKEN_CREATION_ERROR", clientIP, err.Error())
 		sendJSONError(w, http.StatusInternalServerError, "Error creating session")
 		return
 	}
 
 	// Set secure cookie
 	auth.SetAuthCookie(w, tokenString)
-	utils.LogSecurityEvent("LOGIN_SUCCESS", clientIP, req.Username)
+	utils.LogUserSecurityEvent(r.Context(), "LOGIN_SUCCESS", clientIP, user.ID, req.Username)
 
 	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -181,20 +261,125 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// recordFailedLogin counts a failed login and rejects it, as locked if it was the one
+// that locked the account
+func recordFailedLogin(ctx context.Context, w http.ResponseWriter, username, clientIP string) {
+	locked, err := database.RecordFailedLogin(username)
+	if err != nil {
+		utils.LogSecurityEvent(ctx, "LOGIN_LOCKOUT_RECORD_ERROR", clientIP, err.Error())
+	}
+	if !locked {
+		sendJSONError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	utils.LogSecurityEvent(ctx, "LOGIN_ACCOUNT_LOCKED", clientIP, username)
+	lockedFor, err := database.LoginLockedFor(username)
+	if err != nil || lockedFor <= 0 {
+		lockedFor = time.Minute
+	}
+	sendLoginLocked(w, lockedFor)
+}
+
+// sendLoginLocked rejects a login to a locked account with a 429 saying when to retry
+func sendLoginLocked(w http.ResponseWriter, lockedFor time.Duration) {
+	minutes := int(math.Ceil(lockedFor.Minutes()))
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedFor.Seconds()))))
+	sendJSONError(w, http.StatusTooManyRequests,
+		fmt.Sprintf("Too many failed login attempts. This account is locked; try again in %d minute(s).", minutes))
+}
+
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
 	// Try to get user info for logging
 	if user, err := auth.GetUserFromToken(r); err == nil {
-		utils.LogSecurityEvent("USER_LOGOUT", clientIP, user.Username)
+		utils.LogSecurityEvent(r.Context(), "USER_LOGOUT", clientIP, user.Username)
 	} else {
-		utils.LogSecurityEvent("ANONYMOUS_LOGOUT", clientIP, "")
+		utils.LogSecurityEvent(r.Context(), "ANONYMOUS_LOGOUT", clientIP, "")
 	}
 
 	auth.ClearAuthCookie(w)
 	sendJSONSuccess(w, "Logged out successfully", nil)
 }
 
+// LogoutAllHandler revokes every token issued to the user, signing them out on every
+// device including this one
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	if err := database.RevokeUserSessions(user.ID); err != nil {
+		utils.LogSecurityEvent(r.Context(), "LOGOUT_ALL_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to log out other sessions")
+		return
+	}
+
+	auth.ClearAuthCookie(w)
+	utils.LogUserSecurityEvent(r.Context(), "LOGOUT_ALL", clientIP, user.ID, user.Username)
+	sendJSONSuccess(w, "Logged out everywhere", nil)
+}
+
+func DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_ACCOUNT_DELETE", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	// Require the username to be typed again so accounts are not deleted by accident
+	if strings.TrimSpace(req.ConfirmUsername) != user.Username {
+		utils.LogSecurityEvent(r.Context(), "ACCOUNT_DELETE_UNCONFIRMED", clientIP, user.Username)
+		sendJSONError(w, http.StatusBadRequest, "Username confirmation does not match")
+		return
+	}
+
+	if err := database.DeleteUserAccount(user.ID); err != nil {
+		utils.LogSecurityEvent(r.Context(), "ACCOUNT_DELETE_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	auth.ClearAuthCookie(w)
+	utils.LogUserSecurityEvent(r.Context(), "ACCOUNT_DELETED", clientIP, user.ID, fmt.Sprintf("UserID: %d, User: %s", user.ID, user.Username))
+	sendJSONSuccess(w, "Account deleted successfully", nil)
+}
+
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	tokenString, user, err := auth.RefreshToken(r)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "TOKEN_REFRESH_REJECTED", clientIP, err.Error())
+		auth.ClearAuthCookie(w)
+		sendJSONError(w, http.StatusUnauthorized, "Session expired, please log in again")
+		return
+	}
+
+	auth.SetAuthCookie(w, tokenString)
+	sendJSONSuccess(w, "Token refreshed", map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":       user.ID,
+			"username": user.Username,
+			"email":    user.Email,
+		},
+	})
+}
+
 func CheckAuthHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.GetUserFromToken(r)
 	if err != nil {
@@ -203,22 +388,97 @@ func CheckAuthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
-		"id":       user.ID,
-		"username": user.Username,
-		"email":    user.Email,
+		"id":             user.ID,
+		"username":       user.Username,
+		"email":          user.Email,
+		"is_admin":       user.IsAdmin,
+		"email_verified": user.EmailVerified,
 	})
 }
 
 // Recipe Handlers (JSON only)
 
 func GetRecipesHandler(w http.ResponseWriter, r *http.Request) {
-	recipes, err := database.GetAllRecipes()
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter, err := recipeFilterFromQuery(r)
+	if err != nil {
+		sendRequestError(w, err, "Failed to fetch recipes")
+		return
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+
+	recipes, total, err := database.QueryRecipes(filter)
 	if err != nil {
 		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
 		return
 	}
 
-	sendJSONResponse(w, http.StatusOK, recipes)
+	sendPaginatedResponse(w, recipes, total, limit, offset)
+}
+
+// recipeFilterFromQuery reads the recipe list filters from the query string:
+// q, tag (repeatable, every tag must match), cuisine, max_total_time and sort.
+// Tag values that are not valid IDs are ignored.
+func recipeFilterFromQuery(r *http.Request) (database.RecipeFilter, error) {
+	query := r.URL.Query()
+	clientIP := utils.ClientIP(r)
+	filter := database.RecipeFilter{ViewerID: viewerID(r)}
+
+	if search := strings.TrimSpace(query.Get("q")); search != "" {
+		if validation := utils.ValidateSearchQuery(search); !validation.Valid {
+			utils.LogSecurityEvent(r.Context(), "SEARCH_VALIDATION_FAILED", clientIP, fmt.Sprintf("Query: %s, Error: %s", search, validation.Message))
+			validation.Field = "q"
+			return filter, validationError{validation}
+		}
+		filter.Search = search
+	}
+
+	for _, tag := range query["tag"] {
+		tagID, err := strconv.Atoi(strings.TrimSpace(tag))
+		if err != nil || !utils.IsValidID(tagID) {
+			utils.LogSecurityEvent(r.Context(), "INVALID_TAG_FILTER", clientIP, tag)
+			continue
+		}
+		filter.TagIDs = append(filter.TagIDs, tagID)
+	}
+
+	if cuisine := strings.TrimSpace(query.Get("cuisine")); cuisine != "" {
+		if validation := utils.ValidateCuisine(cuisine); !validation.Valid {
+			utils.LogSecurityEvent(r.Context(), "INVALID_CUISINE_FILTER", clientIP, cuisine)
+			return filter, validationError{validation}
+		}
+		filter.Cuisine = cuisine
+	}
+
+	if value := strings.TrimSpace(query.Get("max_total_time")); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 1 || minutes > database.MaxRecipeTotalTime {
+			return filter, validationError{utils.ValidationResult{
+				Valid:   false,
+				Message: fmt.Sprintf("max_total_time must be a whole number of minutes between 1 and %d", database.MaxRecipeTotalTime),
+				Field:   "max_total_time",
+			}}
+		}
+		filter.MaxTotalTime = minutes
+	}
+
+	sortKey, err := parseSort(r)
+	if err != nil {
+		return filter, err
+	}
+	filter.Sort = sortKey
+
+	return filter, nil
+}
+
+func GetCuisinesHandler(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, http.StatusOK, utils.Cuisines)
 }
 
 func GetRecipeHandler(w http.ResponseWriter, r *http.Request) {
@@ -235,13 +495,113 @@ func GetRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	recipe, err := database.GetRecipeByIDSecure(id)
+	sendRecipe(w, r, id)
+}
+
+// GetRecipeBySlugHandler serves the same recipe details as GetRecipeHandler, looked up by slug
+func GetRecipeBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := database.GetRecipeIDBySlug(mux.Vars(r)["slug"], viewerID(r))
+	if err != nil {
+		sendJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+
+	sendRecipe(w, r, id)
+}
+
+// GetRandomRecipeHandler serves the details of a random public recipe, optionally one
+// carrying ?tag= (an ID) and of ?cuisine=, for "what should I cook" suggestions
+func GetRandomRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	clientIP := utils.ClientIP(r)
+
+	var tagID int
+	if tag := strings.TrimSpace(query.Get("tag")); tag != "" {
+		var err error
+		if tagID, err = strconv.Atoi(tag); err != nil || !utils.IsValidID(tagID) {
+			utils.LogSecurityEvent(r.Context(), "INVALID_TAG_FILTER", clientIP, tag)
+			sendJSONValidationError(w, utils.ValidationResult{Valid: false, Message: "Invalid tag ID", Field: "tag"})
+			return
+		}
+	}
+
+	cuisine := strings.TrimSpace(query.Get("cuisine"))
+	if cuisine != "" {
+		if validation := utils.ValidateCuisine(cuisine); !validation.Valid {
+			utils.LogSecurityEvent(r.Context(), "INVALID_CUISINE_FILTER", clientIP, cuisine)
+			sendJSONValidationError(w, validation)
+			return
+		}
+	}
+
+	id, err := database.RandomRecipeID(tagID, cuisine)
+	if errors.Is(err, database.ErrRecipeNotFound) {
+		sendJSONError(w, http.StatusNotFound, "No recipe matches the filter")
+		return
+	}
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to pick a recipe")
+		return
+	}
+
+	sendRecipe(w, r, id)
+}
+
+// RecipeSlugPage guards the frontend's /recipe/{slug} page: next serves it only when the
+// slug names a recipe the viewer may see, and anything else is a 404. Paths such as
+// /recipe/42 or /recipe/new that are not slugs are passed through to next.
+func RecipeSlugPage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug := mux.Vars(r)["slug"]
+		if !database.IsRecipeSlug(slug) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if _, err := database.GetRecipeIDBySlug(slug, viewerID(r)); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sendRecipe sends a recipe with its ratings, nutrition and steps, counting the view
+func sendRecipe(w http.ResponseWriter, r *http.Request, id int) {
+	viewer := viewerID(r)
+	recipe, err := database.GetRecipeByIDSecure(id, viewer)
 	if err != nil {
 		sendJSONError(w, http.StatusNotFound, "Recipe not found")
 		return
 	}
 
-	sendJSONResponse(w, http.StatusOK, recipe)
+	if viewer != 0 {
+		recordRecipeView(viewer, recipe.ID)
+	}
+
+	if average, count, err := database.GetAverageRating(recipe.ID); err == nil {
+		recipe.AverageRating = math.Round(average*10) / 10
+		recipe.RatingCount = count
+	}
+
+	if nutrition, err := database.GetRecipeNutrition(recipe.ID); err == nil {
+		recipe.Nutrition = nutrition
+	}
+
+	// Split for clients offering a step-by-step cooking mode
+	recipe.Steps = utils.ParseInstructionSteps(recipe.Instructions)
+
+	// The view count changes with every flush, so it is left out of the ETag; otherwise
+	// a viewed recipe would never be reported as not modified
+	version := *recipe
+	version.ViewCount = 0
+	sent := sendJSONWithETag(w, r, recipe, version)
+
+	// Revalidations answered with 304 are not new views, and owners opening their own
+	// recipe do not make it more popular
+	if sent && viewer != recipe.CreatedBy {
+		database.CountRecipeView(recipe.ID)
+	}
 }
 
 func CreateRecipeHandler(w http.ResponseWriter, r *http.Request) {
@@ -251,23 +611,44 @@ func CreateRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
+
+	if !requireVerifiedEmail(r.Context(), w, user, clientIP) {
+		return
+	}
+
+	// Clients may retry a create with the same key without creating the recipe twice
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey != "" {
+		if validation := utils.ValidateIdempotencyKey(idempotencyKey); !validation.Valid {
+			utils.LogSecurityEvent(r.Context(), "INVALID_IDEMPOTENCY_KEY", clientIP, fmt.Sprintf("User:%d", user.ID))
+			sendJSONValidationError(w, validation)
+			return
+		}
+	}
 
 	var req RecipeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogSecurityEvent("INVALID_JSON_RECIPE", clientIP, err.Error())
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_RECIPE", clientIP, err.Error())
 		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
 		return
 	}
 
 	// Validate and create recipe
-	recipeID, err := createRecipeFromRequest(req, user.ID, clientIP)
+	recipeID, created, err := createRecipeFromRequest(r.Context(), req, user.ID, clientIP, idempotencyKey)
 	if err != nil {
-		sendJSONError(w, http.StatusBadRequest, err.Error())
+		sendRequestError(w, err, "Failed to create recipe")
+		return
+	}
+
+	if !created {
+		sendJSONSuccess(w, "Recipe already created", map[string]interface{}{
+			"recipe_id": recipeID,
+		})
 		return
 	}
 
-	utils.LogSecurityEvent("RECIPE_CREATED", clientIP, fmt.Sprintf("RecipeID:%d, Title:%s, User:%s", recipeID, req.Title, user.Username))
+	utils.LogUserSecurityEvent(r.Context(), "RECIPE_CREATED", clientIP, user.ID, fmt.Sprintf("RecipeID:%d, Title:%s, User:%s", recipeID, req.Title, user.Username))
 
 	sendJSONResponse(w, http.StatusCreated, map[string]interface{}{
 		"success": true,
@@ -285,7 +666,7 @@ func UpdateRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
 	vars := mux.Vars(r)
 	idStr, exists := vars["id"]
@@ -296,7 +677,7 @@ func UpdateRecipeHandler(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil || !utils.IsValidID(id) {
-		utils.LogSecurityEvent("INVALID_RECIPE_ID_API", clientIP, idStr)
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_API", clientIP, idStr)
 		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
@@ -304,26 +685,41 @@ func UpdateRecipeHandler(w http.ResponseWriter, r *http.Request) {
 	// Verify ownership
 	owns, err := database.UserOwnsRecipe(id, user.ID)
 	if err != nil || !owns {
-		utils.LogSecurityEvent("UNAUTHORIZED_RECIPE_UPDATE_API", clientIP, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, id))
+		utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_RECIPE_UPDATE_API", clientIP, user.ID, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, id))
 		sendJSONError(w, http.StatusForbidden, "Access denied")
 		return
 	}
 
 	var req RecipeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogSecurityEvent("INVALID_JSON_RECIPE_UPDATE", clientIP, err.Error())
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_RECIPE_UPDATE", clientIP, err.Error())
 		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
 		return
 	}
 
+	// Drafts are only published through the publish endpoint
+	current, err := database.GetRecipeByID(id)
+	if err != nil {
+		sendJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	req.Status = current.Status
+	if req.IsPublic == nil {
+		req.IsPublic = &current.IsPublic
+	}
+
 	// Update recipe
-	err = updateRecipeFromRequest(req, id, user.ID, clientIP)
+	err = updateRecipeFromRequest(r.Context(), req, id, user.ID, clientIP)
 	if err != nil {
-		sendJSONError(w, http.StatusBadRequest, err.Error())
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		sendRequestError(w, err, "Failed to update recipe")
 		return
 	}
 
-	utils.LogSecurityEvent("RECIPE_UPDATED_API", clientIP, fmt.Sprintf("RecipeID:%d, User:%s", id, user.Username))
+	utils.LogUserSecurityEvent(r.Context(), "RECIPE_UPDATED_API", clientIP, user.ID, fmt.Sprintf("RecipeID:%d, User:%s", id, user.Username))
 	sendJSONSuccess(w, "Recipe updated successfully", nil)
 }
 
@@ -334,7 +730,7 @@ func DeleteRecipeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
 	vars := mux.Vars(r)
 	idStr, exists := vars["id"]
@@ -345,152 +741,515 @@ func DeleteRecipeHandler(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil || !utils.IsValidID(id) {
-		utils.LogSecurityEvent("INVALID_RECIPE_ID_DELETE", clientIP, idStr)
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_DELETE", clientIP, idStr)
 		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
 		return
 	}
 
-	// Get recipe images for cleanup (before deletion)
-	images := database.GetRecipeImages(id)
-
-	// Use secure delete function
+	// Recipes are moved to the recycle bin; image files are kept until the recipe is purged
 	err = database.DeleteRecipeSecure(id, user.ID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "access denied") {
-			utils.LogSecurityEvent("UNAUTHORIZED_RECIPE_DELETE", clientIP, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, id))
+			utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_RECIPE_DELETE", clientIP, user.ID, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, id))
 			sendJSONError(w, http.StatusForbidden, "Recipe not found or access denied")
 		} else {
-			utils.LogSecurityEvent("RECIPE_DELETE_ERROR", clientIP, err.Error())
+			utils.LogSecurityEvent(r.Context(), "RECIPE_DELETE_ERROR", clientIP, err.Error())
 			sendJSONError(w, http.StatusInternalServerError, "Failed to delete recipe")
 		}
 		return
 	}
 
-	// Clean up image files
-	for _, img := range images {
-		imagePath := filepath.Join("uploads", img.Filename)
-		if err := os.Remove(imagePath); err != nil {
-			utils.LogSecurityEvent("IMAGE_CLEANUP_ERROR", clientIP, fmt.Sprintf("File: %s, Error: %v", imagePath, err))
-		}
-	}
-
-	utils.LogSecurityEvent("RECIPE_DELETED", clientIP, fmt.Sprintf("RecipeID:%d, User:%s", id, user.Username))
-	sendJSONSuccess(w, "Recipe deleted successfully", nil)
+	utils.LogUserSecurityEvent(r.Context(), "RECIPE_DELETED", clientIP, user.ID, fmt.Sprintf("RecipeID:%d, User:%s", id, user.Username))
+	sendJSONSuccess(w, "Recipe moved to trash", nil)
 }
 
-// Image Handlers (Form-data only)
+// maxBulkDeleteRecipes caps how many recipes can be deleted in one request
+const maxBulkDeleteRecipes = 100
 
-func UploadRecipeImagesHandler(w http.ResponseWriter, r *http.Request) {
+func BulkDeleteRecipesHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.GetUserFromToken(r)
 	if err != nil {
 		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
 		return
 	}
 
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
-	// Get recipe ID from URL
-	vars := mux.Vars(r)
-	idStr, exists := vars["id"]
-	if !exists {
-		sendJSONError(w, http.StatusBadRequest, "Recipe ID is required")
+	var ids []int
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_BULK_DELETE", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Expected a JSON array of recipe IDs")
 		return
 	}
 
-	recipeID, err := strconv.Atoi(idStr)
-	if err != nil || !utils.IsValidID(recipeID) {
-		utils.LogSecurityEvent("INVALID_RECIPE_ID_IMAGE_UPLOAD", clientIP, idStr)
-		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+	if len(ids) == 0 {
+		sendJSONError(w, http.StatusBadRequest, "At least one recipe ID is required")
 		return
 	}
-
-	// Verify ownership
-	owns, err := database.UserOwnsRecipe(recipeID, user.ID)
-	if err != nil || !owns {
-		utils.LogSecurityEvent("UNAUTHORIZED_IMAGE_UPLOAD", clientIP, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, recipeID))
-		sendJSONError(w, http.StatusForbidden, "Access denied")
+	if len(ids) > maxBulkDeleteRecipes {
+		sendJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d recipes can be deleted at once", maxBulkDeleteRecipes))
 		return
 	}
 
-	// Parse multipart form with 32MB max memory
-	err = r.ParseMultipartForm(32 << 20)
-	if err != nil {
-		utils.LogSecurityEvent("MULTIPART_PARSE_ERROR", clientIP, err.Error())
-		sendJSONError(w, http.StatusBadRequest, "Invalid form data")
-		return
+	// Each recipe is reported once, even if it was listed more than once
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
 	}
 
-	// Handle file uploads
-	files := r.MultipartForm.File["images"]
-	if len(files) == 0 {
-		sendJSONError(w, http.StatusBadRequest, "No images provided")
+	// Like single deletes, recipes go to the recycle bin and their images are kept until purged
+	results, err := database.DeleteRecipesBulk(unique, user.ID)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "RECIPE_BULK_DELETE_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to delete recipes")
 		return
 	}
 
-	var uploadedImages []map[string]interface{}
-
-	for i, fileHeader := range files {
-		if i >= 5 { // Limit to 5 images
-			break
-		}
-
-		// Validate file
-		validation := utils.ValidateFileUpload(fileHeader.Filename, fileHeader.Size)
-		if !validation.Valid {
-			utils.LogSecurityEvent("INVALID_FILE_UPLOAD", clientIP, validation.Message)
-			continue
+	deleted := 0
+	for _, result := range results {
+		switch result.Status {
+		case database.BulkDeleteDeleted:
+			deleted++
+			utils.LogUserSecurityEvent(r.Context(), "RECIPE_DELETED", clientIP, user.ID, fmt.Sprintf("RecipeID:%d, User:%s", result.ID, user.Username))
+		case database.BulkDeleteForbidden:
+			utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_RECIPE_DELETE", clientIP, user.ID, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, result.ID))
 		}
+	}
 
-		file, err := fileHeader.Open()
-		if err != nil {
-			continue
-		}
-		defer file.Close()
+	sendJSONSuccess(w, fmt.Sprintf("Moved %d recipe(s) to trash", deleted), map[string]interface{}{
+		"results": results,
+	})
+}
 
-		// Save file
-		filename, err := utils.SaveUploadedFile(file, fileHeader)
-		if err != nil {
-			utils.LogSecurityEvent("FILE_SAVE_ERROR", clientIP, err.Error())
-			continue
-		}
+// maxBatchRecipes caps how many recipes can be fetched in one request
+const maxBatchRecipes = 50
 
-		// Get caption from form data
-		caption := ""
-		if captions := r.MultipartForm.Value[fmt.Sprintf("caption_%d", i)]; len(captions) > 0 {
-			caption = strings.TrimSpace(captions[0])
-			if len(caption) > 200 {
-				caption = caption[:200]
-			}
-		}
+// GetRecipesBatchHandler returns the recipes for a JSON array of IDs in one response, in
+// the order they were asked for. IDs of recipes the caller cannot see are listed in missing.
+func GetRecipesBatchHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
 
-		// Save to database
-		result, err := database.DB.Exec(
-			"INSERT INTO recipe_images (recipe_id, filename, caption, display_order) VALUES (?, ?, ?, ?)",
-			recipeID, filename, caption, i,
-		)
-		if err != nil {
-			// Remove file if database insert fails
-			os.Remove(filepath.Join("uploads", filename))
-			continue
-		}
+	var ids []int
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_RECIPE_BATCH", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Expected a JSON array of recipe IDs")
+		return
+	}
 
-		imageID, _ := result.LastInsertId()
-		uploadedImages = append(uploadedImages, map[string]interface{}{
-			"id":       imageID,
-			"filename": filename,
-			"caption":  caption,
-			"order":    i,
-		})
+	if len(ids) == 0 {
+		sendJSONError(w, http.StatusBadRequest, "At least one recipe ID is required")
+		return
+	}
+	if len(ids) > maxBatchRecipes {
+		sendJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d recipes can be fetched at once", maxBatchRecipes))
+		return
 	}
 
-	utils.LogSecurityEvent("IMAGES_UPLOADED", clientIP,
-		fmt.Sprintf("RecipeID:%d, ImagesCount:%d, User:%s", recipeID, len(uploadedImages), user.Username))
+	recipes, err := database.GetRecipesByIDs(ids, viewerID(r))
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "RECIPE_BATCH_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
+		return
+	}
 
+	// Each missing recipe is reported once, even if it was listed more than once
+	found := make(map[int]bool, len(recipes))
+	for _, recipe := range recipes {
+		found[recipe.ID] = true
+	}
+	missing := []int{}
+	for _, id := range ids {
+		if !found[id] {
+			found[id] = true
+			missing = append(missing, id)
+		}
+	}
+
+	if recipes == nil {
+		recipes = []models.Recipe{}
+	}
+
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"results": recipes,
+		"missing": missing,
+	})
+}
+
+func SetVisibilityHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	id, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_VISIBILITY", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	var req VisibilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_VISIBILITY", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	if err := database.SetRecipeVisibility(id, user.ID, req.IsPublic); err != nil {
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_RECIPE_VISIBILITY", clientIP, user.ID, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, id))
+			sendJSONError(w, http.StatusForbidden, "Recipe not found or access denied")
+		} else {
+			utils.LogSecurityEvent(r.Context(), "RECIPE_VISIBILITY_ERROR", clientIP, err.Error())
+			sendJSONError(w, http.StatusInternalServerError, "Failed to update visibility")
+		}
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "RECIPE_VISIBILITY_CHANGED", clientIP, user.ID, fmt.Sprintf("RecipeID:%d, Public:%t, User:%s", id, req.IsPublic, user.Username))
+	sendJSONSuccess(w, "Recipe visibility updated", map[string]interface{}{
+		"recipe_id": id,
+		"is_public": req.IsPublic,
+	})
+}
+
+func PublishRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	id, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_PUBLISH", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	if err := database.PublishRecipe(id, user.ID); err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecipeNotFound):
+			utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_RECIPE_PUBLISH", clientIP, user.ID, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, id))
+			sendJSONError(w, http.StatusForbidden, "Recipe not found or access denied")
+		case errors.Is(err, database.ErrRecipeAlreadyPublished):
+			sendJSONError(w, http.StatusConflict, "Recipe is already published")
+		case errors.Is(err, database.ErrRecipeIncomplete):
+			sendJSONError(w, http.StatusBadRequest, err.Error())
+		default:
+			utils.LogSecurityEvent(r.Context(), "RECIPE_PUBLISH_ERROR", clientIP, err.Error())
+			sendJSONError(w, http.StatusInternalServerError, "Failed to publish recipe")
+		}
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "RECIPE_PUBLISHED", clientIP, user.ID, fmt.Sprintf("RecipeID:%d, User:%s", id, user.Username))
+	sendJSONSuccess(w, "Recipe published", map[string]interface{}{
+		"recipe_id": id,
+		"status":    models.RecipeStatusPublished,
+	})
+}
+
+func CloneRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	if !requireVerifiedEmail(r.Context(), w, user, clientIP) {
+		return
+	}
+
+	id, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_CLONE", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	newID, err := database.CloneRecipe(id, user.ID)
+	if err != nil {
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Recipe not found")
+		} else {
+			utils.LogSecurityEvent(r.Context(), "RECIPE_CLONE_ERROR", clientIP, err.Error())
+			sendJSONError(w, http.StatusInternalServerError, "Failed to copy recipe")
+		}
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "RECIPE_CLONED", clientIP, user.ID, fmt.Sprintf("RecipeID: %d, NewRecipeID: %d, User: %s", id, newID, user.Username))
 	sendJSONResponse(w, http.StatusCreated, map[string]interface{}{
 		"success": true,
-		"message": fmt.Sprintf("Uploaded %d image(s)", len(uploadedImages)),
+		"message": "Recipe copied successfully",
 		"data": map[string]interface{}{
-			"images": uploadedImages,
+			"recipe_id": newID,
+		},
+	})
+}
+
+func GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	recipes, err := database.GetDeletedRecipesByUser(user.ID)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "TRASH_FETCH_ERROR", utils.ClientIP(r), err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch deleted recipes")
+		return
+	}
+
+	if recipes == nil {
+		recipes = []models.Recipe{}
+	}
+
+	sendJSONResponse(w, http.StatusOK, recipes)
+}
+
+func RestoreRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	id, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_RESTORE", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	if err := database.RestoreRecipe(id, user.ID); err != nil {
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_RECIPE_RESTORE", clientIP, user.ID, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, id))
+			sendJSONError(w, http.StatusNotFound, "Deleted recipe not found or access denied")
+		} else {
+			utils.LogSecurityEvent(r.Context(), "RECIPE_RESTORE_ERROR", clientIP, err.Error())
+			sendJSONError(w, http.StatusInternalServerError, "Failed to restore recipe")
+		}
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "RECIPE_RESTORED", clientIP, user.ID, fmt.Sprintf("RecipeID:%d, User:%s", id, user.Username))
+	sendJSONSuccess(w, "Recipe restored successfully", nil)
+}
+
+func RateRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_RATING", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	var req RatingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_RATING", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	ratingValidation := utils.ValidateNumericInput(req.Rating, 1, 5, "Rating")
+	if !ratingValidation.Valid {
+		sendJSONValidationError(w, ratingValidation)
+		return
+	}
+
+	if err := database.SetRating(recipeID, user.ID, req.Rating); err != nil {
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "RATING_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to save rating")
+		return
+	}
+
+	average, count, err := database.GetAverageRating(recipeID)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch rating")
+		return
+	}
+
+	utils.LogSecurityEvent(r.Context(), "RECIPE_RATED", clientIP, fmt.Sprintf("RecipeID:%d, Rating:%d, User:%s", recipeID, req.Rating, user.Username))
+	sendJSONSuccess(w, "Rating saved", map[string]interface{}{
+		"rating":         req.Rating,
+		"average_rating": math.Round(average*10) / 10,
+		"rating_count":   count,
+	})
+}
+
+// Image Handlers (Form-data only)
+
+func UploadRecipeImagesHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	// Get recipe ID from URL
+	vars := mux.Vars(r)
+	idStr, exists := vars["id"]
+	if !exists {
+		sendJSONError(w, http.StatusBadRequest, "Recipe ID is required")
+		return
+	}
+
+	recipeID, err := strconv.Atoi(idStr)
+	if err != nil || !utils.IsValidID(recipeID) {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_IMAGE_UPLOAD", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	// Verify ownership
+	owns, err := database.UserOwnsRecipe(recipeID, user.ID)
+	if err != nil || !owns {
+		utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_IMAGE_UPLOAD", clientIP, user.ID, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, recipeID))
+		sendJSONError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	// Parse multipart form with 32MB max memory
+	err = r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "MULTIPART_PARSE_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid form data")
+		return
+	}
+
+	// Handle file uploads
+	files := r.MultipartForm.File["images"]
+	if len(files) == 0 {
+		sendJSONError(w, http.StatusBadRequest, "No images provided")
+		return
+	}
+
+	existingCount, err := database.CountRecipeImages(recipeID)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Error checking existing images")
+		return
+	}
+	remaining := maxImagesPerRecipe - existingCount
+	if remaining <= 0 {
+		sendJSONError(w, http.StatusConflict, fmt.Sprintf("Recipe already has the maximum of %d images", maxImagesPerRecipe))
+		return
+	}
+
+	// Files that fail validation are reported back rather than silently dropped
+	var pending []models.RecipeImage
+	rejected := []map[string]string{}
+	skipped := 0
+
+	for i, fileHeader := range files {
+		if len(pending) >= remaining {
+			skipped = len(files) - i
+			break
+		}
+
+		// Validate file
+		validation := utils.ValidateFileUpload(fileHeader.Filename, fileHeader.Size)
+		if !validation.Valid {
+			utils.LogSecurityEvent(r.Context(), "INVALID_FILE_UPLOAD", clientIP, validation.Message)
+			rejected = append(rejected, map[string]string{"filename": fileHeader.Filename, "error": validation.Message})
+			continue
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			rejected = append(rejected, map[string]string{"filename": fileHeader.Filename, "error": "Could not read the file"})
+			continue
+		}
+
+		// Save file
+		filename, err := utils.SaveUploadedFile(file, fileHeader)
+		file.Close()
+		if err != nil {
+			utils.LogSecurityEvent(r.Context(), "FILE_SAVE_ERROR", clientIP, err.Error())
+			rejected = append(rejected, map[string]string{"filename": fileHeader.Filename, "error": "File is not a valid image of its type"})
+			continue
+		}
+
+		// Get caption from form data
+		caption := ""
+		if captions := r.MultipartForm.Value[fmt.Sprintf("caption_%d", i)]; len(captions) > 0 {
+			caption = strings.TrimSpace(captions[0])
+			if len(caption) > 200 {
+				caption = caption[:200]
+			}
+		}
+
+		pending = append(pending, models.RecipeImage{Filename: filename, Caption: caption, Order: i})
+	}
+
+	uploadedImages := []models.RecipeImage{}
+	if len(pending) > 0 {
+		added, err := database.AddRecipeImages(recipeID, maxImagesPerRecipe, pending)
+		if err != nil {
+			// Remove the files if the database insert fails
+			for _, img := range pending {
+				os.Remove(filepath.Join("uploads", img.Filename))
+			}
+			sendJSONError(w, http.StatusInternalServerError, "Error saving images")
+			return
+		}
+		uploadedImages = added
+
+		// Another upload filled the recipe in the meantime
+		for _, img := range pending[len(added):] {
+			os.Remove(filepath.Join("uploads", img.Filename))
+		}
+		skipped += len(pending) - len(added)
+	}
+
+	utils.LogSecurityEvent(r.Context(), "IMAGES_UPLOADED", clientIP,
+		fmt.Sprintf("RecipeID:%d, ImagesCount:%d, Skipped:%d, Rejected:%d, User:%s", recipeID, len(uploadedImages), skipped, len(rejected), user.Username))
+
+	message := fmt.Sprintf("Uploaded %d image(s)", len(uploadedImages))
+	if skipped > 0 {
+		message += fmt.Sprintf(", skipped %d over the limit of %d per recipe", skipped, maxImagesPerRecipe)
+	}
+	if len(rejected) > 0 {
+		message += fmt.Sprintf(", rejected %d invalid file(s)", len(rejected))
+	}
+
+	sendJSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"message": message,
+		"data": map[string]interface{}{
+			"images":     uploadedImages,
+			"skipped":    skipped,
+			"rejected":   rejected,
+			"max_images": maxImagesPerRecipe,
 		},
 	})
 }
@@ -502,7 +1261,7 @@ func DeleteImageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
 	vars := mux.Vars(r)
 	idStr, exists := vars["id"]
@@ -513,7 +1272,7 @@ func DeleteImageHandler(w http.ResponseWriter, r *http.Request) {
 
 	imageID, err := strconv.Atoi(idStr)
 	if err != nil || !utils.IsValidID(imageID) {
-		utils.LogSecurityEvent("INVALID_IMAGE_ID_DELETE", clientIP, idStr)
+		utils.LogSecurityEvent(r.Context(), "INVALID_IMAGE_ID_DELETE", clientIP, idStr)
 		sendJSONError(w, http.StatusBadRequest, "Invalid image ID")
 		return
 	}
@@ -529,85 +1288,481 @@ func DeleteImageHandler(w http.ResponseWriter, r *http.Request) {
 	`, imageID).Scan(&recipeID, &createdBy, &filename)
 
 	if err != nil {
-		utils.LogSecurityEvent("IMAGE_NOT_FOUND", clientIP, fmt.Sprintf("ImageID: %d", imageID))
+		utils.LogSecurityEvent(r.Context(), "IMAGE_NOT_FOUND", clientIP, fmt.Sprintf("ImageID: %d", imageID))
 		sendJSONError(w, http.StatusNotFound, "Image not found")
 		return
 	}
-
-	if createdBy != user.ID {
-		utils.LogSecurityEvent("UNAUTHORIZED_IMAGE_DELETE", clientIP, fmt.Sprintf("UserID: %d, ImageID: %d, Owner: %d", user.ID, imageID, createdBy))
-		sendJSONError(w, http.StatusForbidden, "Access denied")
+
+	if createdBy != user.ID {
+		utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_IMAGE_DELETE", clientIP, user.ID, fmt.Sprintf("UserID: %d, ImageID: %d, Owner: %d", user.ID, imageID, createdBy))
+		sendJSONError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	// Delete file from filesystem
+	imagePath := filepath.Join("uploads", filename)
+	if err := os.Remove(imagePath); err != nil {
+		utils.LogSecurityEvent(r.Context(), "IMAGE_FILE_DELETE_ERROR", clientIP, fmt.Sprintf("File: %s, Error: %v", imagePath, err))
+		// Continue with database deletion even if file deletion fails
+	}
+
+	// Delete from database
+	_, err = database.DB.Exec("DELETE FROM recipe_images WHERE id = ?", imageID)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "IMAGE_DB_DELETE_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to delete image")
+		return
+	}
+
+	database.TouchRecipe(recipeID)
+	utils.LogUserSecurityEvent(r.Context(), "IMAGE_DELETED", clientIP, user.ID, fmt.Sprintf("ImageID: %d, Filename: %s, User: %s", imageID, filename, user.Username))
+	sendJSONSuccess(w, "Image deleted successfully", nil)
+}
+
+func ReorderImagesHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_IMAGE_ORDER", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	owns, err := database.UserOwnsRecipe(recipeID, user.ID)
+	if err != nil {
+		sendJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	if !owns {
+		utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_IMAGE_ORDER", clientIP, user.ID, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, recipeID))
+		sendJSONError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var imageIDs []int
+	if err := json.NewDecoder(r.Body).Decode(&imageIDs); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_IMAGE_ORDER", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Expected a JSON array of image IDs")
+		return
+	}
+
+	if err := database.ReorderRecipeImages(recipeID, imageIDs); err != nil {
+		if errors.Is(err, database.ErrImageOrderMismatch) {
+			utils.LogSecurityEvent(r.Context(), "INVALID_IMAGE_ORDER", clientIP, fmt.Sprintf("RecipeID: %d, IDs: %v", recipeID, imageIDs))
+			sendJSONError(w, http.StatusBadRequest, "Image IDs must match the recipe's images exactly")
+		} else {
+			utils.LogSecurityEvent(r.Context(), "IMAGE_ORDER_ERROR", clientIP, err.Error())
+			sendJSONError(w, http.StatusInternalServerError, "Failed to reorder images")
+		}
+		return
+	}
+
+	database.TouchRecipe(recipeID)
+	utils.LogSecurityEvent(r.Context(), "IMAGES_REORDERED", clientIP, fmt.Sprintf("RecipeID: %d, User: %s", recipeID, user.Username))
+	sendJSONSuccess(w, "Images reordered successfully", map[string]interface{}{
+		"images": database.GetRecipeImages(recipeID),
+	})
+}
+
+func SetPrimaryImageHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	imageID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_IMAGE_ID_PRIMARY", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid image ID")
+		return
+	}
+
+	// Check if user owns the recipe containing this image
+	var recipeID, createdBy int
+	err = database.DB.QueryRow(`
+		SELECT ri.recipe_id, r.created_by
+		FROM recipe_images ri
+		JOIN recipes r ON ri.recipe_id = r.id
+		WHERE ri.id = ? AND r.deleted_at IS NULL
+	`, imageID).Scan(&recipeID, &createdBy)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "IMAGE_NOT_FOUND", clientIP, fmt.Sprintf("ImageID: %d", imageID))
+		sendJSONError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	if createdBy != user.ID {
+		utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_IMAGE_PRIMARY", clientIP, user.ID, fmt.Sprintf("UserID: %d, ImageID: %d, Owner: %d", user.ID, imageID, createdBy))
+		sendJSONError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	if err := database.SetPrimaryImage(recipeID, imageID); err != nil {
+		utils.LogSecurityEvent(r.Context(), "IMAGE_PRIMARY_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to set cover image")
+		return
+	}
+
+	database.TouchRecipe(recipeID)
+	utils.LogSecurityEvent(r.Context(), "IMAGE_PRIMARY_SET", clientIP, fmt.Sprintf("ImageID: %d, RecipeID: %d, User: %s", imageID, recipeID, user.Username))
+	sendJSONSuccess(w, "Cover image updated successfully", nil)
+}
+
+// maxImageCaptionLength matches the recipe_images caption CHECK constraint
+const maxImageCaptionLength = 200
+
+type ImageCaptionRequest struct {
+	Caption string `json:"caption"`
+}
+
+func UpdateImageCaptionHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	imageID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_IMAGE_ID_CAPTION", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid image ID")
+		return
+	}
+
+	var req ImageCaptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_IMAGE_CAPTION", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	caption := utils.SanitizeInput(req.Caption)
+	if utf8.RuneCountInString(caption) > maxImageCaptionLength {
+		sendJSONError(w, http.StatusBadRequest, fmt.Sprintf("Caption is too long (maximum %d characters)", maxImageCaptionLength))
+		return
+	}
+
+	// Check if user owns the recipe containing this image
+	var recipeID, createdBy int
+	err = database.DB.QueryRow(`
+		SELECT ri.recipe_id, r.created_by
+		FROM recipe_images ri
+		JOIN recipes r ON ri.recipe_id = r.id
+		WHERE ri.id = ? AND r.deleted_at IS NULL
+	`, imageID).Scan(&recipeID, &createdBy)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "IMAGE_NOT_FOUND", clientIP, fmt.Sprintf("ImageID: %d", imageID))
+		sendJSONError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	if createdBy != user.ID {
+		utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_IMAGE_CAPTION", clientIP, user.ID, fmt.Sprintf("UserID: %d, ImageID: %d, Owner: %d", user.ID, imageID, createdBy))
+		sendJSONError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	image, err := database.UpdateImageCaption(imageID, caption)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "IMAGE_CAPTION_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to update caption")
+		return
+	}
+
+	database.TouchRecipe(recipeID)
+	utils.LogSecurityEvent(r.Context(), "IMAGE_CAPTION_UPDATED", clientIP, fmt.Sprintf("ImageID: %d, RecipeID: %d, User: %s", imageID, recipeID, user.Username))
+	sendJSONSuccess(w, "Caption updated successfully", image)
+}
+
+// Ingredient Handlers
+
+func GetIngredientsHandler(w http.ResponseWriter, r *http.Request) {
+	category := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("category")))
+	if validation := utils.ValidateIngredientCategory(category); !validation.Valid {
+		sendJSONValidationError(w, validation)
+		return
+	}
+
+	ingredients, err := database.GetAllIngredients(category)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch ingredients")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, ingredients)
+}
+
+// GetIngredientHandler returns an ingredient with a page of the recipes that use it
+func GetIngredientHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	id, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_INGREDIENT_ID", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid ingredient ID")
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ingredient, err := database.GetIngredientByID(id)
+	if err != nil {
+		if errors.Is(err, database.ErrIngredientNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Ingredient not found")
+			return
+		}
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch ingredient")
+		return
+	}
+
+	recipes, total, err := database.GetRecipesUsingIngredient(id, viewerID(r), limit, offset)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"ingredient": ingredient,
+		"recipes": map[string]interface{}{
+			"results":  recipes,
+			"total":    total,
+			"page":     offset/limit + 1,
+			"per_page": limit,
+		},
+	})
+}
+
+// GetIngredientStatsHandler lists a page of the ingredients with how many recipes use
+// each, most used first, so that unused ones can be found and cleaned up
+func GetIngredientStatsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ingredients, total, err := database.GetIngredientUsageCounts(limit, offset)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch ingredient statistics")
+		return
+	}
+
+	if ingredients == nil {
+		ingredients = []models.IngredientUsage{}
+	}
+
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"results":  ingredients,
+		"total":    total,
+		"page":     offset/limit + 1,
+		"per_page": limit,
+	})
+}
+
+func CreateIngredientHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	var req IngredientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_INGREDIENT", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Category = strings.ToLower(strings.TrimSpace(req.Category))
+
+	var failures []utils.ValidationResult
+
+	// Validate ingredient name
+	nameValidation := utils.ValidateIngredientName(req.Name)
+	if !nameValidation.Valid {
+		utils.LogSecurityEvent(r.Context(), "INGREDIENT_VALIDATION_FAILED", clientIP, fmt.Sprintf("Name: %s, Error: %s", req.Name, nameValidation.Message))
+		failures = append(failures, nameValidation)
+	}
+
+	categoryValidation := utils.ValidateIngredientCategory(req.Category)
+	if !categoryValidation.Valid {
+		failures = append(failures, categoryValidation)
+	}
+
+	if len(failures) > 0 {
+		sendJSONValidationErrors(w, failures)
+		return
+	}
+	if req.Category == "" {
+		req.Category = utils.DefaultIngredientCategory
+	}
+
+	// Use secure database function
+	err = database.CreateIngredientSecure(req.Name, req.Category)
+	if database.IsUniqueViolation(err) {
+		utils.LogSecurityEvent(r.Context(), "INGREDIENT_DUPLICATE", clientIP, fmt.Sprintf("Name: %s", req.Name))
+		sendJSONError(w, http.StatusConflict, "Ingredient already exists")
+		return
+	}
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "INGREDIENT_INSERT_ERROR", clientIP, fmt.Sprintf("Name: %s, Error: %v", req.Name, err))
+		sendJSONError(w, http.StatusInternalServerError, "Failed to create ingredient")
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "INGREDIENT_CREATED", clientIP, user.ID, fmt.Sprintf("Name: %s, User: %s", req.Name, user.Username))
+	sendJSONSuccess(w, "Ingredient created successfully", map[string]interface{}{
+		"name":     req.Name,
+		"category": req.Category,
+	})
+}
+
+// maxBulkIngredients caps how many ingredients can be created in one request
+const maxBulkIngredients = 100
+
+// InvalidIngredientName is a name rejected by a bulk ingredient create, with the reason
+type InvalidIngredientName struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// CreateIngredientsBulkHandler creates every valid, new ingredient in a JSON array of names
+// and reports which were created, which already existed and which were rejected
+func CreateIngredientsBulkHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	var names []string
+	if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_INGREDIENT", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Expected a JSON array of ingredient names")
+		return
+	}
+
+	if len(names) == 0 {
+		sendJSONError(w, http.StatusBadRequest, "At least one ingredient name is required")
+		return
+	}
+	if len(names) > maxBulkIngredients {
+		sendJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d ingredients can be created at once", maxBulkIngredients))
 		return
 	}
 
-	// Delete file from filesystem
-	imagePath := filepath.Join("uploads", filename)
-	if err := os.Remove(imagePath); err != nil {
-		utils.LogSecurityEvent("IMAGE_FILE_DELETE_ERROR", clientIP, fmt.Sprintf("File: %s, Error: %v", imagePath, err))
-		// Continue with database deletion even if file deletion fails
-	}
+	// Each name is reported once, even if it was listed more than once
+	seen := make(map[string]bool, len(names))
+	valid := make([]string, 0, len(names))
+	invalid := make([]InvalidIngredientName, 0)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
 
-	// Delete from database
-	_, err = database.DB.Exec("DELETE FROM recipe_images WHERE id = ?", imageID)
-	if err != nil {
-		utils.LogSecurityEvent("IMAGE_DB_DELETE_ERROR", clientIP, err.Error())
-		sendJSONError(w, http.StatusInternalServerError, "Failed to delete image")
-		return
+		if validation := utils.ValidateIngredientName(name); !validation.Valid {
+			invalid = append(invalid, InvalidIngredientName{Name: name, Error: validation.Message})
+			continue
+		}
+		valid = append(valid, name)
 	}
 
-	utils.LogSecurityEvent("IMAGE_DELETED", clientIP, fmt.Sprintf("ImageID: %d, Filename: %s, User: %s", imageID, filename, user.Username))
-	sendJSONSuccess(w, "Image deleted successfully", nil)
-}
+	if len(invalid) > 0 {
+		utils.LogSecurityEvent(r.Context(), "INGREDIENT_VALIDATION_FAILED", clientIP, fmt.Sprintf("Bulk create rejected %d name(s)", len(invalid)))
+	}
 
-// Ingredient Handlers
+	created, duplicates := []string{}, []string{}
+	if len(valid) > 0 {
+		created, duplicates, err = database.CreateIngredientsBulk(valid)
+		if err != nil {
+			utils.LogSecurityEvent(r.Context(), "INGREDIENT_INSERT_ERROR", clientIP, fmt.Sprintf("Bulk create: %v", err))
+			sendJSONError(w, http.StatusInternalServerError, "Failed to create ingredients")
+			return
+		}
+	}
 
-func GetIngredientsHandler(w http.ResponseWriter, r *http.Request) {
-	ingredients, err := database.GetAllIngredients()
-	if err != nil {
-		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch ingredients")
-		return
+	for _, name := range created {
+		utils.LogUserSecurityEvent(r.Context(), "INGREDIENT_CREATED", clientIP, user.ID, fmt.Sprintf("Name: %s, User: %s", name, user.Username))
 	}
 
-	sendJSONResponse(w, http.StatusOK, ingredients)
+	sendJSONSuccess(w, fmt.Sprintf("Created %d ingredient(s)", len(created)), map[string]interface{}{
+		"created":    created,
+		"duplicates": duplicates,
+		"invalid":    invalid,
+	})
 }
 
-func CreateIngredientHandler(w http.ResponseWriter, r *http.Request) {
+// GetIngredientCategoriesHandler lists the categories an ingredient can have
+func GetIngredientCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, http.StatusOK, utils.IngredientCategories)
+}
+
+// MergeIngredientsHandler folds a duplicate ingredient into another one, moving its recipes over
+func MergeIngredientsHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.GetUserFromToken(r)
 	if err != nil {
 		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
 		return
 	}
 
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
-	var req IngredientRequest
+	var req MergeIngredientsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogSecurityEvent("INVALID_JSON_INGREDIENT", clientIP, err.Error())
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_INGREDIENT_MERGE", clientIP, err.Error())
 		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
 		return
 	}
 
-	req.Name = strings.TrimSpace(req.Name)
+	if !utils.IsValidID(req.SourceID) || !utils.IsValidID(req.TargetID) {
+		utils.LogSecurityEvent(r.Context(), "INVALID_INGREDIENT_ID_MERGE", clientIP, fmt.Sprintf("Source: %d, Target: %d", req.SourceID, req.TargetID))
+		sendJSONError(w, http.StatusBadRequest, "Invalid ingredient ID")
+		return
+	}
 
-	// Validate ingredient name
-	nameValidation := utils.ValidateIngredientName(req.Name)
-	if !nameValidation.Valid {
-		utils.LogSecurityEvent("INGREDIENT_VALIDATION_FAILED", clientIP, fmt.Sprintf("Name: %s, Error: %s", req.Name, nameValidation.Message))
-		sendJSONError(w, http.StatusBadRequest, nameValidation.Message)
+	if req.SourceID == req.TargetID {
+		sendJSONError(w, http.StatusBadRequest, "Cannot merge an ingredient into itself")
 		return
 	}
 
-	// Use secure database function
-	err = database.CreateIngredientSecure(req.Name)
+	affected, err := database.MergeIngredients(req.SourceID, req.TargetID)
 	if err != nil {
-		utils.LogSecurityEvent("INGREDIENT_INSERT_ERROR", clientIP, fmt.Sprintf("Name: %s, Error: %v", req.Name, err))
-		sendJSONError(w, http.StatusConflict, "Ingredient already exists or database error")
+		if errors.Is(err, database.ErrIngredientNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Ingredient not found")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "INGREDIENT_MERGE_ERROR", clientIP, fmt.Sprintf("Source: %d, Target: %d, Error: %v", req.SourceID, req.TargetID, err))
+		sendJSONError(w, http.StatusInternalServerError, "Failed to merge ingredients")
 		return
 	}
 
-	utils.LogSecurityEvent("INGREDIENT_CREATED", clientIP, fmt.Sprintf("Name: %s, User: %s", req.Name, user.Username))
-	sendJSONSuccess(w, "Ingredient created successfully", map[string]interface{}{
-		"name": req.Name,
+	utils.LogUserSecurityEvent(r.Context(), "INGREDIENT_MERGED", clientIP, user.ID, fmt.Sprintf("Source: %d, Target: %d, Recipes: %d, User: %s", req.SourceID, req.TargetID, affected, user.Username))
+	sendJSONSuccess(w, "Ingredients merged successfully", map[string]interface{}{
+		"target_id":        req.TargetID,
+		"recipes_affected": affected,
 	})
 }
 
@@ -618,7 +1773,7 @@ func DeleteIngredientHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
 	vars := mux.Vars(r)
 	idStr, exists := vars["id"]
@@ -629,7 +1784,7 @@ func DeleteIngredientHandler(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil || !utils.IsValidID(id) {
-		utils.LogSecurityEvent("INVALID_INGREDIENT_ID_DELETE", clientIP, idStr)
+		utils.LogSecurityEvent(r.Context(), "INVALID_INGREDIENT_ID_DELETE", clientIP, idStr)
 		sendJSONError(w, http.StatusBadRequest, "Invalid ingredient ID")
 		return
 	}
@@ -673,7 +1828,7 @@ func DeleteIngredientHandler(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
-			utils.LogSecurityEvent("INGREDIENT_DELETE_BLOCKED", clientIP, fmt.Sprintf("Name: %s, UsedIn: %d recipes", ingredientName, recipeCount))
+			utils.LogSecurityEvent(r.Context(), "INGREDIENT_DELETE_BLOCKED", clientIP, fmt.Sprintf("Name: %s, UsedIn: %d recipes", ingredientName, recipeCount))
 
 			sendJSONResponse(w, http.StatusConflict, map[string]interface{}{
 				"error":         errorMsg,
@@ -683,20 +1838,21 @@ func DeleteIngredientHandler(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		} else {
-			utils.LogSecurityEvent("INGREDIENT_DELETE_ERROR", clientIP, err.Error())
+			utils.LogSecurityEvent(r.Context(), "INGREDIENT_DELETE_ERROR", clientIP, err.Error())
 			sendJSONError(w, http.StatusInternalServerError, "Failed to delete ingredient")
 			return
 		}
 	}
 
-	utils.LogSecurityEvent("INGREDIENT_DELETED", clientIP, fmt.Sprintf("ID: %d, Name: %s, User: %s", id, ingredientName, user.Username))
+	utils.LogUserSecurityEvent(r.Context(), "INGREDIENT_DELETED", clientIP, user.ID, fmt.Sprintf("ID: %d, Name: %s, User: %s", id, ingredientName, user.Username))
 	sendJSONSuccess(w, "Ingredient deleted successfully", nil)
 }
 
 // Tag Handlers
 
 func GetTagsHandler(w http.ResponseWriter, r *http.Request) {
-	tags, err := database.GetAllTags()
+	// sort=count lists the most used tags first; anything else sorts by name
+	tags, err := database.GetAllTags(r.URL.Query().Get("sort") == "count")
 	if err != nil {
 		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch tags")
 		return
@@ -705,6 +1861,35 @@ func GetTagsHandler(w http.ResponseWriter, r *http.Request) {
 	sendJSONResponse(w, http.StatusOK, tags)
 }
 
+func GetTagHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	id, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_TAG_ID", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	tag, err := database.GetTagByID(id)
+	if err != nil {
+		if errors.Is(err, database.ErrTagNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Tag not found")
+			return
+		}
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch tag")
+		return
+	}
+
+	recipeCount, err := database.CountTagRecipes(id)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch tag")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, models.TagUsage{Tag: *tag, RecipeCount: recipeCount})
+}
+
 func CreateTagHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.GetUserFromToken(r)
 	if err != nil {
@@ -712,11 +1897,11 @@ func CreateTagHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
 	var req TagRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogSecurityEvent("INVALID_JSON_TAG", clientIP, err.Error())
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_TAG", clientIP, err.Error())
 		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
 		return
 	}
@@ -731,81 +1916,248 @@ func CreateTagHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate tag name
 	nameValidation := utils.ValidateTagName(req.Name)
 	if !nameValidation.Valid {
-		utils.LogSecurityEvent("TAG_VALIDATION_FAILED", clientIP, fmt.Sprintf("Name: %s, Error: %s", req.Name, nameValidation.Message))
-		sendJSONError(w, http.StatusBadRequest, nameValidation.Message)
+		utils.LogSecurityEvent(r.Context(), "TAG_VALIDATION_FAILED", clientIP, fmt.Sprintf("Name: %s, Error: %s", req.Name, nameValidation.Message))
+		sendJSONValidationError(w, nameValidation)
 		return
 	}
 
 	// Basic color validation (hex color)
-	if !strings.HasPrefix(req.Color, "#") || len(req.Color) != 7 {
+	if !validTagColor(req.Color) {
 		req.Color = "#ff6b6b"
 	}
 
 	// Use secure database function
 	err = database.CreateTagSecure(req.Name, req.Color)
+	if database.IsUniqueViolation(err) {
+		utils.LogSecurityEvent(r.Context(), "TAG_DUPLICATE", clientIP, fmt.Sprintf("Name: %s", req.Name))
+		sendJSONError(w, http.StatusConflict, "Tag already exists")
+		return
+	}
 	if err != nil {
-		utils.LogSecurityEvent("TAG_INSERT_ERROR", clientIP, fmt.Sprintf("Name: %s, Error: %v", req.Name, err))
-		sendJSONError(w, http.StatusConflict, "Tag already exists or database error")
+		utils.LogSecurityEvent(r.Context(), "TAG_INSERT_ERROR", clientIP, fmt.Sprintf("Name: %s, Error: %v", req.Name, err))
+		sendJSONError(w, http.StatusInternalServerError, "Failed to create tag")
 		return
 	}
 
-	utils.LogSecurityEvent("TAG_CREATED", clientIP, fmt.Sprintf("Name: %s, Color: %s, User: %s", req.Name, req.Color, user.Username))
+	utils.LogUserSecurityEvent(r.Context(), "TAG_CREATED", clientIP, user.ID, fmt.Sprintf("Name: %s, Color: %s, User: %s", req.Name, req.Color, user.Username))
 	sendJSONSuccess(w, "Tag created successfully", map[string]interface{}{
 		"name":  req.Name,
 		"color": req.Color,
 	})
 }
 
-func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+// MergeTagsHandler folds a duplicate tag into another one, moving its recipes over
+func MergeTagsHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.GetUserFromToken(r)
 	if err != nil {
 		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
 		return
 	}
 
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 
-	vars := mux.Vars(r)
-	idStr, exists := vars["id"]
-	if !exists {
-		sendJSONError(w, http.StatusBadRequest, "Tag ID is required")
+	var req MergeTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_TAG_MERGE", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil || !utils.IsValidID(id) {
-		utils.LogSecurityEvent("INVALID_TAG_ID_DELETE", clientIP, idStr)
+	if !utils.IsValidID(req.SourceID) || !utils.IsValidID(req.TargetID) {
+		utils.LogSecurityEvent(r.Context(), "INVALID_TAG_ID_MERGE", clientIP, fmt.Sprintf("Source: %d, Target: %d", req.SourceID, req.TargetID))
+		sendJSONError(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	if req.SourceID == req.TargetID {
+		sendJSONError(w, http.StatusBadRequest, "Cannot merge a tag into itself")
+		return
+	}
+
+	moved, err := database.MergeTags(req.SourceID, req.TargetID)
+	if err != nil {
+		if errors.Is(err, database.ErrTagNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Tag not found")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "TAG_MERGE_ERROR", clientIP, fmt.Sprintf("Source: %d, Target: %d, Error: %v", req.SourceID, req.TargetID, err))
+		sendJSONError(w, http.StatusInternalServerError, "Failed to merge tags")
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "TAG_MERGED", clientIP, user.ID, fmt.Sprintf("Source: %d, Target: %d, Recipes: %d, User: %s", req.SourceID, req.TargetID, moved, user.Username))
+	sendJSONSuccess(w, "Tags merged successfully", map[string]interface{}{
+		"target_id":        req.TargetID,
+		"recipes_retagged": moved,
+	})
+}
+
+// validTagColor reports whether color looks like a #rrggbb hex color
+func validTagColor(color string) bool {
+	return strings.HasPrefix(color, "#") && len(color) == 7
+}
+
+func UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	id, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_TAG_ID_UPDATE", clientIP, idStr)
 		sendJSONError(w, http.StatusBadRequest, "Invalid tag ID")
 		return
 	}
 
-	// Get tag name for logging
-	var tagName string
-	database.DB.QueryRow("SELECT name FROM tags WHERE id = ?", id).Scan(&tagName)
+	var req UpdateTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_TAG_UPDATE", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	if req.Name == nil && req.Color == nil {
+		sendJSONError(w, http.StatusBadRequest, "Name or color is required")
+		return
+	}
+
+	tag, err := database.GetTagByID(id)
+	if err != nil {
+		if errors.Is(err, database.ErrTagNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Tag not found")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "TAG_UPDATE_ERROR", clientIP, fmt.Sprintf("ID: %d, Error: %v", id, err))
+		sendJSONError(w, http.StatusInternalServerError, "Failed to update tag")
+		return
+	}
+	oldName, oldColor := tag.Name, tag.Color
+
+	var failures []utils.ValidationResult
+	if req.Name != nil {
+		tag.Name = strings.TrimSpace(*req.Name)
+		if validation := utils.ValidateTagName(tag.Name); !validation.Valid {
+			utils.LogSecurityEvent(r.Context(), "TAG_VALIDATION_FAILED", clientIP, fmt.Sprintf("Name: %s, Error: %s", tag.Name, validation.Message))
+			failures = append(failures, validation)
+		}
+	}
+
+	if req.Color != nil {
+		tag.Color = strings.TrimSpace(*req.Color)
+		if !validTagColor(tag.Color) {
+			failures = append(failures, utils.ValidationResult{Valid: false, Message: "Color must be a hex color like #ff6b6b", Field: "color"})
+		}
+	}
+
+	if len(failures) > 0 {
+		sendJSONValidationErrors(w, failures)
+		return
+	}
+
+	if err := database.UpdateTag(tag.ID, tag.Name, tag.Color); err != nil {
+		switch {
+		case errors.Is(err, database.ErrTagNameTaken):
+			sendJSONError(w, http.StatusConflict, "A tag with that name already exists")
+		case errors.Is(err, database.ErrTagNotFound):
+			sendJSONError(w, http.StatusNotFound, "Tag not found")
+		default:
+			utils.LogSecurityEvent(r.Context(), "TAG_UPDATE_ERROR", clientIP, fmt.Sprintf("ID: %d, Error: %v", id, err))
+			sendJSONError(w, http.StatusInternalServerError, "Failed to update tag")
+		}
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "TAG_UPDATED", clientIP, user.ID, fmt.Sprintf("ID: %d, Name: %s -> %s, Color: %s -> %s, User: %s", tag.ID, oldName, tag.Name, oldColor, tag.Color, user.Username))
+	sendJSONSuccess(w, "Tag updated successfully", tag)
+}
+
+func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	id, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_TAG_ID_DELETE", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
 
-	// Delete tag (cascading deletes will handle recipe_tags)
-	_, err = database.DB.Exec("DELETE FROM tags WHERE id = ?", id)
+	tag, err := database.GetTagByID(id)
 	if err != nil {
-		utils.LogSecurityEvent("TAG_DELETE_ERROR", clientIP, fmt.Sprintf("ID: %d, Error: %v", id, err))
+		if errors.Is(err, database.ErrTagNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Tag not found")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "TAG_DELETE_ERROR", clientIP, fmt.Sprintf("ID: %d, Error: %v", id, err))
+		sendJSONError(w, http.StatusInternalServerError, "Failed to delete tag")
+		return
+	}
+
+	// Tags still on recipes are only deleted with force=true, so nobody un-tags many recipes by accident
+	if force, _ := strconv.ParseBool(r.URL.Query().Get("force")); !force {
+		recipeCount, recipeNames, err := database.GetTagUsage(id, 3)
+		if err != nil {
+			utils.LogSecurityEvent(r.Context(), "TAG_DELETE_ERROR", clientIP, fmt.Sprintf("ID: %d, Error: %v", id, err))
+			sendJSONError(w, http.StatusInternalServerError, "Failed to delete tag")
+			return
+		}
+
+		if recipeCount > 0 {
+			errorMsg := fmt.Sprintf("Tag %s is used in %d recipe(s)", tag.Name, recipeCount)
+			if len(recipeNames) > 0 {
+				errorMsg += fmt.Sprintf(": %s", strings.Join(recipeNames, ", "))
+				if recipeCount > len(recipeNames) {
+					errorMsg += fmt.Sprintf(" and %d more", recipeCount-len(recipeNames))
+				}
+			}
+			errorMsg += ". Delete with force=true to remove it from them"
+
+			utils.LogSecurityEvent(r.Context(), "TAG_DELETE_BLOCKED", clientIP, fmt.Sprintf("Name: %s, UsedIn: %d recipes", tag.Name, recipeCount))
+
+			sendJSONResponse(w, http.StatusConflict, map[string]interface{}{
+				"error":         errorMsg,
+				"usedInRecipes": true,
+				"recipeCount":   recipeCount,
+				"recipeNames":   recipeNames,
+			})
+			return
+		}
+	}
+
+	if err := database.DeleteTag(id); err != nil {
+		if errors.Is(err, database.ErrTagNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Tag not found")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "TAG_DELETE_ERROR", clientIP, fmt.Sprintf("ID: %d, Error: %v", id, err))
 		sendJSONError(w, http.StatusInternalServerError, "Failed to delete tag")
 		return
 	}
 
-	utils.LogSecurityEvent("TAG_DELETED", clientIP, fmt.Sprintf("ID: %d, Name: %s, User: %s", id, tagName, user.Username))
+	utils.LogUserSecurityEvent(r.Context(), "TAG_DELETED", clientIP, user.ID, fmt.Sprintf("ID: %d, Name: %s, User: %s", id, tag.Name, user.Username))
 	sendJSONSuccess(w, "Tag deleted successfully", nil)
 }
 
 // Search Handler
 
 func SearchHandler(w http.ResponseWriter, r *http.Request) {
-	clientIP := getClientIP(r)
+	clientIP := utils.ClientIP(r)
 	query := strings.TrimSpace(r.URL.Query().Get("q"))
 
 	// Validate search query
 	searchValidation := utils.ValidateSearchQuery(query)
 	if !searchValidation.Valid {
-		utils.LogSecurityEvent("SEARCH_VALIDATION_FAILED", clientIP, fmt.Sprintf("Query: %s, Error: %s", query, searchValidation.Message))
-		sendJSONError(w, http.StatusBadRequest, searchValidation.Message)
+		utils.LogSecurityEvent(r.Context(), "SEARCH_VALIDATION_FAILED", clientIP, fmt.Sprintf("Query: %s, Error: %s", query, searchValidation.Message))
+		sendJSONValidationError(w, searchValidation)
 		return
 	}
 
@@ -814,15 +2166,14 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Use secure search function
-	recipes, err := database.SearchRecipes(query)
+	recipes, _, err := database.QueryRecipes(database.RecipeFilter{Search: query, ViewerID: viewerID(r)})
 	if err != nil {
-		utils.LogSecurityEvent("SEARCH_ERROR", clientIP, fmt.Sprintf("Query: %s, Error: %v", query, err))
+		utils.LogSecurityEvent(r.Context(), "SEARCH_ERROR", clientIP, fmt.Sprintf("Query: %s, Error: %v", query, err))
 		sendJSONError(w, http.StatusInternalServerError, "Search failed")
 		return
 	}
 
-	utils.LogSecurityEvent("SEARCH_PERFORMED", clientIP, fmt.Sprintf("Query: %s, Results: %d", query, len(recipes)))
+	utils.LogSecurityEvent(r.Context(), "SEARCH_PERFORMED", clientIP, fmt.Sprintf("Query: %s, Results: %d", query, len(recipes)))
 
 	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -834,80 +2185,130 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 
 // Helper functions
 
-func createRecipeFromRequest(req RecipeRequest, userID int, clientIP string) (int64, error) {
+// validateRecipeRequest trims and validates the scalar recipe fields and the ingredients'
+// preparation notes shared by create, update and import. Failures are logged under the
+// given event name.
+func validateRecipeRequest(ctx context.Context, req *RecipeRequest, clientIP, event string) error {
 	// Trim whitespace
 	req.Title = strings.TrimSpace(req.Title)
 	req.Description = strings.TrimSpace(req.Description)
 	req.Instructions = strings.TrimSpace(req.Instructions)
 	req.ServingUnit = strings.TrimSpace(req.ServingUnit)
+	req.YieldUnit = strings.TrimSpace(req.YieldUnit)
+	req.SourceURL = strings.TrimSpace(req.SourceURL)
+	req.VideoURL = strings.TrimSpace(req.VideoURL)
 
 	// Comprehensive validation
 	titleValidation := utils.ValidateRecipeTitle(req.Title)
 	descValidation := utils.ValidateRecipeDescription(req.Description)
 	instrValidation := utils.ValidateRecipeInstructions(req.Instructions)
 	servingUnitValidation := utils.ValidateServingUnit(req.ServingUnit)
-
-	if !titleValidation.Valid {
-		utils.LogSecurityEvent("RECIPE_VALIDATION_FAILED", clientIP, titleValidation.Message)
-		return 0, fmt.Errorf(titleValidation.Message)
-	}
-
-	if !descValidation.Valid {
-		utils.LogSecurityEvent("RECIPE_VALIDATION_FAILED", clientIP, descValidation.Message)
-		return 0, fmt.Errorf(descValidation.Message)
-	}
-
-	if !instrValidation.Valid {
-		utils.LogSecurityEvent("RECIPE_VALIDATION_FAILED", clientIP, instrValidation.Message)
-		return 0, fmt.Errorf(instrValidation.Message)
+	yieldValidation := utils.ValidateYield(req.YieldQuantity, req.YieldUnit)
+	cuisineValidation := utils.ValidateCuisine(req.Cuisine)
+	sourceURLValidation := utils.ValidateURL(req.SourceURL)
+	videoURLValidation := utils.ValidateVideoURL(req.VideoURL)
+
+	if req.Status == "" {
+		req.Status = models.RecipeStatusPublished
+	}
+	statusValidation := utils.ValidationResult{Valid: true, Field: "status"}
+	if !database.IsValidRecipeStatus(req.Status) {
+		statusValidation = utils.ValidationResult{Valid: false, Message: "Status must be draft or published", Field: "status"}
+	}
+
+	// Drafts skip the required-field checks; they are validated in full when published
+	draft := req.Status == models.RecipeStatusDraft
+	if draft && req.Instructions == "" {
+		instrValidation = utils.ValidationResult{Valid: true}
+	}
+	if draft && req.Servings == 0 {
+		req.Servings = 1
+	}
+
+	// Every failure is reported so that the form can flag all of them at once
+	var failures validationErrors
+	for _, validation := range []utils.ValidationResult{
+		titleValidation,
+		descValidation,
+		instrValidation,
+		servingUnitValidation,
+		yieldValidation,
+		cuisineValidation,
+		sourceURLValidation,
+		videoURLValidation,
+		statusValidation,
+	} {
+		if !validation.Valid {
+			utils.LogSecurityEvent(ctx, event, clientIP, validation.Message)
+			failures = append(failures, validation)
+		}
 	}
 
-	if !servingUnitValidation.Valid {
-		utils.LogSecurityEvent("RECIPE_VALIDATION_FAILED", clientIP, servingUnitValidation.Message)
-		return 0, fmt.Errorf(servingUnitValidation.Message)
+	// Preparation notes are sanitized like image captions
+	for i := range req.Ingredients {
+		req.Ingredients[i].Preparation = utils.SanitizeInput(req.Ingredients[i].Preparation)
+		if utf8.RuneCountInString(req.Ingredients[i].Preparation) > maxPreparationLength {
+			failures = append(failures, utils.ValidationResult{
+				Valid:   false,
+				Message: fmt.Sprintf("Ingredient preparation is too long (maximum %d characters)", maxPreparationLength),
+				Field:   "ingredients",
+			})
+			break
+		}
 	}
 
 	// Validate numeric inputs
-	prepTimeValidation := utils.ValidateNumericInput(req.PrepTime, 0, 1440, "Prep time")
-	cookTimeValidation := utils.ValidateNumericInput(req.CookTime, 0, 1440, "Cook time")
-	servingsValidation := utils.ValidateNumericInput(req.Servings, 1, 100, "Servings")
-
-	if !prepTimeValidation.Valid {
-		return 0, fmt.Errorf(prepTimeValidation.Message)
-	}
-
-	if !cookTimeValidation.Valid {
-		return 0, fmt.Errorf(cookTimeValidation.Message)
+	for _, validation := range []utils.ValidationResult{
+		utils.ValidateNumericInput(req.PrepTime, 0, 1440, "Prep time"),
+		utils.ValidateNumericInput(req.CookTime, 0, 1440, "Cook time"),
+		utils.ValidateNumericInput(req.Servings, 1, 100, "Servings"),
+	} {
+		if !validation.Valid {
+			failures = append(failures, validation)
+		}
 	}
 
-	if !servingsValidation.Valid {
-		return 0, fmt.Errorf(servingsValidation.Message)
+	if len(failures) > 0 {
+		return failures
 	}
 
 	if req.ServingUnit == "" {
 		req.ServingUnit = "people"
 	}
-
-	// Use secure database function
-	recipeID, err := database.CreateRecipeSecure(req.Title, req.Description, req.Instructions, req.PrepTime, req.CookTime, req.Servings, req.ServingUnit, userID)
-	if err != nil {
-		utils.LogSecurityEvent("RECIPE_INSERT_ERROR", clientIP, err.Error())
-		return 0, fmt.Errorf("error creating recipe")
+	switch {
+	case req.YieldQuantity == 0:
+		req.YieldUnit = ""
+	case req.YieldUnit == "":
+		req.YieldUnit = utils.DefaultYieldUnit
 	}
 
-	// Handle tags with validation
-	for _, tagID := range req.Tags {
-		if utils.IsValidID(tagID) {
-			database.DB.Exec("INSERT INTO recipe_tags (recipe_id, tag_id) VALUES (?, ?)", recipeID, tagID)
-		} else {
-			utils.LogSecurityEvent("INVALID_TAG_ID", clientIP, fmt.Sprintf("%d", tagID))
-		}
+	// Store the allow-listed spelling
+	req.Cuisine, _ = utils.CanonicalCuisine(req.Cuisine)
+	if req.VideoURL != "" {
+		req.VideoURL, _ = utils.NormalizeVideoURL(req.VideoURL)
 	}
 
-	// Handle ingredients with thorough validation
-	for _, ingredient := range req.Ingredients {
+	return nil
+}
+
+// validIngredientLines converts the request's ingredient lines for saving, keeping the
+// first occurrence of a repeated ingredient. Every invalid line is logged and reported as
+// a validation failure on its index, e.g. "ingredients[3]".
+func validIngredientLines(ctx context.Context, lines []RecipeIngredientReq, clientIP, eventSuffix string) ([]models.RecipeIngredient, error) {
+	var ingredients []models.RecipeIngredient
+	var failures validationErrors
+	seen := make(map[int]bool)
+
+	for i, ingredient := range lines {
+		field := fmt.Sprintf("ingredients[%d]", i)
+
 		if !utils.IsValidID(ingredient.IngredientID) {
-			utils.LogSecurityEvent("INVALID_INGREDIENT_ID", clientIP, fmt.Sprintf("%d", ingredient.IngredientID))
+			utils.LogSecurityEvent(ctx, "INVALID_INGREDIENT_ID"+eventSuffix, clientIP, fmt.Sprintf("%d", ingredient.IngredientID))
+			failures = append(failures, utils.ValidationResult{
+				Valid:   false,
+				Message: fmt.Sprintf("Ingredient %d: choose an ingredient", i+1),
+				Field:   field,
+			})
 			continue
 		}
 
@@ -916,113 +2317,126 @@ func createRecipeFromRequest(req RecipeRequest, userID int, clientIP string) (in
 		unitValidation := utils.ValidateUnit(ingredient.Unit)
 
 		if !quantityValidation.Valid || !unitValidation.Valid {
-			utils.LogSecurityEvent("INGREDIENT_VALIDATION_FAILED", clientIP,
+			utils.LogSecurityEvent(ctx, "INGREDIENT_VALIDATION_FAILED"+eventSuffix, clientIP,
 				fmt.Sprintf("ID:%d, Qty:%f, Unit:%s", ingredient.IngredientID, ingredient.Quantity, ingredient.Unit))
+			for _, validation := range []utils.ValidationResult{quantityValidation, unitValidation} {
+				if !validation.Valid {
+					failures = append(failures, utils.ValidationResult{
+						Valid:   false,
+						Message: fmt.Sprintf("Ingredient %d: %s", i+1, validation.Message),
+						Field:   field,
+					})
+				}
+			}
 			continue
 		}
 
-		database.DB.Exec("INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity, unit) VALUES (?, ?, ?, ?)",
-			recipeID, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit)
-	}
-
-	return recipeID, nil
-}
-
-func updateRecipeFromRequest(req RecipeRequest, recipeID, userID int, clientIP string) error {
-	// Trim whitespace
-	req.Title = strings.TrimSpace(req.Title)
-	req.Description = strings.TrimSpace(req.Description)
-	req.Instructions = strings.TrimSpace(req.Instructions)
-	req.ServingUnit = strings.TrimSpace(req.ServingUnit)
-
-	// Comprehensive validation (same as create)
-	titleValidation := utils.ValidateRecipeTitle(req.Title)
-	descValidation := utils.ValidateRecipeDescription(req.Description)
-	instrValidation := utils.ValidateRecipeInstructions(req.Instructions)
-	servingUnitValidation := utils.ValidateServingUnit(req.ServingUnit)
-
-	if !titleValidation.Valid {
-		utils.LogSecurityEvent("RECIPE_EDIT_VALIDATION_FAILED", clientIP, titleValidation.Message)
-		return fmt.Errorf(titleValidation.Message)
-	}
-
-	if !descValidation.Valid {
-		utils.LogSecurityEvent("RECIPE_EDIT_VALIDATION_FAILED", clientIP, descValidation.Message)
-		return fmt.Errorf(descValidation.Message)
+		if seen[ingredient.IngredientID] {
+			continue
+		}
+		seen[ingredient.IngredientID] = true
+
+		ingredients = append(ingredients, models.RecipeIngredient{
+			IngredientID: ingredient.IngredientID,
+			Quantity:     ingredient.Quantity,
+			Unit:         ingredient.Unit,
+			Preparation:  ingredient.Preparation,
+			Optional:     ingredient.Optional,
+		})
 	}
 
-	if !instrValidation.Valid {
-		utils.LogSecurityEvent("RECIPE_EDIT_VALIDATION_FAILED", clientIP, instrValidation.Message)
-		return fmt.Errorf(instrValidation.Message)
+	if len(failures) > 0 {
+		return nil, failures
 	}
+	return ingredients, nil
+}
 
-	if !servingUnitValidation.Valid {
-		utils.LogSecurityEvent("RECIPE_EDIT_VALIDATION_FAILED", clientIP, servingUnitValidation.Message)
-		return fmt.Errorf(servingUnitValidation.Message)
+// validTagIDs returns the positive tag IDs from the request, logging the rest
+func validTagIDs(ctx context.Context, tagIDs []int, clientIP, event string) []int {
+	var valid []int
+	for _, tagID := range tagIDs {
+		if utils.IsValidID(tagID) {
+			valid = append(valid, tagID)
+		} else {
+			utils.LogSecurityEvent(ctx, event, clientIP, fmt.Sprintf("%d", tagID))
+		}
 	}
+	return valid
+}
 
-	// Validate numeric inputs
-	prepTimeValidation := utils.ValidateNumericInput(req.PrepTime, 0, 1440, "Prep time")
-	cookTimeValidation := utils.ValidateNumericInput(req.CookTime, 0, 1440, "Cook time")
-	servingsValidation := utils.ValidateNumericInput(req.Servings, 1, 100, "Servings")
-
-	if !prepTimeValidation.Valid {
-		return fmt.Errorf(prepTimeValidation.Message)
+func createRecipeFromRequest(ctx context.Context, req RecipeRequest, userID int, clientIP, idempotencyKey string) (int64, bool, error) {
+	if err := validateRecipeRequest(ctx, &req, clientIP, "RECIPE_VALIDATION_FAILED"); err != nil {
+		return 0, false, err
 	}
-
-	if !cookTimeValidation.Valid {
-		return fmt.Errorf(cookTimeValidation.Message)
+	ingredients, err := validIngredientLines(ctx, req.Ingredients, clientIP, "")
+	if err != nil {
+		return 0, false, err
+	}
+
+	recipe := &models.Recipe{
+		Title:         req.Title,
+		Description:   req.Description,
+		Instructions:  req.Instructions,
+		PrepTime:      req.PrepTime,
+		CookTime:      req.CookTime,
+		Servings:      req.Servings,
+		ServingUnit:   req.ServingUnit,
+		YieldQuantity: req.YieldQuantity,
+		YieldUnit:     req.YieldUnit,
+		Cuisine:       req.Cuisine,
+		SourceURL:     req.SourceURL,
+		VideoURL:      req.VideoURL,
+		IsPublic:      req.IsPublic == nil || *req.IsPublic,
+		Status:        req.Status,
+		CreatedBy:     userID,
+		Ingredients:   ingredients,
+	}
+
+	// Recipe, tags and ingredients are saved atomically, along with the idempotency key
+	recipeID, created, err := database.CreateRecipeOnce(recipe, validTagIDs(ctx, req.Tags, clientIP, "INVALID_TAG_ID"), idempotencyKey)
+	if err != nil {
+		utils.LogSecurityEvent(ctx, "RECIPE_INSERT_ERROR", clientIP, err.Error())
+		return 0, false, err
 	}
 
-	if !servingsValidation.Valid {
-		return fmt.Errorf(servingsValidation.Message)
-	}
+	return recipeID, created, nil
+}
 
-	if req.ServingUnit == "" {
-		req.ServingUnit = "people"
+func updateRecipeFromRequest(ctx context.Context, req RecipeRequest, recipeID, userID int, clientIP string) error {
+	// Comprehensive validation (same as create)
+	if err := validateRecipeRequest(ctx, &req, clientIP, "RECIPE_EDIT_VALIDATION_FAILED"); err != nil {
+		return err
 	}
-
-	// Update recipe using prepared statement
-	_, err := database.DB.Exec(`
-		UPDATE recipes SET title = ?, description = ?, instructions = ?, 
-		prep_time = ?, cook_time = ?, servings = ?, serving_unit = ? WHERE id = ? AND created_by = ?
-	`, req.Title, req.Description, req.Instructions, req.PrepTime, req.CookTime, req.Servings, req.ServingUnit, recipeID, userID)
-
+	ingredients, err := validIngredientLines(ctx, req.Ingredients, clientIP, "_EDIT")
 	if err != nil {
-		utils.LogSecurityEvent("RECIPE_UPDATE_ERROR", clientIP, err.Error())
-		return fmt.Errorf("error updating recipe")
-	}
-
-	// Update tags with validation
-	database.DB.Exec("DELETE FROM recipe_tags WHERE recipe_id = ?", recipeID)
-	for _, tagID := range req.Tags {
-		if utils.IsValidID(tagID) {
-			database.DB.Exec("INSERT INTO recipe_tags (recipe_id, tag_id) VALUES (?, ?)", recipeID, tagID)
-		} else {
-			utils.LogSecurityEvent("INVALID_TAG_ID_EDIT", clientIP, fmt.Sprintf("%d", tagID))
-		}
-	}
-
-	// Update ingredients with validation
-	database.DB.Exec("DELETE FROM recipe_ingredients WHERE recipe_id = ?", recipeID)
-	for _, ingredient := range req.Ingredients {
-		if !utils.IsValidID(ingredient.IngredientID) {
-			utils.LogSecurityEvent("INVALID_INGREDIENT_ID_EDIT", clientIP, fmt.Sprintf("%d", ingredient.IngredientID))
-			continue
-		}
-
-		// Validate ingredient data
-		quantityValidation := utils.ValidateQuantity(ingredient.Quantity)
-		unitValidation := utils.ValidateUnit(ingredient.Unit)
-
-		if !quantityValidation.Valid || !unitValidation.Valid {
-			utils.LogSecurityEvent("INGREDIENT_VALIDATION_FAILED_EDIT", clientIP,
-				fmt.Sprintf("ID:%d, Qty:%f, Unit:%s", ingredient.IngredientID, ingredient.Quantity, ingredient.Unit))
-			continue
-		}
-
-		database.DB.Exec("INSERT INTO recipe_ingredients (recipe_id, ingredient_id, quantity, unit) VALUES (?, ?, ?, ?)",
-			recipeID, ingredient.IngredientID, ingredient.Quantity, ingredient.Unit)
+		return err
+	}
+
+	recipe := &models.Recipe{
+		ID:            recipeID,
+		Title:         req.Title,
+		Description:   req.Description,
+		Instructions:  req.Instructions,
+		PrepTime:      req.PrepTime,
+		CookTime:      req.CookTime,
+		Servings:      req.Servings,
+		ServingUnit:   req.ServingUnit,
+		YieldQuantity: req.YieldQuantity,
+		YieldUnit:     req.YieldUnit,
+		Cuisine:       req.Cuisine,
+		SourceURL:     req.SourceURL,
+		VideoURL:      req.VideoURL,
+		IsPublic:      req.IsPublic != nil && *req.IsPublic,
+		Status:        req.Status,
+		CreatedBy:     userID,
+		Ingredients:   ingredients,
+	}
+
+	// Recipe, tags and ingredients are replaced atomically
+	err = database.UpdateRecipeWithRelations(recipe, validTagIDs(ctx, req.Tags, clientIP, "INVALID_TAG_ID_EDIT"))
+	if err != nil {
+		utils.LogSecurityEvent(ctx, "RECIPE_UPDATE_ERROR", clientIP, err.Error())
+		return err
 	}
 
 	return nil
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// fakePNG is a PNG signature followed by an IHDR chunk header, enough for sniffing
+var fakePNG = append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), bytes.Repeat([]byte{0}, 64)...)
+
+// setupTestDB initializes a fresh, seeded database in a temporary directory, which also
+// becomes the working directory so uploads stay out of the source tree
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("DB_PATH", filepath.Join(dir, "recipes.db"))
+	database.InitDB()
+	t.Cleanup(func() { database.DB.Close() })
+
+	auth.SetJWTSecret("test-secret")
+	t.Cleanup(func() { auth.SetJWTSecret("") })
+}
+
+// asAdmin signs r in as the seeded admin, who has user ID 1
+func asAdmin(t *testing.T, r *http.Request) *http.Request {
+	t.Helper()
+
+	token, err := auth.CreateToken(&models.User{ID: 1, Username: "admin"})
+	if err != nil {
+		t.Fatalf("creating a token: %v", err)
+	}
+	r.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+	return r
+}
+
+// createTestRecipe adds a recipe without images owned by the admin and returns its ID
+func createTestRecipe(t *testing.T) int {
+	t.Helper()
+
+	recipe := models.Recipe{
+		Title:        "Image test",
+		Instructions: "Take photos",
+		Servings:     2,
+		ServingUnit:  "people",
+		CreatedBy:    1,
+		IsPublic:     true,
+	}
+	id, err := database.CreateRecipeWithRelations(&recipe, nil)
+	if err != nil {
+		t.Fatalf("creating a recipe: %v", err)
+	}
+	return int(id)
+}
+
+// uploadImages posts files with the given names and contents to the recipe's image upload handler
+func uploadImages(t *testing.T, recipeID int, files []string, contents [][]byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for i, name := range files {
+		part, err := form.CreateFormFile("images", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(contents[i])
+	}
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/recipes/%d/images", recipeID), &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	r = mux.SetURLVars(asAdmin(t, r), map[string]string{"id": strconv.Itoa(recipeID)})
+
+	w := httptest.NewRecorder()
+	UploadRecipeImagesHandler(w, r)
+	return w
+}
+
+func TestUploadRecipeImagesOverLimit(t *testing.T) {
+	setupTestDB(t)
+	SetMaxImagesPerRecipe(2)
+	t.Cleanup(func() { SetMaxImagesPerRecipe(DefaultMaxImagesPerRecipe) })
+	recipeID := createTestRecipe(t)
+
+	w := uploadImages(t, recipeID,
+		[]string{"one.png", "notes.png", "two.png", "three.png"},
+		[][]byte{fakePNG, []byte("just some text\n"), fakePNG, fakePNG})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload returned %d: %s", w.Code, w.Body)
+	}
+
+	var response struct {
+		Data struct {
+			Images   []models.RecipeImage `json:"images"`
+			Skipped  int                  `json:"skipped"`
+			Rejected []struct {
+				Filename string `json:"filename"`
+				Error    string `json:"error"`
+			} `json:"rejected"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding the response: %v", err)
+	}
+
+	if len(response.Data.Images) != 2 {
+		t.Errorf("uploaded %d images, want 2", len(response.Data.Images))
+	}
+	if response.Data.Skipped != 1 {
+		t.Errorf("skipped = %d, want 1 for the file over the limit", response.Data.Skipped)
+	}
+	if len(response.Data.Rejected) != 1 || response.Data.Rejected[0].Filename != "notes.png" {
+		t.Errorf("rejected = %+v, want notes.png", response.Data.Rejected)
+	}
+
+	if count, err := database.CountRecipeImages(recipeID); err != nil || count != 2 {
+		t.Errorf("recipe has %d images (%v), want 2", count, err)
+	}
+	if entries, _ := os.ReadDir("uploads"); len(entries) != 2 {
+		t.Errorf("uploads holds %d files, want only the 2 attached images", len(entries))
+	}
+
+	// A full recipe refuses further uploads outright
+	w = uploadImages(t, recipeID, []string{"four.png"}, [][]byte{fakePNG})
+	if w.Code != http.StatusConflict {
+		t.Errorf("upload to a full recipe returned %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+// getRecipe requests a recipe anonymously, revalidating with ifNoneMatch when it is set
+func getRecipe(recipeID int, ifNoneMatch string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/recipes/%d", recipeID), nil)
+	if ifNoneMatch != "" {
+		r.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	r = mux.SetURLVars(r, map[string]string{"id": strconv.Itoa(recipeID)})
+
+	w := httptest.NewRecorder()
+	GetRecipeHandler(w, r)
+	return w
+}
+
+func TestGetRecipeETagIgnoresViews(t *testing.T) {
+	setupTestDB(t)
+	recipeID := createTestRecipe(t)
+	database.FlushRecipeViews()
+
+	first := getRecipe(recipeID, "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first request returned %d with ETag %q", first.Code, etag)
+	}
+	if flushed, err := database.FlushRecipeViews(); err != nil || flushed != 1 {
+		t.Fatalf("flushing the first view updated %d recipes (%v), want 1", flushed, err)
+	}
+
+	// The flushed view changed view_count, which must not change the ETag
+	revalidated := getRecipe(recipeID, etag)
+	if revalidated.Code != http.StatusNotModified {
+		t.Errorf("revalidation after a view returned %d, want %d", revalidated.Code, http.StatusNotModified)
+	}
+	if flushed, _ := database.FlushRecipeViews(); flushed != 0 {
+		t.Errorf("a 304 revalidation was counted as a view")
+	}
+
+	again := getRecipe(recipeID, "")
+	if again.Header().Get("ETag") != etag {
+		t.Errorf("ETag changed from %s to %s after a view", etag, again.Header().Get("ETag"))
+	}
+	if !bytes.Contains(again.Body.Bytes(), []byte(`"view_count":1`)) {
+		t.Errorf("response does not report the flushed view: %s", again.Body)
+	}
+}
+
+// updateRecipe sends body to the admin's recipe update handler
+func updateRecipe(t *testing.T, recipeID int, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/recipes/%d", recipeID), strings.NewReader(body))
+	r = mux.SetURLVars(asAdmin(t, r), map[string]string{"id": strconv.Itoa(recipeID)})
+
+	w := httptest.NewRecorder()
+	UpdateRecipeHandler(w, r)
+	return w
+}
+
+func TestUpdateRecipeIsAtomic(t *testing.T) {
+	setupTestDB(t)
+	recipeID := createTestRecipe(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantTitle  string
+	}{
+		{"invalid JSON", `{"title":`, http.StatusBadRequest, "Image test"},
+		{"missing title", `{"title":"","instructions":"Stir","servings":2,"serving_unit":"people"}`, http.StatusBadRequest, "Image test"},
+		{"unknown ingredient", `{"title":"Half saved","instructions":"Stir","servings":2,"serving_unit":"people",
+			"ingredients":[{"ingredient_id":1,"quantity":1,"unit":"tsp"},{"ingredient_id":99999,"quantity":1,"unit":"g"}],"tags":[1]}`,
+			http.StatusInternalServerError, "Image test"},
+		{"valid", `{"title":"Saved","instructions":"Stir","servings":2,"serving_unit":"people",
+			"ingredients":[{"ingredient_id":1,"quantity":1,"unit":"tsp"}],"tags":[1]}`,
+			http.StatusOK, "Saved"},
+	}
+
+	for _, tt := range tests {
+		w := updateRecipe(t, recipeID, tt.body)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: update returned %d, want %d: %s", tt.name, w.Code, tt.wantStatus, w.Body)
+		}
+
+		recipe, err := database.GetRecipeByID(recipeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recipe.Title != tt.wantTitle {
+			t.Errorf("%s: title is %q, want %q", tt.name, recipe.Title, tt.wantTitle)
+		}
+		if tt.wantStatus != http.StatusOK && (len(recipe.Ingredients) != 0 || len(recipe.Tags) != 0) {
+			t.Errorf("%s: failed update left %d ingredients and %d tags", tt.name, len(recipe.Ingredients), len(recipe.Tags))
+		}
+		if tt.wantStatus == http.StatusOK && (len(recipe.Ingredients) != 1 || len(recipe.Tags) != 1 || !recipe.IsPublic) {
+			t.Errorf("%s: saved %d ingredients and %d tags with public %v, want 1, 1 and the kept setting",
+				tt.name, len(recipe.Ingredients), len(recipe.Tags), recipe.IsPublic)
+		}
+	}
+}
+
+func TestCreateRecipeErrorStatuses(t *testing.T) {
+	setupTestDB(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"missing title", `{"title":"","instructions":"Stir","servings":2,"serving_unit":"people"}`, http.StatusBadRequest},
+		// Passes validation, then fails the ingredient foreign key in the database
+		{"unknown ingredient", `{"title":"Ghost soup","instructions":"Stir","servings":2,"serving_unit":"people",
+			"ingredients":[{"ingredient_id":99999,"quantity":1,"unit":"g"}]}`, http.StatusInternalServerError},
+		{"valid", `{"title":"Real soup","instructions":"Stir","servings":2,"serving_unit":"people",
+			"ingredients":[{"ingredient_id":1,"quantity":1,"unit":"tsp"}]}`, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		r := asAdmin(t, httptest.NewRequest(http.MethodPost, "/api/recipes", strings.NewReader(tt.body)))
+		w := httptest.NewRecorder()
+		CreateRecipeHandler(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: create returned %d, want %d: %s", tt.name, w.Code, tt.wantStatus, w.Body)
+		}
+	}
+
+	// An unknown sort with strict=true is the client's mistake
+	w := httptest.NewRecorder()
+	GetRecipesHandler(w, httptest.NewRequest(http.MethodGet, "/api/recipes?sort=tastiest&strict=true", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"field":"sort"`) {
+		t.Errorf("unknown strict sort returned %d: %s, want a 400 naming the sort field", w.Code, w.Body)
+	}
+}
+
+func TestInvalidIngredientLinesAreReported(t *testing.T) {
+	setupTestDB(t)
+	recipeID := createTestRecipe(t)
+
+	body := `{"title":"Lumpy soup","instructions":"Stir","servings":2,"serving_unit":"people","ingredients":[
+		{"ingredient_id":1,"quantity":1,"unit":"tsp"},
+		{"ingredient_id":0,"quantity":1,"unit":"g"},
+		{"ingredient_id":5,"quantity":-2,"unit":"bucket"},
+		{"ingredient_id":1,"quantity":2,"unit":"tsp"}]}`
+	wantFields := []string{"ingredients[1]", "ingredients[2]", "ingredients[2]"}
+
+	create := httptest.NewRecorder()
+	CreateRecipeHandler(create, asAdmin(t, httptest.NewRequest(http.MethodPost, "/api/recipes", strings.NewReader(body))))
+
+	for name, w := range map[string]*httptest.ResponseRecorder{"create": create, "update": updateRecipe(t, recipeID, body)} {
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s returned %d, want 400: %s", name, w.Code, w.Body)
+			continue
+		}
+
+		var response struct {
+			Errors []FieldError `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: decoding the response: %v", name, err)
+		}
+		var fields []string
+		for _, failure := range response.Errors {
+			fields = append(fields, failure.Field)
+		}
+		if !slices.Equal(fields, wantFields) {
+			t.Errorf("%s reported fields %q, want %q", name, fields, wantFields)
+		}
+	}
+
+	if ingredients := database.GetRecipeIngredients(recipeID); len(ingredients) != 0 {
+		t.Errorf("rejected update saved ingredients %+v", ingredients)
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/utils"
+	"unicode/utf8"
+)
+
+// Comment Handlers
+
+const maxCommentLength = 1000
+
+type CommentRequest struct {
+	Body string `json:"body"`
+}
+
+func GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_COMMENTS", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	comments, err := database.GetRecipeComments(recipeID, viewerID(r))
+	if err != nil {
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch comments")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, comments)
+}
+
+func AddCommentHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_COMMENT", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_COMMENT", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	body := utils.SanitizeInput(req.Body)
+	if body == "" {
+		sendJSONError(w, http.StatusBadRequest, "Comment cannot be empty")
+		return
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		sendJSONError(w, http.StatusBadRequest, fmt.Sprintf("Comment is too long (maximum %d characters)", maxCommentLength))
+		return
+	}
+
+	commentID, err := database.AddComment(recipeID, user.ID, body)
+	if err != nil {
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "COMMENT_ADD_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to add comment")
+		return
+	}
+
+	utils.LogSecurityEvent(r.Context(), "COMMENT_ADDED", clientIP, fmt.Sprintf("CommentID:%d, RecipeID:%d, User:%s", commentID, recipeID, user.Username))
+	sendJSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"message": "Comment added successfully",
+		"data": map[string]interface{}{
+			"comment_id": commentID,
+		},
+	})
+}
+
+func DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	commentID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_COMMENT_ID_DELETE", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	if err := database.DeleteComment(commentID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, database.ErrCommentNotFound):
+			sendJSONError(w, http.StatusNotFound, "Comment not found")
+		case errors.Is(err, database.ErrCommentAccessDenied):
+			utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_COMMENT_DELETE", clientIP, user.ID, fmt.Sprintf("UserID: %d, CommentID: %d", user.ID, commentID))
+			sendJSONError(w, http.StatusForbidden, "Access denied")
+		default:
+			utils.LogSecurityEvent(r.Context(), "COMMENT_DELETE_ERROR", clientIP, err.Error())
+			sendJSONError(w, http.StatusInternalServerError, "Failed to delete comment")
+		}
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "COMMENT_DELETED", clientIP, user.ID, fmt.Sprintf("CommentID:%d, User:%s", commentID, user.Username))
+	sendJSONSuccess(w, "Comment deleted successfully", nil)
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import "net/http"
+
+// DefaultMaxImagesPerRecipe is how many images a recipe can have unless configured otherwise
+const DefaultMaxImagesPerRecipe = 10
+
+var maxImagesPerRecipe = DefaultMaxImagesPerRecipe
+
+// SetMaxImagesPerRecipe changes how many images a recipe can have, see MAX_IMAGES_PER_RECIPE
+func SetMaxImagesPerRecipe(limit int) {
+	maxImagesPerRecipe = limit
+}
+
+// ConfigHandler returns the limits the frontend needs to know about
+func ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"max_images_per_recipe": maxImagesPerRecipe,
+	})
+}
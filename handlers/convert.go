@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"recipe-book/utils"
+	"strconv"
+	"strings"
+)
+
+func ConvertUnitHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from := strings.TrimSpace(query.Get("from"))
+	to := strings.TrimSpace(query.Get("to"))
+
+	if from == "" || to == "" {
+		sendJSONError(w, http.StatusBadRequest, "Both from and to units are required")
+		return
+	}
+
+	quantity, err := strconv.ParseFloat(query.Get("quantity"), 64)
+	if err != nil || math.IsNaN(quantity) || math.IsInf(quantity, 0) {
+		sendJSONError(w, http.StatusBadRequest, "Quantity must be a number")
+		return
+	}
+
+	quantityValidation := utils.ValidateQuantity(quantity)
+	if !quantityValidation.Valid {
+		sendJSONValidationError(w, quantityValidation)
+		return
+	}
+
+	result, err := utils.ConvertUnit(quantity, from, to)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"quantity": quantity,
+		"from":     from,
+		"to":       to,
+		"result":   math.Round(result*1000) / 1000,
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strings"
+	"time"
+)
+
+// emailVerificationTTL is how long an email verification token stays valid
+const emailVerificationTTL = 24 * time.Hour
+
+var verificationSender utils.EmailVerificationSender = utils.LogMailer{}
+
+// requireEmailVerification blocks unverified users from creating recipes, see REQUIRE_EMAIL_VERIFICATION
+var requireEmailVerification bool
+
+// SetEmailVerificationSender replaces how email verification tokens are delivered
+func SetEmailVerificationSender(sender utils.EmailVerificationSender) {
+	verificationSender = sender
+}
+
+// SetRequireEmailVerification sets whether users must verify their email before creating recipes
+func SetRequireEmailVerification(required bool) {
+	requireEmailVerification = required
+}
+
+// sendVerificationEmail creates a fresh verification token for the user and sends it
+func sendVerificationEmail(userID int, email, username string) error {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	if err := database.CreateEmailVerificationToken(userID, token, time.Now().Add(emailVerificationTTL)); err != nil {
+		return err
+	}
+
+	return verificationSender.SendEmailVerification(email, username, token)
+}
+
+// requireVerifiedEmail responds with 403 and returns false when email verification is
+// required and the user has not verified their address yet
+func requireVerifiedEmail(ctx context.Context, w http.ResponseWriter, user *models.User, clientIP string) bool {
+	if !requireEmailVerification || user.EmailVerified {
+		return true
+	}
+
+	utils.LogUserSecurityEvent(ctx, "UNVERIFIED_EMAIL_BLOCKED", clientIP, user.ID, fmt.Sprintf("UserID: %d", user.ID))
+	sendJSONError(w, http.StatusForbidden, "Please verify your email address before creating recipes")
+	return false
+}
+
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	token := strings.TrimSpace(r.URL.Query().Get("token"))
+	if token == "" {
+		sendJSONError(w, http.StatusBadRequest, "Verification token is required")
+		return
+	}
+
+	userID, err := database.VerifyEmail(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrVerificationTokenInvalid):
+			utils.LogSecurityEvent(r.Context(), "EMAIL_VERIFICATION_INVALID_TOKEN", clientIP, "Unknown token")
+			sendJSONError(w, http.StatusBadRequest, "Invalid verification link")
+		case errors.Is(err, database.ErrVerificationTokenUsed):
+			sendJSONError(w, http.StatusConflict, "This verification link has already been used")
+		case errors.Is(err, database.ErrVerificationTokenExpired):
+			sendJSONError(w, http.StatusGone, "This verification link has expired, please request a new one")
+		default:
+			utils.LogSecurityEvent(r.Context(), "EMAIL_VERIFICATION_ERROR", clientIP, err.Error())
+			sendJSONError(w, http.StatusInternalServerError, "Error verifying email")
+		}
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "EMAIL_VERIFIED", clientIP, userID, fmt.Sprintf("UserID: %d", userID))
+	sendJSONSuccess(w, "Email address verified", nil)
+}
+
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	if user.EmailVerified {
+		sendJSONError(w, http.StatusConflict, "Email address is already verified")
+		return
+	}
+
+	if err := sendVerificationEmail(user.ID, user.Email, user.Username); err != nil {
+		utils.LogUserSecurityEvent(r.Context(), "EMAIL_VERIFICATION_SEND_ERROR", clientIP, user.ID, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Error sending verification email")
+		return
+	}
+
+	sendJSONSuccess(w, "Verification email sent", nil)
+}
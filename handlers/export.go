@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/utils"
+)
+
+func ExportRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_EXPORT", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "markdown"
+	}
+	if format != "markdown" {
+		sendJSONError(w, http.StatusBadRequest, "Unsupported export format")
+		return
+	}
+
+	recipe, err := database.GetRecipeByIDSecure(recipeID, viewerID(r))
+	if err != nil {
+		sendJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+
+	filename := utils.SlugifyTitle(recipe.Title) + ".md"
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(utils.FormatRecipeMarkdown(recipe)))
+}
+
+// exportBatchSize is how many recipes ExportMyRecipesHandler loads per query
+const exportBatchSize = 100
+
+// ExportMyRecipesHandler streams all of the authenticated user's recipes, including
+// private ones and drafts, as a CSV file with one row per recipe
+func ExportMyRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" {
+		sendJSONError(w, http.StatusBadRequest, "Unsupported export format")
+		return
+	}
+
+	// Load the first batch before writing anything so a failure can still be reported as JSON
+	recipes, err := database.GetRecipesByUser(user.ID, "oldest", exportBatchSize, 0)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="recipes.csv"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write(utils.RecipeCSVHeader)
+
+	for offset := 0; len(recipes) > 0; {
+		for i := range recipes {
+			writer.Write(utils.FormatRecipeCSVRow(&recipes[i]))
+		}
+		writer.Flush()
+		if writer.Error() != nil || len(recipes) < exportBatchSize {
+			break
+		}
+
+		offset += len(recipes)
+		recipes, err = database.GetRecipesByUser(user.ID, "oldest", exportBatchSize, offset)
+		if err != nil {
+			// The status has been sent already, so the file just ends early
+			log.Printf("Error exporting recipes for user %d: %v", user.ID, err)
+			break
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// Favorite Handlers
+
+func AddFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_FAVORITE", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	if err := database.AddFavorite(user.ID, recipeID); err != nil {
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "FAVORITE_ADD_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to add favorite")
+		return
+	}
+
+	utils.LogSecurityEvent(r.Context(), "FAVORITE_ADDED", clientIP, fmt.Sprintf("RecipeID:%d, User:%s", recipeID, user.Username))
+	sendJSONSuccess(w, "Recipe added to favorites", map[string]interface{}{
+		"recipe_id": recipeID,
+	})
+}
+
+func RemoveFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_FAVORITE", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	if err := database.RemoveFavorite(user.ID, recipeID); err != nil {
+		utils.LogSecurityEvent(r.Context(), "FAVORITE_REMOVE_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to remove favorite")
+		return
+	}
+
+	utils.LogSecurityEvent(r.Context(), "FAVORITE_REMOVED", clientIP, fmt.Sprintf("RecipeID:%d, User:%s", recipeID, user.Username))
+	sendJSONSuccess(w, "Recipe removed from favorites", nil)
+}
+
+func GetFavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	recipes, err := database.GetFavoriteRecipes(user.ID)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch favorites")
+		return
+	}
+
+	if recipes == nil {
+		recipes = []models.Recipe{}
+	}
+
+	sendJSONResponse(w, http.StatusOK, recipes)
+}
@@ -1,36 +1,49 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"net"
 	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strconv"
 	"strings"
+
+	"github.com/gorilla/mux"
 )
 
-// Helper function to get client IP with proper header checking
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (for reverse proxies)
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
+// Pagination defaults for list endpoints. maxOffset bounds how far into a list a page
+// may start, which keeps page*per_page from overflowing.
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+	maxOffset      = 100_000
+)
 
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return strings.TrimSpace(xri)
+// viewerID returns the logged-in user's ID, or 0 for anonymous requests. It is used
+// to decide which private recipes the caller may see.
+func viewerID(r *http.Request) int {
+	if user, err := auth.GetUserFromToken(r); err == nil {
+		return user.ID
 	}
+	return 0
+}
 
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+// Helper function to parse a positive integer ID from the route variables.
+// Returns the raw value for logging when it is invalid.
+func parseRouteID(r *http.Request) (int, string, bool) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(idStr)
+	if err != nil || !utils.IsValidID(id) {
+		return 0, idStr, false
 	}
-	return ip
+	return id, idStr, true
 }
 
 // Helper function to send JSON response
@@ -42,11 +55,130 @@ func sendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
 	}
 }
 
+// sendJSONWithETag sends data with an ETag derived from etagSource, the part of the
+// response whose changes clients need to see; pass data itself when that is all of it.
+// When the request's If-None-Match already lists that ETag, only 304 Not Modified is sent.
+// It reports whether data was sent.
+func sendJSONWithETag(w http.ResponseWriter, r *http.Request, data, etagSource interface{}) bool {
+	body, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+		sendJSONError(w, http.StatusInternalServerError, "Error encoding response")
+		return false
+	}
+	body = append(body, '\n')
+
+	versioned, err := json.Marshal(etagSource)
+	if err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+		sendJSONError(w, http.StatusInternalServerError, "Error encoding response")
+		return false
+	}
+	sum := sha256.Sum256(versioned)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	// Responses can depend on who is asking, so only the client may cache them, and it must revalidate
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Del("Pragma")
+	w.Header().Del("Expires")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag, comparing weakly
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // Helper function to send JSON error response
 func sendJSONError(w http.ResponseWriter, statusCode int, message string) {
 	sendJSONResponse(w, statusCode, map[string]string{"error": message})
 }
 
+// sendJSONValidationError sends a 400 with the validation message and, when known, the
+// request field it refers to, so that the frontend can highlight the offending input
+func sendJSONValidationError(w http.ResponseWriter, validation utils.ValidationResult) {
+	response := map[string]string{"error": validation.Message}
+	if validation.Field != "" {
+		response["field"] = validation.Field
+	}
+	sendJSONResponse(w, http.StatusBadRequest, response)
+}
+
+// FieldError is one failed validation in a response listing several of them
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// sendJSONValidationErrors sends a 400 listing every failed validation under "errors".
+// The first failure is also sent as "error" and "field", as sendJSONValidationError
+// would, for clients that only show one message.
+func sendJSONValidationErrors(w http.ResponseWriter, failures []utils.ValidationResult) {
+	errs := make([]FieldError, len(failures))
+	for i, failure := range failures {
+		errs[i] = FieldError{Field: failure.Field, Message: failure.Message}
+	}
+
+	response := map[string]interface{}{
+		"error":  failures[0].Message,
+		"errors": errs,
+	}
+	if failures[0].Field != "" {
+		response["field"] = failures[0].Field
+	}
+	sendJSONResponse(w, http.StatusBadRequest, response)
+}
+
+// validationError is a failed validation returned as an error, keeping the field it refers to
+type validationError struct {
+	utils.ValidationResult
+}
+
+func (e validationError) Error() string {
+	return e.Message
+}
+
+// validationErrors are all of a request's failed validations returned as one error.
+// It must not be empty.
+type validationErrors []utils.ValidationResult
+
+func (e validationErrors) Error() string {
+	return e[0].Message
+}
+
+// sendRequestError sends validation errors as a 400 naming the offending fields. Any
+// other error is a server failure, sent as a 500 with the failure message.
+func sendRequestError(w http.ResponseWriter, err error, failure string) {
+	var failures validationErrors
+	if errors.As(err, &failures) {
+		sendJSONValidationErrors(w, failures)
+		return
+	}
+
+	var validation validationError
+	if errors.As(err, &validation) {
+		sendJSONValidationError(w, validation.ValidationResult)
+		return
+	}
+	sendJSONError(w, http.StatusInternalServerError, failure)
+}
+
 // Helper function to send JSON success response
 func sendJSONSuccess(w http.ResponseWriter, message string, data interface{}) {
 	response := map[string]interface{}{
@@ -58,3 +190,82 @@ func sendJSONSuccess(w http.ResponseWriter, message string, data interface{}) {
 	}
 	sendJSONResponse(w, http.StatusOK, response)
 }
+
+// Helper function to parse pagination query parameters.
+// Accepts either limit/offset or page/per_page; limit/offset take precedence.
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	query := r.URL.Query()
+	limit = defaultPerPage
+
+	if v := query.Get("per_page"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("per_page must be a positive integer")
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("limit must be a positive integer")
+		}
+	}
+	if limit > maxPerPage {
+		limit = maxPerPage
+	}
+
+	if v := query.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("page must be a positive integer")
+		}
+		if page > maxOffset/limit+1 {
+			return 0, 0, fmt.Errorf("page must be at most %d", maxOffset/limit+1)
+		}
+		offset = (page - 1) * limit
+	}
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		if offset > maxOffset {
+			return 0, 0, fmt.Errorf("offset must be at most %d", maxOffset)
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// Helper function to parse the sort query parameter. Unknown keys fall back to the
+// default order unless strict=true is given, in which case an error is returned.
+func parseSort(r *http.Request) (string, error) {
+	sortKey := r.URL.Query().Get("sort")
+	if sortKey == "" || database.IsValidRecipeSort(sortKey) {
+		return sortKey, nil
+	}
+
+	if strict, _ := strconv.ParseBool(r.URL.Query().Get("strict")); strict {
+		return "", validationError{utils.ValidationResult{
+			Valid:   false,
+			Message: fmt.Sprintf("Invalid sort %q, expected one of: %s", sortKey, strings.Join(database.RecipeSortKeys, ", ")),
+			Field:   "sort",
+		}}
+	}
+	return database.DefaultRecipeSort, nil
+}
+
+// Helper function to send a page of recipes with pagination metadata
+func sendPaginatedResponse(w http.ResponseWriter, recipes []models.Recipe, total, limit, offset int) {
+	sendJSONResponse(w, http.StatusOK, paginatedRecipes(recipes, total, limit, offset))
+}
+
+// paginatedRecipes builds the {results, total, page, per_page} payload for a page of recipes
+func paginatedRecipes(recipes []models.Recipe, total, limit, offset int) map[string]interface{} {
+	if recipes == nil {
+		recipes = []models.Recipe{}
+	}
+
+	return map[string]interface{}{
+		"results":  recipes,
+		"total":    total,
+		"page":     offset/limit + 1,
+		"per_page": limit,
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantLimit  int
+		wantOffset int
+		wantErr    string
+	}{
+		{"", defaultPerPage, 0, ""},
+		{"page=3&per_page=10", 10, 20, ""},
+		{"per_page=1000", maxPerPage, 0, ""},
+		{"limit=5&offset=15", 5, 15, ""},
+		{"page=2&offset=7", defaultPerPage, 7, ""},
+		{"page=1001&per_page=100", 100, maxOffset, ""},
+		{"offset=100000", defaultPerPage, maxOffset, ""},
+		{"page=0", 0, 0, "page must be a positive integer"},
+		{"per_page=-1", 0, 0, "per_page must be a positive integer"},
+		{"limit=ten", 0, 0, "limit must be a positive integer"},
+		{"offset=-5", 0, 0, "offset must be a non-negative integer"},
+		{"page=1002&per_page=100", 0, 0, "page must be at most 1001"},
+		{"offset=100001", 0, 0, "offset must be at most 100000"},
+		// Would overflow to a negative offset without the bound
+		{"page=4611686018427387905&per_page=4", 0, 0, "page must be at most 25001"},
+		{"offset=9223372036854775807", 0, 0, "offset must be at most 100000"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/recipes?"+tt.query, nil)
+		limit, offset, err := parsePagination(r)
+
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: got error %v, want %q", tt.query, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || limit != tt.wantLimit || offset != tt.wantOffset {
+			t.Errorf("%q: got limit %d, offset %d, error %v; want %d, %d", tt.query, limit, offset, err, tt.wantLimit, tt.wantOffset)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strings"
+)
+
+// ImportRecipeHandler creates a recipe from a JSON document in the RecipeRequest shape.
+// Ingredients may be referenced by name; unknown names are created on the fly.
+func ImportRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	if !requireVerifiedEmail(r.Context(), w, user, clientIP) {
+		return
+	}
+
+	var req RecipeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_RECIPE_IMPORT", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	if err := validateRecipeRequest(r.Context(), &req, clientIP, "RECIPE_IMPORT_VALIDATION_FAILED"); err != nil {
+		sendRequestError(w, err, "Failed to import recipe")
+		return
+	}
+
+	createdIngredients, err := resolveIngredientNames(r.Context(), req.Ingredients, clientIP)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ingredients, err := validIngredientLines(r.Context(), req.Ingredients, clientIP, "_IMPORT")
+	if err != nil {
+		sendRequestError(w, err, "Failed to import recipe")
+		return
+	}
+
+	recipe := &models.Recipe{
+		Title:         req.Title,
+		Description:   req.Description,
+		Instructions:  req.Instructions,
+		PrepTime:      req.PrepTime,
+		CookTime:      req.CookTime,
+		Servings:      req.Servings,
+		ServingUnit:   req.ServingUnit,
+		YieldQuantity: req.YieldQuantity,
+		YieldUnit:     req.YieldUnit,
+		Cuisine:       req.Cuisine,
+		SourceURL:     req.SourceURL,
+		VideoURL:      req.VideoURL,
+		IsPublic:      req.IsPublic == nil || *req.IsPublic,
+		Status:        req.Status,
+		CreatedBy:     user.ID,
+		Ingredients:   ingredients,
+	}
+
+	recipeID, err := database.CreateRecipeWithRelations(recipe, validTagIDs(r.Context(), req.Tags, clientIP, "INVALID_TAG_ID_IMPORT"))
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "RECIPE_IMPORT_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to import recipe")
+		return
+	}
+
+	if createdIngredients == nil {
+		createdIngredients = []string{}
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "RECIPE_IMPORTED", clientIP, user.ID, fmt.Sprintf("RecipeID:%d, Title:%s, NewIngredients:%d, User:%s",
+		recipeID, req.Title, len(createdIngredients), user.Username))
+
+	sendJSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"message": "Recipe imported successfully",
+		"data": map[string]interface{}{
+			"recipe_id":           recipeID,
+			"created_ingredients": createdIngredients,
+		},
+	})
+}
+
+// resolveIngredientNames fills in IngredientID for lines that only carry a name,
+// creating missing ingredients. It returns the names of ingredients it created.
+func resolveIngredientNames(ctx context.Context, lines []RecipeIngredientReq, clientIP string) ([]string, error) {
+	var created []string
+
+	for i := range lines {
+		name := strings.TrimSpace(lines[i].Name)
+		if lines[i].IngredientID != 0 || name == "" {
+			continue
+		}
+
+		ingredient, err := database.GetIngredientByName(name)
+		if err == sql.ErrNoRows {
+			createErr := database.CreateIngredientSecure(name, utils.DefaultIngredientCategory)
+			// Another request may have just created it, which is as good
+			if createErr != nil && !database.IsUniqueViolation(createErr) {
+				utils.LogSecurityEvent(ctx, "INGREDIENT_IMPORT_ERROR", clientIP, fmt.Sprintf("Name: %s, Error: %v", name, createErr))
+				return nil, fmt.Errorf("could not create ingredient %q", name)
+			}
+			if createErr == nil {
+				created = append(created, name)
+			}
+			ingredient, err = database.GetIngredientByName(name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve ingredient %q", name)
+		}
+
+		lines[i].IngredientID = ingredient.ID
+	}
+
+	return created, nil
+}
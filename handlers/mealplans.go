@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strings"
+	"time"
+)
+
+// Meal Plan Handlers
+
+const (
+	// defaultMealPlanDays is the range returned when no end date is given: one week
+	defaultMealPlanDays = 7
+	// maxMealPlanDays caps how many days can be fetched at once
+	maxMealPlanDays = 92
+)
+
+type MealPlanRequest struct {
+	RecipeID int    `json:"recipe_id"`
+	PlanDate string `json:"plan_date"` // YYYY-MM-DD
+	MealType string `json:"meal_type"`
+}
+
+func parsePlanDate(value, field string) (time.Time, error) {
+	date, err := time.Parse(database.MealPlanDateLayout, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date in YYYY-MM-DD format", field)
+	}
+	return date, nil
+}
+
+// parseMealPlanRange reads the from/to query parameters. from defaults to today and
+// to defaults to the end of the week starting at from.
+func parseMealPlanRange(r *http.Request) (from, to time.Time, err error) {
+	query := r.URL.Query()
+
+	from, _ = time.Parse(database.MealPlanDateLayout, time.Now().Format(database.MealPlanDateLayout))
+	if v := query.Get("from"); v != "" {
+		if from, err = parsePlanDate(v, "from"); err != nil {
+			return from, to, err
+		}
+	}
+
+	to = from.AddDate(0, 0, defaultMealPlanDays-1)
+	if v := query.Get("to"); v != "" {
+		if to, err = parsePlanDate(v, "to"); err != nil {
+			return from, to, err
+		}
+	}
+
+	if to.Before(from) {
+		return from, to, fmt.Errorf("to must not be before from")
+	}
+	if to.Sub(from) >= maxMealPlanDays*24*time.Hour {
+		return from, to, fmt.Errorf("date range is too long (maximum %d days)", maxMealPlanDays)
+	}
+
+	return from, to, nil
+}
+
+// groupMealPlansByDay groups entries that are already ordered by date
+func groupMealPlansByDay(entries []models.MealPlanEntry) []models.MealPlanDay {
+	days := []models.MealPlanDay{}
+	for _, entry := range entries {
+		if len(days) == 0 || days[len(days)-1].Date != entry.PlanDate {
+			days = append(days, models.MealPlanDay{Date: entry.PlanDate})
+		}
+		last := &days[len(days)-1]
+		last.Entries = append(last.Entries, entry)
+	}
+	return days
+}
+
+func GetMealPlansHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	from, to, err := parseMealPlanRange(r)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := database.GetMealPlans(user.ID, from, to)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch meal plans")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"from": from.Format(database.MealPlanDateLayout),
+		"to":   to.Format(database.MealPlanDateLayout),
+		"days": groupMealPlansByDay(entries),
+	})
+}
+
+func AddMealPlanHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	var req MealPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_MEAL_PLAN", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	if !utils.IsValidID(req.RecipeID) {
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	planDate, err := parsePlanDate(req.PlanDate, "plan_date")
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req.MealType = strings.ToLower(strings.TrimSpace(req.MealType))
+	if validation := utils.ValidateMealType(req.MealType); !validation.Valid {
+		sendJSONValidationError(w, validation)
+		return
+	}
+
+	entryID, err := database.AddMealPlan(user.ID, req.RecipeID, planDate, req.MealType)
+	if err != nil {
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			sendJSONError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "MEAL_PLAN_ADD_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to add meal plan entry")
+		return
+	}
+
+	utils.LogSecurityEvent(r.Context(), "MEAL_PLAN_ADDED", clientIP, fmt.Sprintf("EntryID:%d, RecipeID:%d, User:%s", entryID, req.RecipeID, user.Username))
+	sendJSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"success": true,
+		"message": "Meal plan entry added successfully",
+		"data": map[string]interface{}{
+			"meal_plan_id": entryID,
+		},
+	})
+}
+
+func DeleteMealPlanHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	entryID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_MEAL_PLAN_ID_DELETE", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid meal plan ID")
+		return
+	}
+
+	if err := database.DeleteMealPlan(entryID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, database.ErrMealPlanNotFound):
+			sendJSONError(w, http.StatusNotFound, "Meal plan entry not found")
+		case errors.Is(err, database.ErrMealPlanAccessDenied):
+			utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_MEAL_PLAN_DELETE", clientIP, user.ID, fmt.Sprintf("UserID: %d, EntryID: %d", user.ID, entryID))
+			sendJSONError(w, http.StatusForbidden, "Access denied")
+		default:
+			utils.LogSecurityEvent(r.Context(), "MEAL_PLAN_DELETE_ERROR", clientIP, err.Error())
+			sendJSONError(w, http.StatusInternalServerError, "Failed to delete meal plan entry")
+		}
+		return
+	}
+
+	utils.LogSecurityEvent(r.Context(), "MEAL_PLAN_DELETED", clientIP, fmt.Sprintf("EntryID:%d, User:%s", entryID, user.Username))
+	sendJSONSuccess(w, "Meal plan entry deleted successfully", nil)
+}
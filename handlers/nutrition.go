@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// Upper bounds for nutritional values of a whole recipe
+const (
+	maxCalories = 50000
+	maxGrams    = 5000
+)
+
+// validateNutrition checks every provided value; omitted values are left unset
+func validateNutrition(nutrition models.Nutrition) error {
+	fields := []struct {
+		value *float64
+		max   float64
+		name  string
+		key   string
+	}{
+		{nutrition.Calories, maxCalories, "Calories", "calories"},
+		{nutrition.ProteinG, maxGrams, "Protein", "protein_g"},
+		{nutrition.CarbsG, maxGrams, "Carbs", "carbs_g"},
+		{nutrition.FatG, maxGrams, "Fat", "fat_g"},
+	}
+
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		}
+		if validation := utils.ValidateNutritionValue(*field.value, field.max, field.name); !validation.Valid {
+			validation.Field = field.key
+			return validationError{validation}
+		}
+	}
+
+	return nil
+}
+
+func SetNutritionHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	clientIP := utils.ClientIP(r)
+
+	id, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_NUTRITION", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	var nutrition models.Nutrition
+	if err := json.NewDecoder(r.Body).Decode(&nutrition); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_NUTRITION", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	if err := validateNutrition(nutrition); err != nil {
+		sendRequestError(w, err, "Failed to update nutrition")
+		return
+	}
+
+	if err := database.SetRecipeNutrition(id, user.ID, nutrition); err != nil {
+		if errors.Is(err, database.ErrRecipeNotFound) {
+			utils.LogUserSecurityEvent(r.Context(), "UNAUTHORIZED_RECIPE_NUTRITION", clientIP, user.ID, fmt.Sprintf("UserID: %d, RecipeID: %d", user.ID, id))
+			sendJSONError(w, http.StatusForbidden, "Recipe not found or access denied")
+		} else {
+			utils.LogSecurityEvent(r.Context(), "RECIPE_NUTRITION_ERROR", clientIP, err.Error())
+			sendJSONError(w, http.StatusInternalServerError, "Failed to update nutrition")
+		}
+		return
+	}
+
+	database.TouchRecipe(id)
+	utils.LogSecurityEvent(r.Context(), "RECIPE_NUTRITION_UPDATED", clientIP, fmt.Sprintf("RecipeID:%d, User:%s", id, user.Username))
+	sendJSONSuccess(w, "Nutrition updated", nutrition)
+}
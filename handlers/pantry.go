@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strconv"
+)
+
+// maxPantryIngredients caps how many ingredient IDs one request can send
+const maxPantryIngredients = 200
+
+// FindRecipesByIngredientsHandler ranks recipes by how many of their ingredients are in
+// the posted list of ingredient IDs. ?max_missing=N drops recipes lacking more than N.
+func FindRecipesByIngredientsHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	maxMissing := -1
+	if value := r.URL.Query().Get("max_missing"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			sendJSONError(w, http.StatusBadRequest, "max_missing must be a non-negative whole number")
+			return
+		}
+		maxMissing = n
+	}
+
+	var ingredientIDs []int
+	if err := json.NewDecoder(r.Body).Decode(&ingredientIDs); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_BY_INGREDIENTS", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Expected a JSON array of ingredient IDs")
+		return
+	}
+
+	if len(ingredientIDs) == 0 {
+		sendJSONError(w, http.StatusBadRequest, "At least one ingredient is required")
+		return
+	}
+	if len(ingredientIDs) > maxPantryIngredients {
+		sendJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d ingredients can be sent", maxPantryIngredients))
+		return
+	}
+
+	for _, ingredientID := range ingredientIDs {
+		if !utils.IsValidID(ingredientID) {
+			utils.LogSecurityEvent(r.Context(), "INVALID_INGREDIENT_ID_BY_INGREDIENTS", clientIP, fmt.Sprintf("%d", ingredientID))
+			sendJSONError(w, http.StatusBadRequest, "Invalid ingredient ID")
+			return
+		}
+	}
+
+	matches, err := database.FindRecipesByAvailableIngredients(ingredientIDs, viewerID(r))
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "BY_INGREDIENTS_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to find recipes")
+		return
+	}
+
+	if maxMissing >= 0 {
+		filtered := []models.RecipeMatch{}
+		for _, match := range matches {
+			if len(match.MissingIngredients) <= maxMissing {
+				filtered = append(filtered, match)
+			}
+		}
+		matches = filtered
+	}
+
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"results": matches,
+		"count":   len(matches),
+	})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/utils"
+	"strings"
+	"time"
+)
+
+// passwordResetTTL is how long a password reset token stays valid
+const passwordResetTTL = time.Hour
+
+// forgotPasswordMessage is sent whether or not the email belongs to an account,
+// so the endpoint cannot be used to find out which emails are registered
+const forgotPasswordMessage = "If an account with that email exists, a password reset link has been sent"
+
+var resetSender utils.PasswordResetSender = utils.LogMailer{}
+
+// SetPasswordResetSender replaces how password reset tokens are delivered
+func SetPasswordResetSender(sender utils.PasswordResetSender) {
+	resetSender = sender
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_FORGOT_PASSWORD", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if validation := utils.ValidateEmail(req.Email); !validation.Valid {
+		sendJSONValidationError(w, validation)
+		return
+	}
+
+	user, err := database.GetUserByEmail(req.Email)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "PASSWORD_RESET_UNKNOWN_EMAIL", clientIP, req.Email)
+		sendJSONSuccess(w, forgotPasswordMessage, nil)
+		return
+	}
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "PASSWORD_RESET_TOKEN_ERROR", clientIP, err.Error())
+		sendJSONSuccess(w, forgotPasswordMessage, nil)
+		return
+	}
+
+	if err := database.CreatePasswordResetToken(user.ID, token, time.Now().Add(passwordResetTTL)); err != nil {
+		utils.LogSecurityEvent(r.Context(), "PASSWORD_RESET_TOKEN_ERROR", clientIP, err.Error())
+		sendJSONSuccess(w, forgotPasswordMessage, nil)
+		return
+	}
+
+	if err := resetSender.SendPasswordReset(user.Email, user.Username, token); err != nil {
+		utils.LogUserSecurityEvent(r.Context(), "PASSWORD_RESET_SEND_ERROR", clientIP, user.ID, err.Error())
+	} else {
+		utils.LogUserSecurityEvent(r.Context(), "PASSWORD_RESET_REQUESTED", clientIP, user.ID, fmt.Sprintf("UserID: %d", user.ID))
+	}
+
+	sendJSONSuccess(w, forgotPasswordMessage, nil)
+}
+
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_RESET_PASSWORD", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		sendJSONError(w, http.StatusBadRequest, "Reset token is required")
+		return
+	}
+
+	if validation := utils.ValidatePassword(req.Password); !validation.Valid {
+		sendJSONValidationError(w, validation)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "PASSWORD_HASH_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Error processing password")
+		return
+	}
+
+	userID, err := database.ResetPassword(req.Token, string(hashedPassword))
+	if err != nil {
+		if errors.Is(err, database.ErrResetTokenInvalid) {
+			utils.LogSecurityEvent(r.Context(), "PASSWORD_RESET_INVALID_TOKEN", clientIP, "Invalid or expired token")
+			sendJSONError(w, http.StatusBadRequest, "Invalid or expired reset token")
+			return
+		}
+		utils.LogSecurityEvent(r.Context(), "PASSWORD_RESET_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Error resetting password")
+		return
+	}
+
+	utils.LogUserSecurityEvent(r.Context(), "PASSWORD_RESET_COMPLETED", clientIP, userID, fmt.Sprintf("UserID: %d", userID))
+	sendJSONSuccess(w, "Password has been reset, you can now log in", nil)
+}
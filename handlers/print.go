@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// PrintRecipeHandler renders a recipe as a plain HTML page meant for printing,
+// without the navigation and scripts of the app
+func PrintRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	recipeID, _, ok := parseRouteID(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := models.PageData{}
+	if user, err := auth.GetUserFromToken(r); err == nil {
+		data.User = user
+		data.IsLoggedIn = true
+	}
+
+	viewer := 0
+	if data.User != nil {
+		viewer = data.User.ID
+	}
+
+	// Private recipes and drafts are reported as missing to everyone but their owner
+	recipe, err := database.GetRecipeByIDSecure(recipeID, viewer)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	data.Recipe = recipe
+	data.Title = recipe.Title
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The page can show a private recipe, so it must not be stored by shared caches
+	w.Header().Set("Cache-Control", "private, no-store")
+	if err := utils.Templates.ExecuteTemplate(w, "print.html", data); err != nil {
+		log.Printf("Error rendering print view of recipe %d: %v", recipeID, err)
+	}
+}
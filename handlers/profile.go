@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/utils"
+)
+
+func GetUserProfileHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	userID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_USER_ID_PROFILE", clientIP, idStr)
+		sendJSONError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	profile, err := database.GetUserPublicProfile(userID)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			sendJSONError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+
+	// Email is private to the user themselves
+	if viewerID(r) != profile.ID {
+		profile.Email = ""
+	}
+
+	recipes, err := database.GetUserPublicRecipes(userID, limit, offset)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"profile": profile,
+		"recipes": paginatedRecipes(recipes, profile.RecipeCount, limit, offset),
+	})
+}
+
+func GetMyRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sortKey, err := parseSort(r)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	total, err := database.CountRecipesByUser(user.ID)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
+		return
+	}
+
+	recipes, err := database.GetRecipesByUser(user.ID, sortKey, limit, offset)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch recipes")
+		return
+	}
+
+	sendPaginatedResponse(w, recipes, total, limit, offset)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strconv"
+)
+
+const (
+	// defaultRelatedRecipes is how many recipes GET /api/recipes/{id}/related returns without a limit
+	defaultRelatedRecipes = 5
+	// maxRelatedRecipes caps the limit of GET /api/recipes/{id}/related
+	maxRelatedRecipes = 20
+)
+
+// RelatedRecipesHandler lists the recipes sharing the most tags and ingredients with a recipe
+func RelatedRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_RELATED", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	limit := defaultRelatedRecipes
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			sendJSONError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		if limit > maxRelatedRecipes {
+			limit = maxRelatedRecipes
+		}
+	}
+
+	recipes, err := database.GetRelatedRecipes(recipeID, limit, viewerID(r))
+	if errors.Is(err, database.ErrRecipeNotFound) {
+		sendJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "RELATED_RECIPES_FETCH_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch related recipes")
+		return
+	}
+
+	if recipes == nil {
+		recipes = []models.Recipe{}
+	}
+
+	sendJSONResponse(w, http.StatusOK, recipes)
+}
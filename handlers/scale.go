@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+	"strconv"
+)
+
+// ScaledRecipe is a recipe with ingredient quantities adjusted to a new serving count
+type ScaledRecipe struct {
+	*models.Recipe
+	OriginalServings int     `json:"original_servings"`
+	ScaleFactor      float64 `json:"scale_factor"`
+}
+
+// scaleRecipe returns a scaled copy of the recipe, yield included; the original is not modified
+func scaleRecipe(recipe *models.Recipe, servings int) ScaledRecipe {
+	factor := float64(servings) / float64(recipe.Servings)
+
+	scaled := *recipe
+	scaled.Servings = servings
+	scaled.YieldQuantity = utils.ScaleQuantity(recipe.YieldQuantity, factor)
+	scaled.Ingredients = make([]models.RecipeIngredient, len(recipe.Ingredients))
+	for i, ingredient := range recipe.Ingredients {
+		ingredient.Quantity = utils.ScaleQuantity(ingredient.Quantity, factor)
+		ingredient.QuantityDisplay = utils.FormatQuantity(ingredient.Quantity)
+		scaled.Ingredients[i] = ingredient
+	}
+
+	return ScaledRecipe{
+		Recipe:           &scaled,
+		OriginalServings: recipe.Servings,
+		ScaleFactor:      utils.ScaleQuantity(factor, 1),
+	}
+}
+
+func ScaleRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_SCALE", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	servings, err := strconv.Atoi(r.URL.Query().Get("servings"))
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, "Servings must be a whole number")
+		return
+	}
+
+	servingsValidation := utils.ValidateNumericInput(servings, 1, 100, "Servings")
+	if !servingsValidation.Valid {
+		sendJSONValidationError(w, servingsValidation)
+		return
+	}
+
+	recipe, err := database.GetRecipeByIDSecure(recipeID, viewerID(r))
+	if err != nil {
+		sendJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+
+	if recipe.Servings < 1 {
+		sendJSONError(w, http.StatusUnprocessableEntity, "Recipe has no serving count to scale from")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, scaleRecipe(recipe, servings))
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"recipe-book/models"
+	"testing"
+)
+
+func TestScaleRecipeDisplaysFractions(t *testing.T) {
+	recipe := &models.Recipe{
+		Servings: 3,
+		Ingredients: []models.RecipeIngredient{
+			{Name: "Flour", Quantity: 1, Unit: "cup"},
+			{Name: "Butter", Quantity: 0.5, Unit: "cup"},
+			{Name: "Eggs", Quantity: 3, Unit: "pieces"},
+		},
+	}
+
+	scaled := scaleRecipe(recipe, 1)
+
+	want := []string{"1/3", "0.17", "1"}
+	for i, ingredient := range scaled.Ingredients {
+		if ingredient.QuantityDisplay != want[i] {
+			t.Errorf("%s scaled to %v is displayed as %q, want %q", ingredient.Name, ingredient.Quantity, ingredient.QuantityDisplay, want[i])
+		}
+	}
+	if recipe.Ingredients[0].Quantity != 1 || recipe.Ingredients[0].QuantityDisplay != "" {
+		t.Error("scaleRecipe modified the original recipe")
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"recipe-book/database"
+	"recipe-book/utils"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxShoppingListRecipes caps how many recipes can be combined in one request
+const maxShoppingListRecipes = 50
+
+// ShoppingListRecipe selects a recipe for the shopping list. It can be sent either as a
+// bare recipe ID or as {"recipe_id": 1, "servings": 4} to scale the recipe first.
+type ShoppingListRecipe struct {
+	RecipeID int `json:"recipe_id"`
+	Servings int `json:"servings,omitempty"`
+}
+
+func (s *ShoppingListRecipe) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return json.Unmarshal(data, &s.RecipeID)
+	}
+
+	type plain ShoppingListRecipe
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// ShoppingListItem is one line of the combined grocery list
+type ShoppingListItem struct {
+	Name            string  `json:"name"`
+	Unit            string  `json:"unit"`
+	Quantity        float64 `json:"quantity"`
+	QuantityDisplay string  `json:"quantity_display"` // e.g. "1 1/2"
+	Category        string  `json:"category"`
+}
+
+// ShoppingListGroup holds the items of one ingredient category, for walking the aisles
+type ShoppingListGroup struct {
+	Category string             `json:"category"`
+	Items    []ShoppingListItem `json:"items"`
+}
+
+// groupShoppingList splits a sorted list by category, in the order of utils.IngredientCategories
+func groupShoppingList(list []ShoppingListItem) []ShoppingListGroup {
+	groups := []ShoppingListGroup{}
+	for _, category := range utils.IngredientCategories {
+		group := ShoppingListGroup{Category: category}
+		for _, item := range list {
+			if item.Category == category {
+				group.Items = append(group.Items, item)
+			}
+		}
+		if len(group.Items) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// ShoppingListHandler combines the ingredients of the selected recipes into one grocery
+// list. Optional ingredients are left out unless ?include_optional=true is passed.
+func ShoppingListHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+	includeOptional, _ := strconv.ParseBool(r.URL.Query().Get("include_optional"))
+
+	var selections []ShoppingListRecipe
+	if err := json.NewDecoder(r.Body).Decode(&selections); err != nil {
+		utils.LogSecurityEvent(r.Context(), "INVALID_JSON_SHOPPING_LIST", clientIP, err.Error())
+		sendJSONError(w, http.StatusBadRequest, "Expected a JSON array of recipe IDs")
+		return
+	}
+
+	if len(selections) == 0 {
+		sendJSONError(w, http.StatusBadRequest, "At least one recipe is required")
+		return
+	}
+	if len(selections) > maxShoppingListRecipes {
+		sendJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d recipes can be combined", maxShoppingListRecipes))
+		return
+	}
+
+	for _, selection := range selections {
+		if selection.Servings == 0 {
+			continue
+		}
+		servingsValidation := utils.ValidateNumericInput(selection.Servings, 1, 100, "Servings")
+		if !servingsValidation.Valid {
+			sendJSONValidationError(w, servingsValidation)
+			return
+		}
+	}
+
+	ingredients, err := database.GetAllIngredients("")
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch ingredients")
+		return
+	}
+	categories := make(map[int]string, len(ingredients))
+	for _, ingredient := range ingredients {
+		categories[ingredient.ID] = ingredient.Category
+	}
+
+	viewer := viewerID(r)
+	items := make(map[string]*ShoppingListItem)
+	skipped := []int{}
+
+	for _, selection := range selections {
+		if !utils.IsValidID(selection.RecipeID) {
+			skipped = append(skipped, selection.RecipeID)
+			continue
+		}
+
+		recipe, err := database.GetRecipeByIDSecure(selection.RecipeID, viewer)
+		if err != nil {
+			skipped = append(skipped, selection.RecipeID)
+			continue
+		}
+
+		if selection.Servings > 0 && recipe.Servings > 0 {
+			recipe = scaleRecipe(recipe, selection.Servings).Recipe
+		}
+
+		for _, ingredient := range recipe.Ingredients {
+			if ingredient.Optional && !includeOptional {
+				continue
+			}
+
+			// Quantities are only summed when the unit matches; other units get their own line
+			key := strings.ToLower(ingredient.Name) + "|" + strings.ToLower(ingredient.Unit)
+			if item, exists := items[key]; exists {
+				item.Quantity += ingredient.Quantity
+				continue
+			}
+			category := categories[ingredient.IngredientID]
+			if category == "" {
+				category = utils.DefaultIngredientCategory
+			}
+			items[key] = &ShoppingListItem{
+				Name:     ingredient.Name,
+				Unit:     ingredient.Unit,
+				Quantity: ingredient.Quantity,
+				Category: category,
+			}
+		}
+	}
+
+	list := make([]ShoppingListItem, 0, len(items))
+	for _, item := range items {
+		item.Quantity = utils.ScaleQuantity(item.Quantity, 1)
+		item.QuantityDisplay = utils.FormatQuantity(item.Quantity)
+		list = append(list, *item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := strings.ToLower(list[i].Name), strings.ToLower(list[j].Name)
+		if a != b {
+			return a < b
+		}
+		return list[i].Unit < list[j].Unit
+	})
+
+	sendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"items":   list,
+		"groups":  groupShoppingList(list),
+		"skipped": skipped,
+	})
+}
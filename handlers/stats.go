@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"net/http"
+	"recipe-book/database"
+	"recipe-book/utils"
+)
+
+// GetSiteStatsHandler serves the site's totals and most used tags and ingredients,
+// computed at most once per database.SiteStatsTTL
+func GetSiteStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := database.GetSiteStats()
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "SITE_STATS_ERROR", utils.ClientIP(r), err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch statistics")
+		return
+	}
+
+	sendJSONResponse(w, http.StatusOK, stats)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"recipe-book/database"
+	"recipe-book/models"
+	"recipe-book/utils"
+)
+
+// SuggestedTagsHandler proposes existing tags for a recipe from its ingredients,
+// instructions and total time, leaving out those it already has
+func SuggestedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	clientIP := utils.ClientIP(r)
+
+	recipeID, idStr, ok := parseRouteID(r)
+	if !ok {
+		utils.LogSecurityEvent(r.Context(), "INVALID_RECIPE_ID_SUGGEST_TAGS", clientIP, idStr)
+		sendJSONError(w, http.StatusBadRequest, "Invalid recipe ID")
+		return
+	}
+
+	recipe, err := database.GetRecipeByIDSecure(recipeID, viewerID(r))
+	if err != nil {
+		sendJSONError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+
+	usages, err := database.GetAllTags(false)
+	if err != nil {
+		utils.LogSecurityEvent(r.Context(), "TAGS_FETCH_ERROR", clientIP, err.Error())
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+	tags := make([]models.Tag, len(usages))
+	for i, usage := range usages {
+		tags[i] = usage.Tag
+	}
+
+	sendJSONResponse(w, http.StatusOK, utils.SuggestTags(*recipe, tags))
+}
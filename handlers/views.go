@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"recipe-book/auth"
+	"recipe-book/database"
+	"recipe-book/models"
+	"strconv"
+)
+
+// defaultRecentlyViewed is how many recipes GET /api/my/recently-viewed returns without a limit
+const defaultRecentlyViewed = 10
+
+// recordRecipeView stores the view in the background so the recipe response is not
+// held up by the write. A lost view is harmless, so failures are only logged.
+func recordRecipeView(userID, recipeID int) {
+	go func() {
+		if err := database.RecordRecipeView(userID, recipeID); err != nil {
+			log.Printf("Error recording view of recipe %d by user %d: %v", recipeID, userID, err)
+		}
+	}()
+}
+
+// GetRecentlyViewedHandler lists the recipes the user opened most recently
+func GetRecentlyViewedHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.GetUserFromToken(r)
+	if err != nil {
+		sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	limit := defaultRecentlyViewed
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			sendJSONError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		if limit > database.MaxRecipeViewsPerUser {
+			limit = database.MaxRecipeViewsPerUser
+		}
+	}
+
+	recipes, err := database.GetRecentlyViewedRecipes(user.ID, limit)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, "Failed to fetch recently viewed recipes")
+		return
+	}
+
+	if recipes == nil {
+		recipes = []models.Recipe{}
+	}
+
+	sendJSONResponse(w, http.StatusOK, recipes)
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"recipe-book/auth"
 	"recipe-book/database"
+	"recipe-book/docs"
 	"recipe-book/handlers"
 	"recipe-book/middleware"
+	"recipe-book/utils"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,44 +29,134 @@ func main() {
 		return
 	}
 
+	// Address to bind, e.g. 127.0.0.1:9000 or :3000, from LISTEN_ADDR
+	addr := listenAddr()
+
+	// Load JWT signing key (exits if missing outside DEV_MODE)
+	auth.InitJWTSecret()
+
+	// Password hashing cost, tunable through BCRYPT_COST
+	auth.InitBcryptCost()
+
+	// Auth cookie attributes, see SECURE_COOKIES and COOKIE_SAMESITE
+	auth.InitCookies()
+
+	// Account lockout after failed logins, tunable through LOGIN_LOCKOUT_* variables
+	database.InitLoginLockout()
+
+	// Persist important security events when AUDIT_DB is enabled
+	if auditDB, _ := strconv.ParseBool(os.Getenv("AUDIT_DB")); auditDB {
+		utils.SetAuditSink(database.WriteAuditLog)
+		log.Println("📝 Audit log enabled")
+	}
+
+	if requireVerification, _ := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION")); requireVerification {
+		handlers.SetRequireEmailVerification(true)
+		log.Println("✉️  Email verification required to create recipes")
+	}
+
+	if maxImages := os.Getenv("MAX_IMAGES_PER_RECIPE"); maxImages != "" {
+		limit, err := strconv.Atoi(maxImages)
+		if err != nil || limit <= 0 {
+			log.Fatalf("❌ Invalid MAX_IMAGES_PER_RECIPE %q: expected a positive whole number", maxImages)
+		}
+		handlers.SetMaxImagesPerRecipe(limit)
+	}
+
+	// Store uploaded JPEG and PNG images as WebP when that makes them smaller
+	if convertWebP, _ := strconv.ParseBool(os.Getenv("CONVERT_WEBP")); convertWebP {
+		utils.SetConvertWebP(true)
+		log.Println("🖼️  Converting uploaded JPEG and PNG images to WebP")
+	}
+
+	// Expose request, rate limit and database error metrics at /metrics when METRICS_ENABLED is set
+	metricsEnabled, _ := strconv.ParseBool(os.Getenv("METRICS_ENABLED"))
+	if metricsEnabled {
+		database.CountQueryErrors()
+		middleware.ReportDBErrors(database.QueryErrors)
+		log.Println("📈 Metrics enabled at /metrics")
+	}
+
+	// Parse the embedded templates of the server-rendered pages
+	utils.LoadTemplates()
+
 	// Initialize database in background
 	go func() {
 		database.InitDB()
 		log.Println("✅ Database initialization completed")
+		go flushRecipeViewsPeriodically()
+		purgeDeletedRecipesPeriodically()
 	}()
 
+	// Forwarding headers are only believed from these proxies
+	trustedProxies, err := utils.ParseIPRanges(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("❌ Invalid TRUSTED_PROXIES: %v", err)
+	}
+	utils.SetTrustedProxies(trustedProxies)
+
+	ipAccess, err := middleware.IPAccessConfigFromEnv()
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
 	// Create router immediately
 	r := mux.NewRouter()
 
 	// Apply global middleware (order matters!)
-	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.RequestID())        // First, so every later log line can include the ID
+	r.Use(middleware.IPAccess(ipAccess)) // Before anything else does work for the request
+	if metricsEnabled {
+		r.Use(middleware.Metrics()) // Before the rate limiters, so their 429s are counted
+	}
+	corsConfig := corsConfigFromEnv()
+	r.Use(middleware.CORSMiddleware(corsConfig))
 	r.Use(middleware.SecurityHeaders())
 	r.Use(middleware.CacheHeaders())          // Add caching middleware
 	r.Use(middleware.CompressionMiddleware()) // Add compression
-	r.Use(middleware.RequestLogging())
+	if os.Getenv("LOG_FORMAT") == "json" {
+		r.Use(middleware.RequestLoggingJSON())
+	} else {
+		r.Use(middleware.RequestLogging())
+	}
 
-	// Initialize security manager with lighter config for startup
-	securityConfig := middleware.LightRateLimitConfig() // Use lighter config
+	// Initialize security manager with the lighter config, tunable through RATE_* variables
+	securityConfig := middleware.RateLimitConfigFromEnv()
 	securityManager := middleware.NewSecurityManager(securityConfig)
 	r.Use(securityManager.AddSecurityContext())
 	r.Use(middleware.SQLInjectionProtection())
 	r.Use(securityManager.GeneralRateLimit(securityConfig))
 
-	// Health check endpoint (no database dependency)
-	r.HandleFunc("/health", quickHealthCheckHandler).Methods("GET")
+	// Health check endpoint
+	r.HandleFunc("/health", healthCheckHandler).Methods("GET")
+	if metricsEnabled {
+		r.HandleFunc("/metrics", middleware.MetricsHandler).Methods("GET")
+	}
 
 	// API routes with specific rate limiting
 	setupAPIRoutes(r, securityManager, securityConfig)
 
+	// Server-rendered print view, ahead of the SPA's /recipe/{id} routes
+	r.HandleFunc("/recipe/{id:[0-9]+}/print", handlers.PrintRecipeHandler).Methods("GET")
+
+	// Recipe pages by slug, e.g. /recipe/classic-margherita-pizza, next to those by ID
+	r.Handle("/recipe/{slug:[a-z0-9-]+}", handlers.RecipeSlugPage(http.HandlerFunc(serveSPA))).Methods("GET")
+
 	// Static file serving with caching
 	setupStaticRoutes(r)
 
 	// SPA fallback
-	setupSPAFallback(r)
+	spaRoute := setupSPAFallback(r)
+
+	// Known paths requested with another method get a JSON 405, or the list of allowed
+	// methods for OPTIONS. Router middleware does not run for these, so IP access and CORS
+	// are added here.
+	r.MethodNotAllowedHandler = middleware.MethodNotAllowed(r, spaRoute,
+		middleware.IPAccess(ipAccess), middleware.CORSMiddleware(corsConfig), middleware.SecurityHeaders())
 
-	fmt.Println("🚀 Recipe Book Server starting on :8080 (Fast Mode)")
+	fmt.Printf("🚀 Recipe Book Server starting on %s (Fast Mode)\n", addr)
 	fmt.Println("📦 Database initializing in background...")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	log.Fatal(http.ListenAndServe(addr, r))
 }
 
 func setupAPIRoutes(r *mux.Router, sm *middleware.SecurityManager, config *middleware.RateLimitConfig) {
@@ -67,10 +164,13 @@ func setupAPIRoutes(r *mux.Router, sm *middleware.SecurityManager, config *middl
 	loginRouter := r.PathPrefix("/api").Subrouter()
 	loginRouter.Use(sm.LoginRateLimit(config))
 	loginRouter.HandleFunc("/login", handlers.LoginHandler).Methods("POST")
+	loginRouter.HandleFunc("/auth/forgot-password", handlers.ForgotPasswordHandler).Methods("POST")
+	loginRouter.HandleFunc("/auth/reset-password", handlers.ResetPasswordHandler).Methods("POST")
 
 	registerRouter := r.PathPrefix("/api").Subrouter()
 	registerRouter.Use(sm.RegisterRateLimit(config))
 	registerRouter.HandleFunc("/register", handlers.RegisterHandler).Methods("POST")
+	registerRouter.HandleFunc("/auth/resend-verification", handlers.ResendVerificationHandler).Methods("POST")
 
 	// Search API
 	searchRouter := r.PathPrefix("/api").Subrouter()
@@ -80,26 +180,93 @@ func setupAPIRoutes(r *mux.Router, sm *middleware.SecurityManager, config *middl
 	// Other API routes
 	r.HandleFunc("/api/logout", handlers.LogoutHandler).Methods("POST")
 	r.HandleFunc("/api/auth/check", handlers.CheckAuthHandler).Methods("GET")
+	r.HandleFunc("/api/auth/refresh", handlers.RefreshTokenHandler).Methods("POST")
+	r.HandleFunc("/api/auth/verify", handlers.VerifyEmailHandler).Methods("GET")
+	r.HandleFunc("/api/config", handlers.ConfigHandler).Methods("GET")
+	r.HandleFunc("/api/openapi.json", docs.OpenAPIHandler).Methods("GET")
+	r.HandleFunc("/api/account", handlers.DeleteAccountHandler).Methods("DELETE")
+	r.HandleFunc("/api/account/logout-all", handlers.LogoutAllHandler).Methods("POST")
+	r.HandleFunc("/api/users/{id:[0-9]+}", handlers.GetUserProfileHandler).Methods("GET")
+	r.HandleFunc("/api/my/recipes", handlers.GetMyRecipesHandler).Methods("GET")
+	r.HandleFunc("/api/my/recipes/export", handlers.ExportMyRecipesHandler).Methods("GET")
+	r.HandleFunc("/api/my/recently-viewed", handlers.GetRecentlyViewedHandler).Methods("GET")
 
 	// Recipe API routes
 	r.HandleFunc("/api/recipes", handlers.GetRecipesHandler).Methods("GET")
 	r.HandleFunc("/api/recipes", handlers.CreateRecipeHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/import", handlers.ImportRecipeHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/bulk-delete", handlers.BulkDeleteRecipesHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/batch", handlers.GetRecipesBatchHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/trash", handlers.GetTrashHandler).Methods("GET")
+	r.HandleFunc("/api/recipes/by-ingredients", handlers.FindRecipesByIngredientsHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/random", handlers.GetRandomRecipeHandler).Methods("GET")
 	r.HandleFunc("/api/recipes/{id:[0-9]+}", handlers.GetRecipeHandler).Methods("GET")
+	r.HandleFunc("/api/recipes/slug/{slug}", handlers.GetRecipeBySlugHandler).Methods("GET")
 	r.HandleFunc("/api/recipes/{id:[0-9]+}", handlers.UpdateRecipeHandler).Methods("PUT")
 	r.HandleFunc("/api/recipes/{id:[0-9]+}", handlers.DeleteRecipeHandler).Methods("DELETE")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/restore", handlers.RestoreRecipeHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/clone", handlers.CloneRecipeHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/visibility", handlers.SetVisibilityHandler).Methods("PUT")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/publish", handlers.PublishRecipeHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/nutrition", handlers.SetNutritionHandler).Methods("PUT")
+
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/scale", handlers.ScaleRecipeHandler).Methods("GET")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/suggested-tags", handlers.SuggestedTagsHandler).Methods("GET")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/related", handlers.RelatedRecipesHandler).Methods("GET")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/export", handlers.ExportRecipeHandler).Methods("GET")
+
+	// Rating API routes
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/rating", handlers.RateRecipeHandler).Methods("POST")
+
+	// Favorite API routes
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/favorite", handlers.AddFavoriteHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/favorite", handlers.RemoveFavoriteHandler).Methods("DELETE")
+	r.HandleFunc("/api/favorites", handlers.GetFavoritesHandler).Methods("GET")
+
+	// Comment API routes
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/comments", handlers.GetCommentsHandler).Methods("GET")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/comments", handlers.AddCommentHandler).Methods("POST")
+	r.HandleFunc("/api/comments/{id:[0-9]+}", handlers.DeleteCommentHandler).Methods("DELETE")
 
 	// Recipe Image API routes
 	r.HandleFunc("/api/recipes/{id:[0-9]+}/images", handlers.UploadRecipeImagesHandler).Methods("POST")
+	r.HandleFunc("/api/recipes/{id:[0-9]+}/images/order", handlers.ReorderImagesHandler).Methods("PUT")
 	r.HandleFunc("/api/images/{id:[0-9]+}", handlers.DeleteImageHandler).Methods("DELETE")
+	r.HandleFunc("/api/images/{id:[0-9]+}", handlers.UpdateImageCaptionHandler).Methods("PATCH")
+	r.HandleFunc("/api/images/{id:[0-9]+}/primary", handlers.SetPrimaryImageHandler).Methods("PUT")
 
 	// Ingredient API routes
 	r.HandleFunc("/api/ingredients", handlers.GetIngredientsHandler).Methods("GET")
 	r.HandleFunc("/api/ingredients", handlers.CreateIngredientHandler).Methods("POST")
+	r.HandleFunc("/api/ingredients/bulk", handlers.CreateIngredientsBulkHandler).Methods("POST")
+	r.HandleFunc("/api/ingredients/merge", handlers.MergeIngredientsHandler).Methods("POST")
+	r.HandleFunc("/api/ingredients/stats", handlers.GetIngredientStatsHandler).Methods("GET")
+	r.HandleFunc("/api/ingredient-categories", handlers.GetIngredientCategoriesHandler).Methods("GET")
+	r.HandleFunc("/api/ingredients/{id:[0-9]+}", handlers.GetIngredientHandler).Methods("GET")
 	r.HandleFunc("/api/ingredients/{id:[0-9]+}", handlers.DeleteIngredientHandler).Methods("DELETE")
 
+	// Unit conversion API
+	r.HandleFunc("/api/convert", handlers.ConvertUnitHandler).Methods("GET")
+	r.HandleFunc("/api/cuisines", handlers.GetCuisinesHandler).Methods("GET")
+	r.HandleFunc("/api/stats", handlers.GetSiteStatsHandler).Methods("GET")
+	r.HandleFunc("/api/shopping-list", handlers.ShoppingListHandler).Methods("POST")
+
+	// Meal planner routes
+	r.HandleFunc("/api/meal-plans", handlers.GetMealPlansHandler).Methods("GET")
+	r.HandleFunc("/api/meal-plans", handlers.AddMealPlanHandler).Methods("POST")
+	r.HandleFunc("/api/meal-plans/{id:[0-9]+}", handlers.DeleteMealPlanHandler).Methods("DELETE")
+
+	// Admin routes
+	r.HandleFunc("/api/admin/audit", handlers.GetAuditLogHandler).Methods("GET")
+	r.HandleFunc("/api/admin/backup", handlers.BackupHandler).Methods("GET")
+	r.HandleFunc("/api/admin/cleanup-images", handlers.CleanupImagesHandler).Methods("POST")
+
 	// Tag API routes
 	r.HandleFunc("/api/tags", handlers.GetTagsHandler).Methods("GET")
 	r.HandleFunc("/api/tags", handlers.CreateTagHandler).Methods("POST")
+	r.HandleFunc("/api/tags/merge", handlers.MergeTagsHandler).Methods("POST")
+	r.HandleFunc("/api/tags/{id:[0-9]+}", handlers.GetTagHandler).Methods("GET")
+	r.HandleFunc("/api/tags/{id:[0-9]+}", handlers.UpdateTagHandler).Methods("PUT")
 	r.HandleFunc("/api/tags/{id:[0-9]+}", handlers.DeleteTagHandler).Methods("DELETE")
 }
 
@@ -116,37 +283,45 @@ func setupStaticRoutes(r *mux.Router) {
 		log.Printf("⚠️  Static files not found at %s", staticDir)
 	}
 
-	// Serve static assets with long cache
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", addCacheHeaders(http.FileServer(http.Dir(staticDir)), 31536000)))           // 1 year
+	// Static files are cached for good only at versioned URLs, see utils.StaticAssetURL.
+	// The build's assets have the content hash in their names, so they always are.
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", middleware.VersionedAssets(utils.StaticAssets)(http.FileServer(http.Dir(staticDir)))))
 	r.PathPrefix("/assets/").Handler(http.StripPrefix("/assets/", addCacheHeaders(http.FileServer(http.Dir(staticDir+"assets/")), 31536000))) // 1 year
 }
 
-func setupSPAFallback(r *mux.Router) {
-	r.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// setupSPAFallback serves the frontend for every other GET request and returns its route
+func setupSPAFallback(r *mux.Router) *mux.Route {
+	return r.PathPrefix("/").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Don't serve index.html for API routes or specific file requests
 		if strings.HasPrefix(r.URL.Path, "/api/") ||
 			strings.HasPrefix(r.URL.Path, "/uploads/") ||
 			strings.HasPrefix(r.URL.Path, "/static/") ||
 			strings.HasPrefix(r.URL.Path, "/assets/") ||
 			r.URL.Path == "/health" ||
+			r.URL.Path == "/metrics" ||
 			filepath.Ext(r.URL.Path) != "" {
 			http.NotFound(w, r)
 			return
 		}
 
-		staticDir := "./static/dist/"
-		indexPath := filepath.Join(staticDir, "index.html")
-		if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-			http.Error(w, "Frontend not built. Please run 'cd frontend && npm run build'", http.StatusServiceUnavailable)
-			return
-		}
-
-		// Add cache headers for HTML (short cache)
-		w.Header().Set("Cache-Control", "public, max-age=300") // 5 minutes
-		http.ServeFile(w, r, indexPath)
+		serveSPA(w, r)
 	})
 }
 
+// serveSPA serves the frontend's index.html, which routes the page itself
+func serveSPA(w http.ResponseWriter, r *http.Request) {
+	staticDir := "./static/dist/"
+	indexPath := filepath.Join(staticDir, "index.html")
+	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+		http.Error(w, "Frontend not built. Please run 'cd frontend && npm run build'", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Add cache headers for HTML (short cache)
+	w.Header().Set("Cache-Control", "public, max-age=300") // 5 minutes
+	http.ServeFile(w, r, indexPath)
+}
+
 // Helper function to add cache headers
 func addCacheHeaders(h http.Handler, maxAge int) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -156,17 +331,126 @@ func addCacheHeaders(h http.Handler, maxAge int) http.Handler {
 	})
 }
 
-// Quick health check that doesn't depend on database
-func quickHealthCheckHandler(w http.ResponseWriter, r *http.Request) {
+// healthCheckTimeout bounds how long the health check waits for the database
+const healthCheckTimeout = 2 * time.Second
+
+// startTime is when the server started, reported as uptime by the health check
+var startTime = time.Now()
+
+// Health check that reports 503 while the database is unreachable or still initializing
+func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	response := map[string]interface{}{
+		"status":         "healthy",
+		"service":        "recipe-book",
+		"database":       "up",
+		"uptime_seconds": int(time.Since(startTime).Seconds()),
+		"timestamp":      time.Now().UTC().Format(time.RFC3339),
+	}
+	status := http.StatusOK
+
+	if err := database.HealthCheck(ctx); err != nil {
+		log.Printf("⚠️  Health check: database unavailable: %v", err)
+		response["status"] = "unhealthy"
+		response["database"] = "down"
+		status = http.StatusServiceUnavailable
+	} else if count, err := database.CountRecipes(0); err == nil {
+		response["recipe_count"] = count
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-cache")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"healthy","service":"recipe-book","timestamp":"` + time.Now().UTC().Format(time.RFC3339) + `"}`))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
 }
 
-// Regular health check function for Docker
+// defaultListenAddr is used when LISTEN_ADDR is unset
+const defaultListenAddr = ":8080"
+
+// listenAddr reads the host:port to bind from LISTEN_ADDR and exits if it is malformed.
+// An empty host binds every interface.
+func listenAddr() string {
+	addr := strings.TrimSpace(os.Getenv("LISTEN_ADDR"))
+	if addr == "" {
+		return defaultListenAddr
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil || port == "" {
+		log.Fatalf("❌ Invalid LISTEN_ADDR %q: expected host:port or :port", addr)
+	}
+	return addr
+}
+
+// healthCheckURL returns the /health URL of a server bound to addr. A wildcard or
+// empty host is reached through localhost.
+func healthCheckURL(addr string) string {
+	host, port, _ := net.SplitHostPort(addr)
+	switch host {
+	case "", "0.0.0.0", "::":
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/health"
+}
+
+// corsConfigFromEnv reads the comma-separated CORS_ORIGINS allowlist. When it is unset,
+// no cross-origin requests are allowed.
+func corsConfigFromEnv() *middleware.CORSConfig {
+	config := &middleware.CORSConfig{}
+	for _, origin := range strings.Split(os.Getenv("CORS_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			config.AllowedOrigins = append(config.AllowedOrigins, origin)
+		}
+	}
+	return config
+}
+
+// purgeDeletedRecipesPeriodically empties old recipes from the recycle bin and deletes
+// stale login attempts and expired idempotency keys once a day
+func purgeDeletedRecipesPeriodically() {
+	purge := func() {
+		purged, err := database.PurgeOldDeletedRecipes(database.TrashRetention)
+		if err != nil {
+			log.Printf("Failed to purge deleted recipes: %v", err)
+		} else if purged > 0 {
+			log.Printf("🗑️ Purged %d recipes from the recycle bin", purged)
+		}
+
+		if _, err := database.DeleteStaleLoginAttempts(); err != nil {
+			log.Printf("Failed to delete stale login attempts: %v", err)
+		}
+
+		expired, err := database.DeleteExpiredIdempotencyKeys()
+		if err != nil {
+			log.Printf("Failed to delete expired idempotency keys: %v", err)
+		} else if expired > 0 {
+			log.Printf("🗑️ Deleted %d expired idempotency keys", expired)
+		}
+	}
+
+	purge()
+	ticker := time.NewTicker(24 * time.Hour)
+	for range ticker.C {
+		purge()
+	}
+}
+
+// flushRecipeViewsPeriodically writes the recipe view counts collected in memory
+func flushRecipeViewsPeriodically() {
+	ticker := time.NewTicker(30 * time.Second)
+	for range ticker.C {
+		if _, err := database.FlushRecipeViews(); err != nil {
+			log.Printf("Failed to save recipe view counts: %v", err)
+		}
+	}
+}
+
+// Regular health check function for Docker. It fails unless /health reports 200,
+// so an unreachable database marks the container unhealthy.
 func healthCheck() {
-	resp, err := http.Get("http://localhost:8080/health")
+	resp, err := http.Get(healthCheckURL(listenAddr()))
 	if err != nil {
 		fmt.Printf("Health check failed: %v\n", err)
 		os.Exit(1)
@@ -174,7 +458,11 @@ func healthCheck() {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Health check failed with status: %d\n", resp.StatusCode)
+		var body struct {
+			Database string `json:"database"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		fmt.Printf("Health check failed with status: %d (database: %s)\n", resp.StatusCode, body.Database)
 		os.Exit(1)
 	}
 
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"recipe-book/docs"
+	"recipe-book/middleware"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// undocumentedRoutes are API routes the OpenAPI document does not describe yet. A new
+// route must be added to docs.Spec or, deliberately, here.
+var undocumentedRoutes = map[string]bool{
+	"get /api/config":                    true,
+	"get /api/openapi.json":              true,
+	"get /api/users/{id}":                true,
+	"delete /api/account":                true,
+	"get /api/my/recipes/export":         true,
+	"get /api/recipes/{id}/scale":        true,
+	"get /api/recipes/{id}/export":       true,
+	"post /api/recipes/by-ingredients":   true,
+	"post /api/recipes/{id}/rating":      true,
+	"post /api/recipes/{id}/favorite":    true,
+	"delete /api/recipes/{id}/favorite":  true,
+	"get /api/favorites":                 true,
+	"get /api/recipes/{id}/comments":     true,
+	"post /api/recipes/{id}/comments":    true,
+	"delete /api/comments/{id}":          true,
+	"post /api/recipes/{id}/images":      true,
+	"put /api/recipes/{id}/images/order": true,
+	"delete /api/images/{id}":            true,
+	"patch /api/images/{id}":             true,
+	"put /api/images/{id}/primary":       true,
+	"get /api/convert":                   true,
+	"get /api/cuisines":                  true,
+	"post /api/shopping-list":            true,
+	"get /api/meal-plans":                true,
+	"post /api/meal-plans":               true,
+	"delete /api/meal-plans/{id}":        true,
+	"get /api/admin/audit":               true,
+	"get /api/admin/backup":              true,
+	"post /api/admin/cleanup-images":     true,
+}
+
+// routeVariablePattern matches a mux path variable with its pattern, e.g. {id:[0-9]+}
+var routeVariablePattern = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// apiRoutes returns the registered API routes as "method path" keys, with path
+// variables written the OpenAPI way
+func apiRoutes(t *testing.T) map[string]bool {
+	t.Helper()
+
+	r := mux.NewRouter()
+	config := middleware.RateLimitConfigFromEnv()
+	setupAPIRoutes(r, middleware.NewSecurityManager(config), config)
+
+	routes := map[string]bool{}
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // subrouters have no methods of their own
+		}
+		for _, method := range methods {
+			routes[strings.ToLower(method)+" "+routeVariablePattern.ReplaceAllString(path, "{$1}")] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking the routes: %v", err)
+	}
+	return routes
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	routes := apiRoutes(t)
+
+	documented := map[string]bool{}
+	for path, item := range docs.Spec().Paths {
+		for method := range item {
+			key := method + " " + path
+			documented[key] = true
+			if !routes[key] {
+				t.Errorf("the OpenAPI document describes %s, which is not a route", key)
+			}
+		}
+	}
+
+	for key := range routes {
+		if !documented[key] && !undocumentedRoutes[key] {
+			t.Errorf("route %s is missing from the OpenAPI document", key)
+		}
+	}
+	for key := range undocumentedRoutes {
+		if documented[key] {
+			t.Errorf("route %s is documented now, remove it from undocumentedRoutes", key)
+		}
+	}
+}
+
+// testRouter routes the API and the frontend fallback the way main does, with the
+// JSON 405 handler and CORS for allowedOrigin
+func testRouter(allowedOrigin string) *mux.Router {
+	r := mux.NewRouter()
+	config := middleware.RateLimitConfigFromEnv()
+	setupAPIRoutes(r, middleware.NewSecurityManager(config), config)
+	spaRoute := setupSPAFallback(r)
+
+	corsConfig := &middleware.CORSConfig{AllowedOrigins: []string{allowedOrigin}}
+	r.MethodNotAllowedHandler = middleware.MethodNotAllowed(r, spaRoute, middleware.CORSMiddleware(corsConfig))
+	return r
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	router := testRouter("https://app.example.com")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		origin     string
+		wantStatus int
+		wantAllow  string
+		wantJSON   bool
+	}{
+		{"wrong method on an API path", http.MethodDelete, "/api/recipes", "", http.StatusMethodNotAllowed, "GET, HEAD, POST, OPTIONS", true},
+		{"wrong method on a recipe", http.MethodPost, "/api/recipes/1", "", http.StatusMethodNotAllowed, "GET, HEAD, PUT, DELETE, OPTIONS", true},
+		{"options", http.MethodOptions, "/api/recipes", "", http.StatusNoContent, "GET, HEAD, POST, OPTIONS", false},
+		{"cors preflight", http.MethodOptions, "/api/recipes", "https://app.example.com", http.StatusOK, "", false},
+		{"frontend page", http.MethodPost, "/about", "", http.StatusNotFound, "", true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: %s %s returned %d, want %d", tt.name, tt.method, tt.path, w.Code, tt.wantStatus)
+		}
+		if allow := w.Header().Get("Allow"); allow != tt.wantAllow {
+			t.Errorf("%s: Allow = %q, want %q", tt.name, allow, tt.wantAllow)
+		}
+		if tt.origin != "" && w.Header().Get("Access-Control-Allow-Origin") != tt.origin {
+			t.Errorf("%s: preflight is missing the CORS headers", tt.name)
+		}
+		if tt.wantJSON {
+			var body struct {
+				Error string `json:"error"`
+			}
+			if w.Header().Get("Content-Type") != "application/json" || json.Unmarshal(w.Body.Bytes(), &body) != nil || body.Error == "" {
+				t.Errorf("%s: response is not a JSON error: %q", tt.name, w.Body)
+			}
+		}
+	}
+}
+
+func TestHeadServedAsGet(t *testing.T) {
+	router := testRouter("")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/api/openapi.json", nil))
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("HEAD /api/openapi.json returned %d %q, want the GET response's 200 and JSON type", w.Code, w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"recipe-book/utils"
+)
+
+// IPAccessConfig holds static lists of client address ranges, as opposed to the IPs the
+// SecurityManager blocks for a while after rate limit violations
+type IPAccessConfig struct {
+	// AllowedIPs, when not empty, are the only ranges requests are accepted from
+	AllowedIPs []*net.IPNet
+	// BlockedIPs are ranges requests are never accepted from, even when allowed
+	BlockedIPs []*net.IPNet
+}
+
+// IPAccessConfigFromEnv reads the comma-separated IP_ALLOWLIST and IP_DENYLIST, whose
+// entries are CIDR ranges such as 10.0.0.0/8 or single addresses. It fails on any entry
+// it can't parse rather than leave a range open by mistake.
+func IPAccessConfigFromEnv() (*IPAccessConfig, error) {
+	allowed, err := utils.ParseIPRanges(os.Getenv("IP_ALLOWLIST"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP_ALLOWLIST: %w", err)
+	}
+
+	blocked, err := utils.ParseIPRanges(os.Getenv("IP_DENYLIST"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP_DENYLIST: %w", err)
+	}
+
+	return &IPAccessConfig{AllowedIPs: allowed, BlockedIPs: blocked}, nil
+}
+
+// Allows reports whether requests from ip are accepted. When an allowlist is set,
+// addresses that can't be parsed are refused.
+func (c *IPAccessConfig) Allows(ip net.IP) bool {
+	if ip != nil && containsIP(c.BlockedIPs, ip) {
+		return false
+	}
+	if len(c.AllowedIPs) == 0 {
+		return true
+	}
+	return ip != nil && containsIP(c.AllowedIPs, ip)
+}
+
+func containsIP(ranges []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range ranges {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPAccess refuses requests from clients the config doesn't allow with a JSON 403; with
+// both lists empty every request passes. Behind a reverse proxy, the proxy has to be
+// listed in TRUSTED_PROXIES for the client's own address to be checked.
+func IPAccess(config *IPAccessConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := utils.ClientIP(r)
+			if !config.Allows(net.ParseIP(ip)) {
+				log.Printf("🚫 Refused request from %s to %s: address not allowed", ip, r.URL.Path)
+				writeJSONError(w, http.StatusForbidden, "Access denied")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
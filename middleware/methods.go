@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// probedMethods are the methods tried against the router to find those a path allows
+var probedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// MethodNotAllowed handles requests to a path the router knows, but not for their method.
+// It is meant as the router's MethodNotAllowedHandler:
+//   - HEAD is served as GET wherever GET is allowed;
+//   - OPTIONS gets a 204 whose Allow header lists the path's methods;
+//   - anything else gets a JSON 405 with the same Allow header.
+//
+// Paths only the fallback route (the frontend) matches get a JSON 404 instead, so that
+// POST /about is not reported as a page accepting GET. The router's middleware does not
+// run for these responses; wrap adds what they need, such as CORS for preflight requests.
+func MethodNotAllowed(router *mux.Router, fallback *mux.Route, wrap ...func(http.Handler) http.Handler) http.Handler {
+	var respond http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := allowedMethods(router, fallback, r)
+		if len(allowed) == 0 {
+			writeJSONError(w, http.StatusNotFound, "Not found")
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed here")
+	})
+	for i := len(wrap) - 1; i >= 0; i-- {
+		respond = wrap[i](respond)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			get := r.Clone(r.Context())
+			get.Method = http.MethodGet
+			// Pages the fallback serves answer HEAD as well
+			var match mux.RouteMatch
+			if router.Match(get, &match) && match.MatchErr == nil {
+				// The server drops the body of responses to HEAD requests
+				router.ServeHTTP(w, get)
+				return
+			}
+		}
+
+		respond.ServeHTTP(w, r)
+	})
+}
+
+// allowedMethods lists the methods routes other than fallback accept for the request's path
+func allowedMethods(router *mux.Router, fallback *mux.Route, r *http.Request) []string {
+	var allowed []string
+	for _, method := range probedMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if routeMatches(router, fallback, probe) {
+			allowed = append(allowed, method)
+			if method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+		}
+	}
+
+	if len(allowed) > 0 {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	return allowed
+}
+
+func routeMatches(router *mux.Router, fallback *mux.Route, r *http.Request) bool {
+	var match mux.RouteMatch
+	return router.Match(r, &match) && match.MatchErr == nil && match.Route != fallback
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
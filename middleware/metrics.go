@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the request duration histogram
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	method string
+	path   string
+	status int
+}
+
+type routeKey struct {
+	method string
+	path   string
+}
+
+type histogram struct {
+	counts []uint64 // one per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// metricsRegistry is a small hand-rolled collector written out in the Prometheus text format
+type metricsRegistry struct {
+	mu          sync.Mutex
+	requests    map[requestKey]uint64
+	durations   map[routeKey]*histogram
+	rateLimited map[string]uint64
+	dbErrors    func() uint64
+}
+
+// metrics is nil until Metrics is used, so the limiters only count rejections when enabled
+var metrics *metricsRegistry
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		requests:    make(map[requestKey]uint64),
+		durations:   make(map[routeKey]*histogram),
+		rateLimited: make(map[string]uint64),
+	}
+}
+
+// Metrics records the count and duration of every request by method, route and status.
+// Routes are labelled with their template (e.g. /api/recipes/{id:[0-9]+}) so the number of
+// series stays bounded. It must be installed before the rate limiters to see their 429s.
+func Metrics() func(http.Handler) http.Handler {
+	if metrics == nil {
+		metrics = newMetricsRegistry()
+	}
+	registry := metrics
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			wrapper := &responseWrapper{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(wrapper, r)
+
+			registry.observeRequest(r.Method, routeTemplate(r), wrapper.statusCode, time.Since(start))
+		})
+	}
+}
+
+// ReportDBErrors sets where the metrics endpoint reads the number of failed database queries from
+func ReportDBErrors(count func() uint64) {
+	if metrics == nil {
+		metrics = newMetricsRegistry()
+	}
+	metrics.dbErrors = count
+}
+
+// MetricsHandler serves the collected metrics in the Prometheus text exposition format
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if metrics == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(metrics.render()))
+}
+
+// routeTemplate returns the matched route's path template, or "unmatched" for anything else
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
+
+// recordRateLimitRejection counts a request turned away by one of the SecurityManager limiters
+func recordRateLimitRejection(limiter string) {
+	if metrics == nil {
+		return
+	}
+
+	metrics.mu.Lock()
+	metrics.rateLimited[limiter]++
+	metrics.mu.Unlock()
+}
+
+func (m *metricsRegistry) observeRequest(method, path string, status int, duration time.Duration) {
+	seconds := duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{method, path, status}]++
+
+	h, ok := m.durations[routeKey{method, path}]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		m.durations[routeKey{method, path}] = h
+	}
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (m *metricsRegistry) render() string {
+	var b strings.Builder
+
+	m.mu.Lock()
+
+	requestKeys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, c := requestKeys[i], requestKeys[j]
+		if a.path != c.path {
+			return a.path < c.path
+		}
+		if a.method != c.method {
+			return a.method < c.method
+		}
+		return a.status < c.status
+	})
+
+	b.WriteString("# HELP http_requests_total Total HTTP requests by method, route and status.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	for _, key := range requestKeys {
+		fmt.Fprintf(&b, "http_requests_total{method=%s,path=%s,status=\"%d\"} %d\n",
+			labelValue(key.method), labelValue(key.path), key.status, m.requests[key])
+	}
+
+	routeKeys := make([]routeKey, 0, len(m.durations))
+	for key := range m.durations {
+		routeKeys = append(routeKeys, key)
+	}
+	sort.Slice(routeKeys, func(i, j int) bool {
+		if routeKeys[i].path != routeKeys[j].path {
+			return routeKeys[i].path < routeKeys[j].path
+		}
+		return routeKeys[i].method < routeKeys[j].method
+	})
+
+	b.WriteString("# HELP http_request_duration_seconds HTTP request duration by method and route.\n")
+	b.WriteString("# TYPE http_request_duration_seconds histogram\n")
+	for _, key := range routeKeys {
+		h := m.durations[key]
+		labels := fmt.Sprintf("method=%s,path=%s", labelValue(key.method), labelValue(key.path))
+
+		var cumulative uint64
+		for i, bound := range durationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&b, "http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	limiters := make([]string, 0, len(m.rateLimited))
+	for limiter := range m.rateLimited {
+		limiters = append(limiters, limiter)
+	}
+	sort.Strings(limiters)
+
+	b.WriteString("# HELP rate_limit_rejections_total Requests rejected by a rate limiter.\n")
+	b.WriteString("# TYPE rate_limit_rejections_total counter\n")
+	for _, limiter := range limiters {
+		fmt.Fprintf(&b, "rate_limit_rejections_total{limiter=%s} %d\n", labelValue(limiter), m.rateLimited[limiter])
+	}
+
+	dbErrors := m.dbErrors
+	m.mu.Unlock()
+
+	if dbErrors != nil {
+		b.WriteString("# HELP db_query_errors_total Database queries that returned an error.\n")
+		b.WriteString("# TYPE db_query_errors_total counter\n")
+		fmt.Fprintf(&b, "db_query_errors_total %d\n", dbErrors())
+	}
+
+	return b.String()
+}
+
+// labelValue quotes a label value, escaping backslashes, quotes and newlines
+func labelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
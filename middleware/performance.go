@@ -2,11 +2,12 @@ package middleware
 
 import (
 	"compress/gzip"
-	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"recipe-book/utils"
+
 	"golang.org/x/time/rate"
 )
 
@@ -27,7 +28,12 @@ func CacheHeaders() func(http.Handler) http.Handler {
 				ext := path[strings.LastIndex(path, "."):]
 				switch ext {
 				case ".js", ".css", ".woff", ".woff2", ".ttf", ".eot":
-					w.Header().Set("Cache-Control", "public, max-age=31536000") // 1 year
+					// Only a versioned URL changes along with the file, see utils.StaticAssetURL
+					if r.URL.Query().Get("v") != "" {
+						w.Header().Set("Cache-Control", "public, max-age=31536000, immutable") // 1 year
+					} else {
+						w.Header().Set("Cache-Control", "public, max-age=3600") // 1 hour
+					}
 				case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg":
 					w.Header().Set("Cache-Control", "public, max-age=86400") // 1 day
 				default:
@@ -40,7 +46,35 @@ func CacheHeaders() func(http.Handler) http.Handler {
 	}
 }
 
-// CompressionMiddleware adds gzip compression
+// VersionedAssets sets the cache headers of files served from the directory versions
+// hashes. Requests whose v parameter matches the file's current version, as added by
+// utils.StaticAssetURL, may be cached for good since the URL changes with the file; any
+// other request is cached for an hour, so that a rebuilt frontend soon reaches browsers.
+// The response may be gzipped, so caches have to key it on Accept-Encoding as well.
+func VersionedAssets(versions *utils.AssetVersions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), "Accept-Encoding")
+
+			v := r.URL.Query().Get("v")
+			if version, err := versions.Version(r.URL.Path); err == nil && v != "" && v == version {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable") // 1 year
+			} else {
+				w.Header().Set("Cache-Control", "public, max-age=3600") // 1 hour
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// gzipMinSize is the smallest response CompressionMiddleware compresses; gzip's own
+// overhead makes smaller ones no shorter
+const gzipMinSize = 1024
+
+// CompressionMiddleware adds gzip compression. The start of the response is buffered to
+// decide whether it is worth compressing: responses under gzipMinSize, partial content
+// and responses the handler already encoded are sent as they are.
 func CompressionMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -63,29 +97,127 @@ func CompressionMiddleware() func(http.Handler) http.Handler {
 				return
 			}
 
-			w.Header().Set("Content-Encoding", "gzip")
-			w.Header().Set("Vary", "Accept-Encoding")
-
-			gz := gzip.NewWriter(w)
-			defer gz.Close()
+			// Compressed or not, the response depends on Accept-Encoding
+			addVary(w.Header(), "Accept-Encoding") // keeping CORS's Vary: Origin
 
-			gzw := &gzipResponseWriter{
-				ResponseWriter: w,
-				Writer:         gz,
-			}
+			gzw := &gzipResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			defer gzw.Close()
 
 			next.ServeHTTP(gzw, r)
 		})
 	}
 }
 
+// addVary adds field to the Vary header unless it is already listed
+func addVary(header http.Header, field string) {
+	for _, value := range header.Values("Vary") {
+		for _, listed := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
+
+// gzipResponseWriter holds back the status and the first gzipMinSize bytes of a response
+// until it knows whether to compress it
 type gzipResponseWriter struct {
 	http.ResponseWriter
-	io.Writer
+	status  int
+	buf     []byte
+	started bool
+	gz      *gzip.Writer // nil unless the response is compressed
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if !w.started {
+		w.status = status
+	}
 }
 
 func (w *gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
+	if !w.started {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < gzipMinSize {
+			return len(b), nil
+		}
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// start sends the headers, deciding whether to compress, followed by the buffered bytes
+func (w *gzipResponseWriter) start() error {
+	w.started = true
+	header := w.Header()
+
+	compress := len(w.buf) >= gzipMinSize &&
+		w.status >= http.StatusOK && w.status != http.StatusNoContent &&
+		w.status != http.StatusPartialContent && w.status != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" && header.Get("Content-Range") == ""
+	if compress {
+		// Sniffing the compressed bytes would get the type wrong
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		contentType := header.Get("Content-Type")
+		compress = !strings.HasPrefix(contentType, "image/") && !strings.HasPrefix(contentType, "video/")
+	}
+
+	if compress {
+		// A length set by the handler is that of the uncompressed body
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends what has been written so far, letting handlers stream their response
+func (w *gzipResponseWriter) Flush() {
+	if !w.started {
+		w.start()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the response, sending it as it is if it stayed under gzipMinSize
+func (w *gzipResponseWriter) Close() error {
+	if !w.started {
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
 }
 
 // LightRateLimitConfig returns a lighter rate limiting config for faster startup
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	largeJSON, _ := json.Marshal(map[string]string{"instructions": strings.Repeat("Stir and simmer. ", 200)})
+	smallJSON := []byte(`{"title":"Toast"}`)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		status         int
+		header         map[string]string
+		body           []byte
+		wantGzip       bool
+	}{
+		{"large JSON", "gzip, deflate", http.StatusOK, map[string]string{"Content-Type": "application/json"}, largeJSON, true},
+		{"large JSON with a length", "gzip", http.StatusOK, map[string]string{"Content-Type": "application/json", "Content-Length": strconv.Itoa(len(largeJSON))}, largeJSON, true},
+		{"large error", "gzip", http.StatusNotFound, map[string]string{"Content-Type": "application/json"}, largeJSON, true},
+		{"under the minimum size", "gzip", http.StatusOK, map[string]string{"Content-Type": "application/json", "Content-Length": strconv.Itoa(len(smallJSON))}, smallJSON, false},
+		{"client without gzip", "", http.StatusOK, map[string]string{"Content-Type": "application/json"}, largeJSON, false},
+		{"already encoded", "gzip", http.StatusOK, map[string]string{"Content-Type": "application/json", "Content-Encoding": "br"}, largeJSON, false},
+		{"partial content", "gzip", http.StatusPartialContent, map[string]string{"Content-Type": "application/json", "Content-Range": "bytes 0-3399/5000"}, largeJSON, false},
+		{"image", "gzip", http.StatusOK, map[string]string{"Content-Type": "image/png"}, largeJSON, false},
+	}
+
+	for _, tt := range tests {
+		handler := CompressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for key, value := range tt.header {
+				w.Header().Set(key, value)
+			}
+			w.WriteHeader(tt.status)
+			// Written in two parts so the first stays under gzipMinSize
+			w.Write(tt.body[:len(tt.body)/2])
+			w.Write(tt.body[len(tt.body)/2:])
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/api/recipes/1", nil)
+		if tt.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+
+		body := w.Body.Bytes()
+		gzipped := w.Header().Get("Content-Encoding") == "gzip"
+		if gzipped != tt.wantGzip {
+			t.Errorf("%s: Content-Encoding %q, want gzip %v", tt.name, w.Header().Get("Content-Encoding"), tt.wantGzip)
+			continue
+		}
+		if gzipped {
+			if length := w.Header().Get("Content-Length"); length != "" {
+				t.Errorf("%s: compressed response keeps the uncompressed Content-Length %s", tt.name, length)
+			}
+			reader, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			if body, err = io.ReadAll(reader); err != nil {
+				t.Errorf("%s: decompressing: %v", tt.name, err)
+				continue
+			}
+		} else if length := tt.header["Content-Length"]; length != "" && w.Header().Get("Content-Length") != length {
+			t.Errorf("%s: Content-Length %q, want %s", tt.name, w.Header().Get("Content-Length"), length)
+		}
+
+		if string(body) != string(tt.body) {
+			t.Errorf("%s: body of %d bytes does not match the %d written", tt.name, len(body), len(tt.body))
+		}
+		if tt.acceptEncoding != "" && w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: Vary %q, want Accept-Encoding", tt.name, w.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestCompressionMiddlewareEmptyResponse(t *testing.T) {
+	handler := CompressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/recipes/1", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("got %d with %d bytes and Content-Encoding %q, want an empty 204", w.Code, w.Body.Len(), w.Header().Get("Content-Encoding"))
+	}
+}
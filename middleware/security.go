@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net"
+	"math"
 	"net/http"
+	"os"
+	"recipe-book/auth"
+	"recipe-book/utils"
 	"regexp"
 	"strconv"
 	"strings"
@@ -63,6 +66,11 @@ type RateLimitConfig struct {
 	GeneralBurst  int
 	GeneralWindow time.Duration
 
+	// Key the general limit on the authenticated user rather than the client IP, so that
+	// users behind a shared NAT don't throttle each other. Anonymous requests, including
+	// logins and registrations, are still limited by IP.
+	GeneralPerUser bool
+
 	// Block duration for repeated violations
 	BlockDuration time.Duration
 }
@@ -95,6 +103,98 @@ func DefaultRateLimitConfig() *RateLimitConfig {
 	}
 }
 
+// RateLimitConfigFromEnv starts from LightRateLimitConfig and applies overrides from
+// the environment:
+//
+//	RATE_{LOGIN,REGISTER,SEARCH,GENERAL}_PER_MINUTE  sustained requests per minute
+//	RATE_{LOGIN,REGISTER,SEARCH,GENERAL}_BURST       requests allowed in a burst
+//	RATE_BLOCK_MINUTES                               how long repeat offenders are blocked
+//	RATE_GENERAL_PER_USER                            true to limit signed-in users by account
+//
+// Unset variables keep the default; invalid or non-positive values are logged and ignored.
+func RateLimitConfigFromEnv() *RateLimitConfig {
+	config := LightRateLimitConfig()
+
+	limits := []struct {
+		name  string
+		rate  *rate.Limit
+		burst *int
+	}{
+		{"LOGIN", &config.LoginRate, &config.LoginBurst},
+		{"REGISTER", &config.RegisterRate, &config.RegisterBurst},
+		{"SEARCH", &config.SearchRate, &config.SearchBurst},
+		{"GENERAL", &config.GeneralRate, &config.GeneralBurst},
+	}
+
+	var applied []string
+	for _, limit := range limits {
+		key := "RATE_" + limit.name + "_PER_MINUTE"
+		if perMinute, ok := positiveFloatFromEnv(key); ok {
+			*limit.rate = rate.Limit(perMinute / 60)
+			applied = append(applied, fmt.Sprintf("%s=%g", key, perMinute))
+		}
+
+		key = "RATE_" + limit.name + "_BURST"
+		if burst, ok := positiveIntFromEnv(key); ok {
+			*limit.burst = burst
+			applied = append(applied, fmt.Sprintf("%s=%d", key, burst))
+		}
+	}
+
+	if minutes, ok := positiveFloatFromEnv("RATE_BLOCK_MINUTES"); ok {
+		config.BlockDuration = time.Duration(minutes * float64(time.Minute))
+		applied = append(applied, fmt.Sprintf("RATE_BLOCK_MINUTES=%g", minutes))
+	}
+
+	if value := strings.TrimSpace(os.Getenv("RATE_GENERAL_PER_USER")); value != "" {
+		if perUser, err := strconv.ParseBool(value); err != nil {
+			log.Printf("⚠️  Ignoring RATE_GENERAL_PER_USER=%q: expected true or false", value)
+		} else {
+			config.GeneralPerUser = perUser
+			applied = append(applied, fmt.Sprintf("RATE_GENERAL_PER_USER=%t", perUser))
+		}
+	}
+
+	if len(applied) > 0 {
+		log.Printf("⚙️  Rate limit overrides applied: %s", strings.Join(applied, ", "))
+	}
+
+	return config
+}
+
+// positiveFloatFromEnv reads a positive number from the environment. It reports false
+// when the variable is unset or invalid, logging the latter.
+func positiveFloatFromEnv(key string) (float64, bool) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return 0, false
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number <= 0 || math.IsInf(number, 0) || math.IsNaN(number) {
+		log.Printf("⚠️  Ignoring %s=%q: expected a positive number", key, value)
+		return 0, false
+	}
+
+	return number, true
+}
+
+// positiveIntFromEnv is like positiveFloatFromEnv for whole numbers
+func positiveIntFromEnv(key string) (int, bool) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return 0, false
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		log.Printf("⚠️  Ignoring %s=%q: expected a positive whole number", key, value)
+		return 0, false
+	}
+
+	return number, true
+}
+
 // NewSecurityManager creates a new security manager
 func NewSecurityManager(config *RateLimitConfig) *SecurityManager {
 	if config == nil {
@@ -116,31 +216,6 @@ func NewSecurityManager(config *RateLimitConfig) *SecurityManager {
 	return sm
 }
 
-// Get client IP address
-func (sm *SecurityManager) getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (for reverse proxies)
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return strings.TrimSpace(xri)
-	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
-}
-
 // Check if IP is blocked
 func (sm *SecurityManager) isBlocked(ip string) (bool, time.Duration) {
 	sm.mu.RLock()
@@ -236,25 +311,31 @@ func (sm *SecurityManager) cleanupRoutine() {
 func (sm *SecurityManager) GeneralRateLimit(config *RateLimitConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := sm.getClientIP(r)
+			ip := utils.ClientIP(r)
 
 			// Check if IP is blocked
 			if blocked, remaining := sm.isBlocked(ip); blocked {
-				w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())))
-				http.Error(w, fmt.Sprintf("Rate limit exceeded. Try again in %v", remaining.Round(time.Second)), http.StatusTooManyRequests)
+				recordRateLimitRejection("general")
+				sm.respondWithError(w, fmt.Sprintf("Rate limit exceeded. Try again in %v", remaining.Round(time.Second)), remaining)
 				log.Printf("⚠️  Blocked request from %s (blocked for %v more)", ip, remaining.Round(time.Second))
 				return
 			}
 
-			// Get rate limiter for this IP
-			limiter := sm.getRateLimiter(sm.generalLimiters, ip, config.GeneralRate, config.GeneralBurst)
+			// Get rate limiter for this IP, or for the signed-in user
+			key := ip
+			if config.GeneralPerUser {
+				if userID := requestUserID(r); userID != 0 {
+					key = fmt.Sprintf("user:%d", userID)
+				}
+			}
+			limiter := sm.getRateLimiter(sm.generalLimiters, key, config.GeneralRate, config.GeneralBurst)
 
 			if !limiter.Allow() {
 				// Count violations and potentially block IP
-				sm.handleRateViolation(ip, "general", config.BlockDuration)
+				sm.handleRateViolation(key, "general", config.BlockDuration)
+				recordRateLimitRejection("general")
 
-				w.Header().Set("Retry-After", "60")
-				http.Error(w, "Rate limit exceeded. Please slow down.", http.StatusTooManyRequests)
+				sm.respondWithError(w, "Rate limit exceeded. Please slow down.", retryDelay(limiter))
 				return
 			}
 
@@ -267,12 +348,12 @@ func (sm *SecurityManager) GeneralRateLimit(config *RateLimitConfig) func(http.H
 func (sm *SecurityManager) LoginRateLimit(config *RateLimitConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := sm.getClientIP(r)
+			ip := utils.ClientIP(r)
 
 			// Check if IP is blocked
 			if blocked, remaining := sm.isBlocked(ip); blocked {
-				w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())))
-				sm.respondWithError(w, fmt.Sprintf("Too many login attempts. Try again in %v", remaining.Round(time.Second)), "login.html")
+				recordRateLimitRejection("login")
+				sm.respondWithError(w, fmt.Sprintf("Too many login attempts. Try again in %v", remaining.Round(time.Second)), remaining)
 				return
 			}
 
@@ -282,8 +363,9 @@ func (sm *SecurityManager) LoginRateLimit(config *RateLimitConfig) func(http.Han
 			if !limiter.Allow() {
 				// Block IP after repeated login violations
 				sm.blockIP(ip, config.BlockDuration)
+				recordRateLimitRejection("login")
 
-				sm.respondWithError(w, "Too many login attempts. Your IP has been temporarily blocked.", "login.html")
+				sm.respondWithError(w, "Too many login attempts. Your IP has been temporarily blocked.", config.BlockDuration)
 				log.Printf("🚨 Blocked IP %s due to excessive login attempts", ip)
 				return
 			}
@@ -297,12 +379,12 @@ func (sm *SecurityManager) LoginRateLimit(config *RateLimitConfig) func(http.Han
 func (sm *SecurityManager) RegisterRateLimit(config *RateLimitConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := sm.getClientIP(r)
+			ip := utils.ClientIP(r)
 
 			// Check if IP is blocked
 			if blocked, remaining := sm.isBlocked(ip); blocked {
-				w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())))
-				sm.respondWithError(w, fmt.Sprintf("Rate limit exceeded. Try again in %v", remaining.Round(time.Second)), "register.html")
+				recordRateLimitRejection("register")
+				sm.respondWithError(w, fmt.Sprintf("Rate limit exceeded. Try again in %v", remaining.Round(time.Second)), remaining)
 				return
 			}
 
@@ -310,7 +392,8 @@ func (sm *SecurityManager) RegisterRateLimit(config *RateLimitConfig) func(http.
 			limiter := sm.getRateLimiter(sm.registerLimiters, ip, config.RegisterRate, config.RegisterBurst)
 
 			if !limiter.Allow() {
-				sm.respondWithError(w, "Too many registration attempts. Please try again later.", "register.html")
+				recordRateLimitRejection("register")
+				sm.respondWithError(w, "Too many registration attempts. Please try again later.", retryDelay(limiter))
 				log.Printf("⚠️  Registration rate limit exceeded for IP %s", ip)
 				return
 			}
@@ -324,17 +407,14 @@ func (sm *SecurityManager) RegisterRateLimit(config *RateLimitConfig) func(http.
 func (sm *SecurityManager) SearchRateLimit(config *RateLimitConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := sm.getClientIP(r)
+			ip := utils.ClientIP(r)
 
 			// Get rate limiter for this IP
 			limiter := sm.getRateLimiter(sm.searchLimiters, ip, config.SearchRate, config.SearchBurst)
 
 			if !limiter.Allow() {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
-				json.NewEncoder(w).Encode(map[string]string{
-					"error": "Search rate limit exceeded. Please slow down.",
-				})
+				recordRateLimitRejection("search")
+				sm.respondWithError(w, "Search rate limit exceeded. Please slow down.", retryDelay(limiter))
 				log.Printf("⚠️  Search rate limit exceeded for IP %s", ip)
 				return
 			}
@@ -345,17 +425,38 @@ func (sm *SecurityManager) SearchRateLimit(config *RateLimitConfig) func(http.Ha
 }
 
 // Handle rate limit violations
-func (sm *SecurityManager) handleRateViolation(ip, violationType string, blockDuration time.Duration) {
+func (sm *SecurityManager) handleRateViolation(client, violationType string, blockDuration time.Duration) {
 	// For now, we just log the violation
 	// In a more sophisticated system, you might track violation counts
-	log.Printf("⚠️  Rate limit violation from IP %s for %s requests", ip, violationType)
+	log.Printf("⚠️  Rate limit violation from %s for %s requests", client, violationType)
 }
 
-// Respond with error for HTML pages
-func (sm *SecurityManager) respondWithError(w http.ResponseWriter, message, template string) {
-	// For now, just return a simple error
-	// In your actual implementation, you might want to render the template with the error
-	http.Error(w, message, http.StatusTooManyRequests)
+// respondWithError sends a 429 JSON error telling the client how many seconds to wait,
+// both in the body and in the Retry-After header
+func (sm *SecurityManager) respondWithError(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":               message,
+		"retry_after_seconds": seconds,
+	})
+}
+
+// retryDelay returns how long until the limiter allows the next request, without using up a token
+func retryDelay(limiter *rate.Limiter) time.Duration {
+	reservation := limiter.Reserve()
+	defer reservation.Cancel()
+
+	if !reservation.OK() {
+		return time.Minute
+	}
+	return reservation.Delay()
 }
 
 // Security headers middleware
@@ -388,6 +489,34 @@ func SecurityHeaders() func(http.Handler) http.Handler {
 }
 
 // Logging middleware
+// maxRequestIDLength bounds client-supplied request IDs so they cannot flood the logs
+const maxRequestIDLength = 64
+
+// requestIDPattern is what a client-supplied X-Request-ID must look like to be reused
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// RequestID tags each request with an ID, taken from a well-formed X-Request-ID header or
+// generated. The ID is stored in the request context, echoed in the response header and
+// included in request and security log lines.
+func RequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get("X-Request-ID")
+			if len(id) > maxRequestIDLength || !requestIDPattern.MatchString(id) {
+				generated, err := utils.GenerateSecureToken(8)
+				if err != nil {
+					next.ServeHTTP(w, r)
+					return
+				}
+				id = generated
+			}
+
+			w.Header().Set("X-Request-ID", id)
+			next.ServeHTTP(w, r.WithContext(utils.WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
 func RequestLogging() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -401,22 +530,69 @@ func RequestLogging() func(http.Handler) http.Handler {
 			duration := time.Since(start)
 
 			// Log the request
-			log.Printf("%s %s %s %d %v %s",
+			log.Printf("%s %s %s %d %v %s %s",
 				r.Method,
 				r.RequestURI,
 				r.RemoteAddr,
 				wrapper.statusCode,
 				duration,
 				r.UserAgent(),
+				utils.RequestIDFromContext(r.Context()),
 			)
 		})
 	}
 }
 
-// Response wrapper to capture status code
+// requestLogEntry is the shape of a RequestLoggingJSON log line
+type requestLogEntry struct {
+	Time         string  `json:"time"`
+	Method       string  `json:"method"`
+	Path         string  `json:"path"`
+	RemoteIP     string  `json:"remote_ip"`
+	Status       int     `json:"status"`
+	DurationMs   float64 `json:"duration_ms"`
+	UserAgent    string  `json:"user_agent"`
+	BytesWritten int64   `json:"bytes_written"`
+	RequestID    string  `json:"request_id,omitempty"`
+}
+
+// RequestLoggingJSON logs one JSON object per request, for log aggregators
+func RequestLoggingJSON() func(http.Handler) http.Handler {
+	// No timestamp prefix so every line is valid JSON
+	logger := log.New(os.Stderr, "", 0)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			wrapper := &responseWrapper{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(wrapper, r)
+
+			entry, err := json.Marshal(requestLogEntry{
+				Time:         start.UTC().Format(time.RFC3339),
+				Method:       r.Method,
+				Path:         r.URL.Path,
+				RemoteIP:     utils.ClientIP(r),
+				Status:       wrapper.statusCode,
+				DurationMs:   float64(time.Since(start).Microseconds()) / 1000,
+				UserAgent:    r.UserAgent(),
+				BytesWritten: wrapper.bytesWritten,
+				RequestID:    utils.RequestIDFromContext(r.Context()),
+			})
+			if err != nil {
+				return
+			}
+			logger.Println(string(entry))
+		})
+	}
+}
+
+// Response wrapper to capture status code and response size
 type responseWrapper struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	bytesWritten int64
 }
 
 func (rw *responseWrapper) WriteHeader(code int) {
@@ -424,6 +600,12 @@ func (rw *responseWrapper) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWrapper) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += int64(n)
+	return n, err
+}
+
 // SQL Injection protection middleware
 func SQLInjectionProtection() func(http.Handler) http.Handler {
 	// Common SQL injection patterns
@@ -498,16 +680,26 @@ const SecurityContextKey contextKey = "security"
 // Security info to pass in context
 type SecurityInfo struct {
 	ClientIP    string
+	UserID      int // from the auth token, 0 for anonymous requests
 	UserAgent   string
 	RequestTime time.Time
 }
 
+// requestUserID returns the user AddSecurityContext found for the request, or 0
+func requestUserID(r *http.Request) int {
+	if secInfo, ok := r.Context().Value(SecurityContextKey).(*SecurityInfo); ok {
+		return secInfo.UserID
+	}
+	return 0
+}
+
 // Add security info to context
 func (sm *SecurityManager) AddSecurityContext() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			secInfo := &SecurityInfo{
-				ClientIP:    sm.getClientIP(r),
+				ClientIP:    utils.ClientIP(r),
+				UserID:      auth.UserIDFromToken(r),
 				UserAgent:   r.UserAgent(),
 				RequestTime: time.Now(),
 			}
@@ -518,35 +710,33 @@ func (sm *SecurityManager) AddSecurityContext() func(http.Handler) http.Handler
 	}
 }
 
-// CORS middleware for frontend-backend communication
-func CORSMiddleware() func(http.Handler) http.Handler {
+// CORSConfig lists the cross-origin frontends allowed to call the API with credentials
+type CORSConfig struct {
+	AllowedOrigins []string
+}
+
+// CORS middleware for frontend-backend communication. Only origins in the allowlist get
+// CORS headers; with an empty allowlist only same-origin requests are possible.
+func CORSMiddleware(config *CORSConfig) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(config.AllowedOrigins))
+	for _, origin := range config.AllowedOrigins {
+		allowed[origin] = true
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Allow requests from frontend development server and production
 			origin := r.Header.Get("Origin")
-			allowedOrigins := []string{
-				"http://localhost:3000", // Vite dev server
-				"http://localhost:5173", // Alternative Vite port
-				"http://localhost:8080", // Same origin
-				"http://127.0.0.1:8080", // Same origin alternative
-			}
-
-			// Check if origin is allowed
-			for _, allowedOrigin := range allowedOrigins {
-				if origin == allowedOrigin {
-					w.Header().Set("Access-Control-Allow-Origin", origin)
-					break
-				}
-			}
-
-			// If no origin header (same-origin requests), allow
-			if origin == "" {
-				w.Header().Set("Access-Control-Allow-Origin", "*")
+			if origin == "" || !allowed[origin] {
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Request-ID, Idempotency-Key")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 			w.Header().Set("Access-Control-Max-Age", "86400")
 
 			// Handle preflight requests
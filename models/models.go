@@ -4,15 +4,28 @@ package models
 import "time"
 
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"-"`
+	ID            int    `json:"id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	Password      string `json:"-"`
+	IsAdmin       bool   `json:"is_admin"`
+	EmailVerified bool   `json:"email_verified"`
+	TokenVersion  int    `json:"-"` // tokens issued for another version are revoked
+}
+
+// UserProfile is the public view of a user. Email is only filled in for the user themselves.
+type UserProfile struct {
+	ID          int       `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email,omitempty"`
+	JoinedAt    time.Time `json:"joined_at"`
+	RecipeCount int       `json:"recipe_count"`
 }
 
 type Ingredient struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
 }
 
 // Add this new Tag struct
@@ -22,37 +35,169 @@ type Tag struct {
 	Color string `json:"color"`
 }
 
+// TagUsage is a tag with the number of recipes that use it
+type TagUsage struct {
+	Tag
+	RecipeCount int `json:"recipe_count"`
+}
+
+// IngredientUsage is an ingredient with the number of recipes that use it
+type IngredientUsage struct {
+	Ingredient
+	RecipeCount int `json:"recipe_count"`
+}
+
+// SiteStats are site-wide counts for a dashboard. Recipes and the usage counts only
+// cover public, published recipes.
+type SiteStats struct {
+	Recipes        int               `json:"recipes"`
+	Ingredients    int               `json:"ingredients"`
+	Tags           int               `json:"tags"`
+	Users          int               `json:"users"`
+	TopTags        []TagUsage        `json:"top_tags"`
+	TopIngredients []IngredientUsage `json:"top_ingredients"`
+	GeneratedAt    time.Time         `json:"generated_at"`
+}
+
 type RecipeIngredient struct {
-	IngredientID int     `json:"ingredient_id"`
-	Name         string  `json:"name"`
-	Unit         string  `json:"unit"`
-	Quantity     float64 `json:"quantity"`
+	IngredientID    int     `json:"ingredient_id"`
+	Name            string  `json:"name"`
+	Unit            string  `json:"unit"`
+	Quantity        float64 `json:"quantity"`
+	QuantityDisplay string  `json:"quantity_display,omitempty"` // e.g. "1 1/2"; only set on scaled recipes
+	Preparation     string  `json:"preparation,omitempty"`      // e.g. "finely chopped"
+	Optional        bool    `json:"optional,omitempty"`         // garnishes and add-ins the dish works without
 }
 
 type RecipeImage struct {
-	ID       int    `json:"id"`
-	RecipeID int    `json:"recipe_id"`
-	Filename string `json:"filename"`
-	Caption  string `json:"caption"`
-	Order    int    `json:"order"`
+	ID        int    `json:"id"`
+	RecipeID  int    `json:"recipe_id"`
+	Filename  string `json:"filename"`
+	Caption   string `json:"caption"`
+	Order     int    `json:"order"`
+	IsPrimary bool   `json:"is_primary"`
 }
 
 // Update Recipe struct to include Tags
 type Recipe struct {
-	ID           int                `json:"id"`
-	Title        string             `json:"title"`
-	Description  string             `json:"description"`
-	Instructions string             `json:"instructions"`
-	PrepTime     int                `json:"prep_time"`
-	CookTime     int                `json:"cook_time"`
-	Servings     int                `json:"servings"`
-	ServingUnit  string             `json:"serving_unit"`
-	CreatedBy    int                `json:"created_by"`
-	CreatedAt    time.Time          `json:"created_at"`
-	Ingredients  []RecipeIngredient `json:"ingredients"`
-	Images       []RecipeImage      `json:"images"`
-	Tags         []Tag              `json:"tags"` // Add this line
-	AuthorName   string             `json:"author_name"`
+	ID            int                `json:"id"`
+	Title         string             `json:"title"`
+	Slug          string             `json:"slug"`
+	Description   string             `json:"description"`
+	Instructions  string             `json:"instructions"`
+	Steps         []string           `json:"steps,omitempty"` // Only set on the single recipe view
+	PrepTime      int                `json:"prep_time"`
+	CookTime      int                `json:"cook_time"`
+	TotalTime     int                `json:"total_time"` // PrepTime + CookTime, computed when loaded
+	Servings      int                `json:"servings"`
+	ServingUnit   string             `json:"serving_unit"`
+	YieldQuantity float64            `json:"yield_quantity"` // what the recipe makes, e.g. 2 dozen; 0 when not stated
+	YieldUnit     string             `json:"yield_unit"`
+	Cuisine       string             `json:"cuisine"`
+	SourceURL     string             `json:"source_url"`
+	VideoURL      string             `json:"video_url"`
+	ViewCount     int                `json:"view_count"`
+	IsPublic      bool               `json:"is_public"`
+	Status        string             `json:"status"`
+	CreatedBy     int                `json:"created_by"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     *time.Time         `json:"updated_at,omitempty"`
+	Ingredients   []RecipeIngredient `json:"ingredients"`
+	Images        []RecipeImage      `json:"images"`
+	CoverImage    *RecipeImage       `json:"cover_image"`
+	Tags          []Tag              `json:"tags"` // Add this line
+	AuthorName    string             `json:"author_name"`
+	AverageRating float64            `json:"average_rating"`
+	RatingCount   int                `json:"rating_count"`
+	Nutrition     *Nutrition         `json:"nutrition,omitempty"` // Only set on the single recipe view
+	DeletedAt     *time.Time         `json:"deleted_at,omitempty"`
+	Relevance     int                `json:"relevance,omitempty"` // Only set by search
+}
+
+// RecipeSummary is the short form of a recipe used to link to it from other pages
+type RecipeSummary struct {
+	ID         int          `json:"id"`
+	Title      string       `json:"title"`
+	CoverImage *RecipeImage `json:"cover_image"`
+}
+
+// Recipe statuses. Drafts are only visible to their owner and may be incomplete.
+const (
+	RecipeStatusDraft     = "draft"
+	RecipeStatusPublished = "published"
+)
+
+// Nutrition holds per-recipe nutritional values. Values that were not provided are nil.
+type Nutrition struct {
+	Calories *float64 `json:"calories,omitempty"`
+	ProteinG *float64 `json:"protein_g,omitempty"`
+	CarbsG   *float64 `json:"carbs_g,omitempty"`
+	FatG     *float64 `json:"fat_g,omitempty"`
+}
+
+// IsEmpty reports whether no nutritional value is set
+func (n *Nutrition) IsEmpty() bool {
+	return n.Calories == nil && n.ProteinG == nil && n.CarbsG == nil && n.FatG == nil
+}
+
+// Comment is a note left on a recipe
+type Comment struct {
+	ID         int       `json:"id"`
+	RecipeID   int       `json:"recipe_id"`
+	UserID     int       `json:"user_id"`
+	AuthorName string    `json:"author_name"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// MealPlanEntry is a recipe planned for a meal on a given day
+type MealPlanEntry struct {
+	ID          int          `json:"id"`
+	RecipeID    int          `json:"recipe_id"`
+	RecipeTitle string       `json:"recipe_title"`
+	CoverImage  *RecipeImage `json:"cover_image"`
+	PlanDate    string       `json:"plan_date"` // YYYY-MM-DD
+	MealType    string       `json:"meal_type"`
+}
+
+// MealPlanDay groups the meal plan entries for one day
+type MealPlanDay struct {
+	Date    string          `json:"date"`
+	Entries []MealPlanEntry `json:"entries"`
+}
+
+// AuditEntry is a security event persisted in the audit log
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	EventType string    `json:"event_type"`
+	UserID    *int      `json:"user_id"`
+	ClientIP  string    `json:"client_ip"`
+	Details   string    `json:"details"`
+}
+
+// RecipeMatch is a recipe ranked by how many of its ingredients the user already has
+type RecipeMatch struct {
+	Recipe
+	MatchPercentage    float64            `json:"match_percentage"`
+	MissingIngredients []RecipeIngredient `json:"missing_ingredients"`
+}
+
+// SetImages attaches images to the recipe and picks its cover image: the image marked
+// primary, or the one with the lowest display order when none is
+func (r *Recipe) SetImages(images []RecipeImage) {
+	r.Images = images
+	r.CoverImage = nil
+
+	for i := range images {
+		if images[i].IsPrimary {
+			r.CoverImage = &images[i]
+			return
+		}
+		if r.CoverImage == nil || images[i].Order < r.CoverImage.Order {
+			r.CoverImage = &images[i]
+		}
+	}
 }
 
 type Claims struct {
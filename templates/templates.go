@@ -0,0 +1,9 @@
+// Package templates holds the few pages rendered by the server rather than the React app
+package templates
+
+import "embed"
+
+// FS contains the HTML templates, embedded so the binary runs without a templates directory
+//
+//go:embed *.html
+var FS embed.FS
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AssetVersions hashes the files under a directory for cache-busting URLs. A file is
+// hashed the first time its version is asked for and again whenever it changes on disk,
+// so a rebuilt frontend is picked up without restarting the server.
+type AssetVersions struct {
+	dir string
+
+	mu       sync.Mutex
+	versions map[string]assetVersion
+}
+
+type assetVersion struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// NewAssetVersions versions the files under dir
+func NewAssetVersions(dir string) *AssetVersions {
+	return &AssetVersions{dir: dir, versions: make(map[string]assetVersion)}
+}
+
+// Version returns a short hash of the content of name, a slash-separated path relative
+// to the directory
+func (a *AssetVersions) Version(name string) (string, error) {
+	name = path.Clean("/" + name) // never outside the directory
+	file := filepath.Join(a.dir, filepath.FromSlash(name))
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", name)
+	}
+
+	a.mu.Lock()
+	cached, ok := a.versions[name]
+	a.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.hash, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))[:16]
+
+	a.mu.Lock()
+	a.versions[name] = assetVersion{modTime: info.ModTime(), size: info.Size(), hash: hash}
+	a.mu.Unlock()
+
+	return hash, nil
+}
+
+// StaticAssets versions the frontend build served under /static/
+var StaticAssets = NewAssetVersions("./static/dist")
+
+// StaticAssetURL appends the version of a file served under /static/ to its URL, as in
+// /static/favicon.svg?v=1a2b3c4d5e6f7a8b. Other URLs, and those of missing files, are
+// returned unchanged.
+func StaticAssetURL(url string) string {
+	name := strings.TrimPrefix(url, "/static/")
+	if name == url {
+		return url
+	}
+
+	version, err := StaticAssets.Version(name)
+	if err != nil {
+		return url
+	}
+	return url + "?v=" + version
+}
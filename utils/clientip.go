@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxies are the ranges whose forwarding headers ClientIP believes, see
+// SetTrustedProxies
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies sets the reverse proxies allowed to report the client address in
+// X-Forwarded-For and X-Real-IP. It is meant to be called once at startup; with no
+// ranges, the headers are ignored.
+func SetTrustedProxies(ranges []*net.IPNet) {
+	trustedProxies = ranges
+}
+
+// ClientIP resolves the address of the client that made the request. The forwarding
+// headers are only honoured when the request comes from a trusted proxy, so other
+// clients can't spoof their address past the rate limiters and logs. Each proxy appends
+// the address it was connected from to X-Forwarded-For; the client is the last one not
+// added by a trusted proxy.
+func ClientIP(r *http.Request) string {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+	if !isTrustedProxy(remoteIP) {
+		return remoteIP
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if i == 0 || !isTrustedProxy(hop) {
+				return hop
+			}
+		}
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		return xri
+	}
+
+	return remoteIP
+}
+
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIPRanges parses a comma-separated list of CIDR ranges such as 10.0.0.0/8 and
+// single addresses, the latter becoming ranges of one address
+func ParseIPRanges(list string) ([]*net.IPNet, error) {
+	var ranges []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return ranges, nil
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// trustProxies makes ClientIP trust the given ranges for the rest of the test
+func trustProxies(t *testing.T, list string) {
+	t.Helper()
+
+	ranges, err := ParseIPRanges(list)
+	if err != nil {
+		t.Fatalf("ParseIPRanges(%q): %v", list, err)
+	}
+	SetTrustedProxies(ranges)
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+}
+
+// clientIPCase is a request from remoteAddr with the given forwarding headers
+type clientIPCase struct {
+	name         string
+	remoteAddr   string
+	forwardedFor string
+	realIP       string
+	want         string
+}
+
+func checkClientIP(t *testing.T, tests []clientIPCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/recipes", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+
+		if got := ClientIP(r); got != tt.want {
+			t.Errorf("%s: ClientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientIPIgnoresSpoofedHeaders(t *testing.T) {
+	checkClientIP(t, []clientIPCase{
+		{"no trusted proxies", "203.0.113.7:51000", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+	})
+
+	trustProxies(t, "10.0.0.0/8, 192.0.2.1")
+	checkClientIP(t, []clientIPCase{
+		{"forwarded for from a client", "203.0.113.7:51000", "198.51.100.1", "", "203.0.113.7"},
+		{"real IP from a client", "203.0.113.7:51000", "", "198.51.100.2", "203.0.113.7"},
+		{"client next to the proxy range", "11.0.0.1:51000", "198.51.100.1", "", "11.0.0.1"},
+		{"proxy address as a client", "192.0.2.2:51000", "198.51.100.1", "", "192.0.2.2"},
+		{"forwarded for from a trusted proxy", "10.1.2.3:443", "198.51.100.1", "", "198.51.100.1"},
+		{"single trusted address", "192.0.2.1:443", "198.51.100.1", "", "198.51.100.1"},
+		// The client put its own forged address first; the proxy appended the real one
+		{"forged hop before the client", "10.1.2.3:443", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.1.2.3:443", "198.51.100.1, 10.9.9.9, 192.0.2.1", "", "198.51.100.1"},
+		{"only trusted hops", "10.1.2.3:443", "10.0.0.5, 10.0.0.6", "", "10.0.0.5"},
+	})
+}
+
+func TestParseIPRanges(t *testing.T) {
+	ranges, err := ParseIPRanges(" 10.0.0.0/8,, 192.0.2.1 ,2001:db8::/32,::1")
+	if err != nil {
+		t.Fatalf("ParseIPRanges: %v", err)
+	}
+
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::/32", "::1/128"}
+	if len(ranges) != len(want) {
+		t.Fatalf("parsed %d ranges, want %d", len(ranges), len(want))
+	}
+	for i, ipNet := range ranges {
+		if ipNet.String() != want[i] {
+			t.Errorf("range %d = %s, want %s", i, ipNet, want[i])
+		}
+	}
+
+	for _, list := range []string{"proxy.internal", "10.0.0.0/33", "10.0.0.1, 300.0.0.1"} {
+		if _, err := ParseIPRanges(list); err == nil {
+			t.Errorf("ParseIPRanges(%q) accepted an invalid entry", list)
+		}
+	}
+}
+
+func TestClientIPHeaderPrecedence(t *testing.T) {
+	trustProxies(t, "10.0.0.0/8, ::1")
+	checkClientIP(t, []clientIPCase{
+		{"forwarded for over real IP", "10.1.2.3:443", "198.51.100.1", "198.51.100.2", "198.51.100.1"},
+		{"real IP without forwarded for", "10.1.2.3:443", "", " 198.51.100.2 ", "198.51.100.2"},
+		{"spaces around hops", "10.1.2.3:443", " 198.51.100.1 ,10.0.0.5 ", "", "198.51.100.1"},
+		{"trusted proxy without headers", "10.1.2.3:443", "", "", "10.1.2.3"},
+		{"IPv6 proxy", "[::1]:443", "2001:db8::7", "", "2001:db8::7"},
+	})
+}
+
+func TestClientIPRemoteAddrFallback(t *testing.T) {
+	checkClientIP(t, []clientIPCase{
+		{"IPv4 with port", "203.0.113.7:51000", "", "", "203.0.113.7"},
+		{"IPv6 with port", "[2001:db8::7]:51000", "", "", "2001:db8::7"},
+		{"without port", "203.0.113.7", "", "", "203.0.113.7"},
+		{"not an address", "pipe", "198.51.100.1", "", "pipe"},
+	})
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"fmt"
+	"recipe-book/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatRecipeMarkdown renders a recipe as a printable Markdown document
+func FormatRecipeMarkdown(recipe *models.Recipe) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", strings.TrimSpace(recipe.Title))
+
+	if description := strings.TrimSpace(recipe.Description); description != "" {
+		fmt.Fprintf(&b, "%s\n\n", description)
+	}
+
+	if recipe.Cuisine != "" {
+		fmt.Fprintf(&b, "**Cuisine:** %s | ", recipe.Cuisine)
+	}
+	fmt.Fprintf(&b, "**Prep:** %d min | **Cook:** %d min | **Servings:** %d %s",
+		recipe.PrepTime, recipe.CookTime, recipe.Servings, recipe.ServingUnit)
+	if recipe.YieldQuantity > 0 {
+		fmt.Fprintf(&b, " | **Makes:** %s %s", FormatQuantity(recipe.YieldQuantity), recipe.YieldUnit)
+	}
+	b.WriteString("\n\n")
+
+	if recipe.SourceURL != "" {
+		fmt.Fprintf(&b, "**Source:** <%s>\n\n", recipe.SourceURL)
+	}
+	if recipe.VideoURL != "" {
+		fmt.Fprintf(&b, "**Video:** <%s>\n\n", recipe.VideoURL)
+	}
+
+	if len(recipe.Ingredients) > 0 {
+		b.WriteString("## Ingredients\n\n")
+		for _, ingredient := range recipe.Ingredients {
+			fmt.Fprintf(&b, "- %s %s %s%s\n",
+				FormatQuantity(ingredient.Quantity), ingredient.Unit, ingredient.Name, preparationSuffix(ingredient))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Instructions\n\n")
+	for i, step := range ParseInstructionSteps(recipe.Instructions) {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step)
+	}
+
+	return b.String()
+}
+
+// RecipeCSVHeader is the header row of the CSV recipe export
+var RecipeCSVHeader = []string{
+	"id", "title", "description", "prep_time", "cook_time", "servings", "serving_unit", "created_at", "ingredients",
+	"yield_quantity", "yield_unit",
+}
+
+// preparationSuffix renders an ingredient's preparation note and whether it is optional
+// after its name, as in "onion, finely chopped (optional)"
+func preparationSuffix(ingredient models.RecipeIngredient) string {
+	suffix := ""
+	if ingredient.Preparation != "" {
+		suffix = ", " + ingredient.Preparation
+	}
+	if ingredient.Optional {
+		suffix += " (optional)"
+	}
+	return suffix
+}
+
+// FormatRecipeCSVRow renders a recipe as one row of the CSV export, matching RecipeCSVHeader.
+// Ingredients are joined into a single "quantity unit name[, preparation][ (optional)]; ..." column.
+func FormatRecipeCSVRow(recipe *models.Recipe) []string {
+	ingredients := make([]string, 0, len(recipe.Ingredients))
+	for _, ingredient := range recipe.Ingredients {
+		ingredients = append(ingredients, fmt.Sprintf("%s %s %s%s",
+			strconv.FormatFloat(ingredient.Quantity, 'f', -1, 64), ingredient.Unit, ingredient.Name, preparationSuffix(ingredient)))
+	}
+
+	return []string{
+		strconv.Itoa(recipe.ID),
+		csvText(recipe.Title),
+		csvText(recipe.Description),
+		strconv.Itoa(recipe.PrepTime),
+		strconv.Itoa(recipe.CookTime),
+		strconv.Itoa(recipe.Servings),
+		csvText(recipe.ServingUnit),
+		recipe.CreatedAt.UTC().Format(time.RFC3339),
+		csvText(strings.Join(ingredients, "; ")),
+		strconv.FormatFloat(recipe.YieldQuantity, 'f', -1, 64),
+		csvText(recipe.YieldUnit),
+	}
+}
+
+// csvText keeps spreadsheets from evaluating user text as a formula
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
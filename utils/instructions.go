@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// stepMarker matches leading step numbering such as "1." or "2)" and list bullets,
+// but not a quantity such as "1.5 cups" or a negative number
+var stepMarker = regexp.MustCompile(`^(\d+\.(\s+|$)|\d+\)\s*|[-*•]\s+)`)
+
+// ParseInstructionSteps splits instructions into clean steps. A step starts at a line
+// numbered like "1." or "2)" or bulleted with "-", "*" or "•", and ends at a blank line.
+// Other lines continue the current step, except in text with no numbering, bullets or
+// blank lines at all, where every line is a step of its own.
+func ParseInstructionSteps(instructions string) []string {
+	text := strings.TrimSpace(strings.ReplaceAll(instructions, "\r\n", "\n"))
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+
+	structured := false
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+		if lines[i] == "" || stepMarker.MatchString(lines[i]) {
+			structured = true
+		}
+	}
+
+	var steps []string
+	var current []string
+	flush := func() {
+		if step := strings.Join(current, " "); step != "" {
+			steps = append(steps, step)
+		}
+		current = nil
+	}
+
+	for _, line := range lines {
+		switch {
+		case line == "":
+			flush()
+		case stepMarker.MatchString(line):
+			flush()
+			current = append(current, strings.TrimSpace(stepMarker.ReplaceAllString(line, "")))
+		default:
+			if !structured {
+				flush()
+			}
+			current = append(current, line)
+		}
+	}
+	flush()
+
+	return steps
+}
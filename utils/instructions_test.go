@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseInstructionSteps(t *testing.T) {
+	tests := []struct {
+		name         string
+		instructions string
+		want         []string
+	}{
+		{
+			"numbered with periods",
+			"1. Preheat the oven.\n2. Mix the flour\nand the sugar.\n3. Bake for 20 minutes.",
+			[]string{"Preheat the oven.", "Mix the flour and the sugar.", "Bake for 20 minutes."},
+		},
+		{
+			"numbered with parentheses",
+			"1) Boil water\n2) Add pasta\n10) Drain",
+			[]string{"Boil water", "Add pasta", "Drain"},
+		},
+		{
+			"dash bulleted",
+			"- Chop the onion\n- Fry until golden\n  - Season to taste",
+			[]string{"Chop the onion", "Fry until golden", "Season to taste"},
+		},
+		{
+			"other bullets",
+			"* Whisk the eggs\n• Pour into the pan",
+			[]string{"Whisk the eggs", "Pour into the pan"},
+		},
+		{
+			"paragraphs",
+			"Preheat the oven and grease a tin.\n\nMix everything together,\nthen pour it in.\n\n\nBake until golden.",
+			[]string{"Preheat the oven and grease a tin.", "Mix everything together, then pour it in.", "Bake until golden."},
+		},
+		{
+			"plain lines",
+			"Mix\nBake\r\nServe",
+			[]string{"Mix", "Bake", "Serve"},
+		},
+		{
+			"single step",
+			"  Just heat it up.  ",
+			[]string{"Just heat it up."},
+		},
+		{"empty", " \n\n ", nil},
+	}
+
+	for _, tt := range tests {
+		got := ParseInstructionSteps(tt.instructions)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: ParseInstructionSteps = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
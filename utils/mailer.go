@@ -0,0 +1,27 @@
+package utils
+
+import "log"
+
+// PasswordResetSender delivers password reset tokens to users
+type PasswordResetSender interface {
+	SendPasswordReset(email, username, token string) error
+}
+
+// EmailVerificationSender delivers email verification tokens to users
+type EmailVerificationSender interface {
+	SendEmailVerification(email, username, token string) error
+}
+
+// LogMailer writes tokens to the server log instead of sending email.
+// It is meant for development and for deployments without a mail server.
+type LogMailer struct{}
+
+func (LogMailer) SendPasswordReset(email, username, token string) error {
+	log.Printf("📧 Password reset for %s <%s>: token %s", username, email, token)
+	return nil
+}
+
+func (LogMailer) SendEmailVerification(email, username, token string) error {
+	log.Printf("📧 Email verification for %s <%s>: token %s", username, email, token)
+	return nil
+}
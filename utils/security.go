@@ -2,15 +2,19 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"html/template"
 	"log"
+	"math"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Input validation patterns
@@ -30,6 +34,9 @@ var (
 	// Ingredient name: 1-100 chars, letters, numbers, spaces, basic punctuation
 	IngredientNameRegex = regexp.MustCompile(`^[a-zA-Z0-9\s\-'.,()]{1,100}$`)
 
+	// Idempotency key: 1-128 chars, letters, numbers and . _ : - (enough for UUIDs and ULIDs)
+	IdempotencyKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9._:\-]{1,128}$`)
+
 	// SQL injection patterns (more comprehensive)
 	SQLInjectionPatterns = []*regexp.Regexp{
 		regexp.MustCompile(`(?i)(\bunion\s+(all\s+)?select)`),
@@ -201,15 +208,22 @@ func ValidateRecipeDescription(description string) ValidationResult {
 	return ValidationResult{true, "", "description"}
 }
 
-// ValidateRecipeInstructions validates recipe instructions
+// MaxRecipeInstructionsLength is the CHECK constraint on recipes.instructions, in characters
+// as SQLite's length() counts them
+const MaxRecipeInstructionsLength = 10000
+
+// ValidateRecipeInstructions validates recipe instructions. The length is that of the
+// instructions as given, surrounding whitespace included, so that whatever passes also
+// fits the database.
 func ValidateRecipeInstructions(instructions string) ValidationResult {
+	length := utf8.RuneCountInString(instructions)
 	instructions = strings.TrimSpace(instructions)
 
 	if len(instructions) == 0 {
 		return ValidationResult{false, "Recipe instructions are required", "instructions"}
 	}
 
-	if len(instructions) > 10000 {
+	if length > MaxRecipeInstructionsLength {
 		return ValidationResult{false, "Recipe instructions are too long (maximum 10,000 characters)", "instructions"}
 	}
 
@@ -303,8 +317,13 @@ func ContainsXSS(input string) bool {
 
 // SanitizeInput removes or escapes potentially dangerous characters
 func SanitizeInput(input string) string {
-	// Remove null bytes
-	input = strings.ReplaceAll(input, "\x00", "")
+	// Remove null bytes and other control characters, keeping line breaks and tabs
+	input = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return -1
+		}
+		return r
+	}, input)
 
 	// Trim whitespace
 	input = strings.TrimSpace(input)
@@ -369,16 +388,19 @@ func GetFileExtension(filename string) string {
 }
 
 // ValidateNumericInput validates numeric inputs with bounds
+// The result's Field is fieldName in snake case, e.g. "Prep time" becomes "prep_time".
 func ValidateNumericInput(value, min, max int, fieldName string) ValidationResult {
+	field := strings.ReplaceAll(strings.ToLower(fieldName), " ", "_")
+
 	if value < min {
-		return ValidationResult{false, fmt.Sprintf("%s must be at least %d", fieldName, min), strings.ToLower(fieldName)}
+		return ValidationResult{false, fmt.Sprintf("%s must be at least %d", fieldName, min), field}
 	}
 
 	if value > max {
-		return ValidationResult{false, fmt.Sprintf("%s must be no more than %d", fieldName, max), strings.ToLower(fieldName)}
+		return ValidationResult{false, fmt.Sprintf("%s must be no more than %d", fieldName, max), field}
 	}
 
-	return ValidationResult{true, "", strings.ToLower(fieldName)}
+	return ValidationResult{true, "", field}
 }
 
 // ValidateQuantity validates recipe ingredient quantities
@@ -394,6 +416,21 @@ func ValidateQuantity(quantity float64) ValidationResult {
 	return ValidationResult{true, "", "quantity"}
 }
 
+// ValidateNutritionValue validates a nutritional value such as calories or grams of protein
+func ValidateNutritionValue(value, max float64, fieldName string) ValidationResult {
+	field := strings.ToLower(fieldName)
+
+	if value < 0 {
+		return ValidationResult{false, fmt.Sprintf("%s cannot be negative", fieldName), field}
+	}
+
+	if value > max {
+		return ValidationResult{false, fmt.Sprintf("%s is too large (maximum %g)", fieldName, max), field}
+	}
+
+	return ValidationResult{true, "", field}
+}
+
 // ValidateUnit validates measurement units
 func ValidateUnit(unit string) ValidationResult {
 	unit = strings.TrimSpace(unit)
@@ -446,6 +483,208 @@ func ValidateServingUnit(unit string) ValidationResult {
 	return ValidationResult{false, "Invalid serving unit", "serving_unit"}
 }
 
+// MaxYieldQuantity matches the CHECK constraint on recipes.yield_quantity
+const MaxYieldQuantity = 10000
+
+// DefaultYieldUnit is the unit of a yield given without one
+const DefaultYieldUnit = "pieces"
+
+// ValidateYield validates what a recipe makes, such as 2 dozen or 24 cookies. A quantity
+// of 0 means the recipe states no yield. The unit is one of the serving units; an empty
+// unit stands for DefaultYieldUnit.
+func ValidateYield(quantity float64, unit string) ValidationResult {
+	if quantity == 0 {
+		return ValidationResult{true, "", "yield_quantity"}
+	}
+
+	if math.IsNaN(quantity) || quantity < 0 || quantity > MaxYieldQuantity {
+		return ValidationResult{false, fmt.Sprintf("Yield must be between 0 and %d", MaxYieldQuantity), "yield_quantity"}
+	}
+
+	if strings.TrimSpace(unit) == "" {
+		return ValidationResult{true, "", "yield_unit"}
+	}
+	if validation := ValidateServingUnit(unit); !validation.Valid {
+		return ValidationResult{false, "Invalid yield unit", "yield_unit"}
+	}
+
+	return ValidationResult{true, "", "yield_unit"}
+}
+
+// IngredientCategories is the allow-list of ingredient categories, in the order a
+// shopping list groups them
+var IngredientCategories = []string{
+	"produce", "meat", "seafood", "dairy", "bakery", "pantry", "spice", "frozen", "beverages", "other",
+}
+
+// DefaultIngredientCategory is used for ingredients created without a category
+const DefaultIngredientCategory = "other"
+
+// ValidateIngredientCategory validates an ingredient category; an empty category is
+// allowed and stands for DefaultIngredientCategory
+func ValidateIngredientCategory(category string) ValidationResult {
+	if category == "" {
+		return ValidationResult{true, "", "category"}
+	}
+
+	for _, allowed := range IngredientCategories {
+		if category == allowed {
+			return ValidationResult{true, "", "category"}
+		}
+	}
+
+	return ValidationResult{false, "Category must be one of: " + strings.Join(IngredientCategories, ", "), "category"}
+}
+
+// Cuisines is the allow-list of recipe cuisines
+var Cuisines = []string{
+	"American", "Chinese", "French", "Greek", "Indian", "Italian", "Japanese", "Korean",
+	"Mediterranean", "Mexican", "Middle Eastern", "Spanish", "Thai", "Vietnamese", "Other",
+}
+
+// CanonicalCuisine returns the allow-listed spelling of a cuisine, matched case-insensitively
+func CanonicalCuisine(cuisine string) (string, bool) {
+	cuisine = strings.TrimSpace(cuisine)
+	for _, allowed := range Cuisines {
+		if strings.EqualFold(cuisine, allowed) {
+			return allowed, true
+		}
+	}
+	return "", false
+}
+
+// ValidateCuisine validates a recipe cuisine; an empty cuisine is allowed
+func ValidateCuisine(cuisine string) ValidationResult {
+	if strings.TrimSpace(cuisine) == "" {
+		return ValidationResult{true, "", "cuisine"}
+	}
+
+	if _, ok := CanonicalCuisine(cuisine); !ok {
+		return ValidationResult{false, "Invalid cuisine", "cuisine"}
+	}
+
+	return ValidationResult{true, "", "cuisine"}
+}
+
+// MaxSourceURLLength limits the source URL of a recipe
+const MaxSourceURLLength = 2048
+
+// ValidateURL validates a recipe's source URL; an empty URL is allowed.
+// Only absolute http and https URLs are accepted, so javascript: and data: links are rejected.
+func ValidateURL(rawURL string) ValidationResult {
+	if rawURL == "" {
+		return ValidationResult{true, "", "source_url"}
+	}
+
+	if len(rawURL) > MaxSourceURLLength {
+		return ValidationResult{false, fmt.Sprintf("Source URL must be no more than %d characters long", MaxSourceURLLength), "source_url"}
+	}
+
+	if strings.IndexFunc(rawURL, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return ValidationResult{false, "Source URL must not contain spaces", "source_url"}
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ValidationResult{false, "Source URL must be a valid http or https URL", "source_url"}
+	}
+
+	return ValidationResult{true, "", "source_url"}
+}
+
+var (
+	youTubeVideoID = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	vimeoVideoID   = regexp.MustCompile(`^[0-9]{1,12}$`)
+)
+
+// NormalizeVideoURL returns the canonical link to a YouTube or Vimeo video, e.g.
+// https://youtu.be/ID and https://www.youtube.com/embed/ID both become
+// https://www.youtube.com/watch?v=ID. Links to any other host are rejected.
+func NormalizeVideoURL(rawURL string) (string, bool) {
+	rawURL = strings.TrimSpace(rawURL)
+	if len(rawURL) > MaxSourceURLLength {
+		return "", false
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.User != nil || parsed.Port() != "" {
+		return "", false
+	}
+
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+
+	switch strings.ToLower(parsed.Hostname()) {
+	case "youtube.com", "www.youtube.com", "m.youtube.com", "youtube-nocookie.com", "www.youtube-nocookie.com":
+		var id string
+		switch {
+		case len(segments) == 1 && segments[0] == "watch":
+			id = parsed.Query().Get("v")
+		case len(segments) == 2 && (segments[0] == "embed" || segments[0] == "shorts" || segments[0] == "live"):
+			id = segments[1]
+		}
+		if youTubeVideoID.MatchString(id) {
+			return "https://www.youtube.com/watch?v=" + id, true
+		}
+	case "youtu.be":
+		if len(segments) == 1 && youTubeVideoID.MatchString(segments[0]) {
+			return "https://www.youtube.com/watch?v=" + segments[0], true
+		}
+	case "vimeo.com", "www.vimeo.com":
+		if len(segments) == 1 && vimeoVideoID.MatchString(segments[0]) {
+			return "https://vimeo.com/" + segments[0], true
+		}
+	case "player.vimeo.com":
+		if len(segments) == 2 && segments[0] == "video" && vimeoVideoID.MatchString(segments[1]) {
+			return "https://vimeo.com/" + segments[1], true
+		}
+	}
+
+	return "", false
+}
+
+// ValidateVideoURL validates a recipe's video link, which must point to a YouTube or
+// Vimeo video so the frontend never embeds a player from an untrusted host.
+// An empty URL is allowed.
+func ValidateVideoURL(rawURL string) ValidationResult {
+	if strings.TrimSpace(rawURL) == "" {
+		return ValidationResult{true, "", "video_url"}
+	}
+
+	if _, ok := NormalizeVideoURL(rawURL); !ok {
+		return ValidationResult{false, "Video URL must link to a YouTube or Vimeo video", "video_url"}
+	}
+
+	return ValidationResult{true, "", "video_url"}
+}
+
+// MealTypes lists the meals a recipe can be planned for, in the order they are eaten
+var MealTypes = []string{"breakfast", "lunch", "dinner", "snack"}
+
+// ValidateMealType validates a meal plan's meal type
+func ValidateMealType(mealType string) ValidationResult {
+	for _, allowed := range MealTypes {
+		if mealType == allowed {
+			return ValidationResult{true, "", "meal_type"}
+		}
+	}
+
+	return ValidationResult{false, "Meal type must be one of: " + strings.Join(MealTypes, ", "), "meal_type"}
+}
+
+// ValidateIdempotencyKey validates the Idempotency-Key header a client sends to make
+// retrying a request safe
+func ValidateIdempotencyKey(key string) ValidationResult {
+	if len(key) > 128 {
+		return ValidationResult{false, "Idempotency key is too long (maximum 128 characters)", "Idempotency-Key"}
+	}
+
+	if !IdempotencyKeyRegex.MatchString(key) {
+		return ValidationResult{false, "Idempotency key can only contain letters, numbers, periods, underscores, colons, and hyphens", "Idempotency-Key"}
+	}
+
+	return ValidationResult{true, "", "Idempotency-Key"}
+}
+
 // SecurityContext holds security-related information for requests
 type SecurityContext struct {
 	UserID    int
@@ -455,9 +694,67 @@ type SecurityContext struct {
 	Timestamp time.Time
 }
 
-// LogSecurityEvent logs security-related events
-func LogSecurityEvent(event, ip, details string) {
-	log.Printf("🔒 SECURITY: %s from IP %s - %s", event, ip, details)
+// auditedEvents are the security events persisted by the audit sink, in addition to
+// every UNAUTHORIZED_* event
+var auditedEvents = map[string]bool{
+	"LOGIN_SUCCESS": true, "LOGIN_WRONG_PASSWORD": true, "LOGIN_USER_NOT_FOUND": true, "LOGIN_ACCOUNT_LOCKED": true,
+	"USER_REGISTERED": true, "ACCOUNT_DELETED": true, "LOGOUT_ALL": true, "ADMIN_BACKUP": true, "ADMIN_IMAGE_CLEANUP": true,
+	"PASSWORD_RESET_REQUESTED": true, "PASSWORD_RESET_COMPLETED": true, "EMAIL_VERIFIED": true,
+	"RECIPE_CREATED": true, "RECIPE_IMPORTED": true, "RECIPE_CLONED": true, "RECIPE_UPDATED_API": true,
+	"RECIPE_DELETED": true, "RECIPE_RESTORED": true, "RECIPE_PUBLISHED": true, "RECIPE_VISIBILITY_CHANGED": true,
+	"IMAGE_DELETED": true, "INGREDIENT_CREATED": true, "INGREDIENT_MERGED": true, "INGREDIENT_DELETED": true,
+	"TAG_CREATED": true, "TAG_UPDATED": true, "TAG_MERGED": true, "TAG_DELETED": true, "COMMENT_DELETED": true,
+}
+
+// AuditSink persists an audited security event. userID is 0 when no user is known.
+type AuditSink func(event string, userID int, ip, details string) error
+
+var auditSink AuditSink
+
+// SetAuditSink enables persisting important security events; nil disables it.
+// It must be called before the server starts handling requests.
+func SetAuditSink(sink AuditSink) {
+	auditSink = sink
+}
+
+// AuditEnabled reports whether important security events are persisted
+func AuditEnabled() bool {
+	return auditSink != nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// LogSecurityEvent logs security-related events, tagged with the request ID from ctx
+func LogSecurityEvent(ctx context.Context, event, ip, details string) {
+	LogUserSecurityEvent(ctx, event, ip, 0, details)
+}
+
+// LogUserSecurityEvent logs a security event caused by a known user. Important events
+// are also passed to the audit sink when one is set.
+func LogUserSecurityEvent(ctx context.Context, event, ip string, userID int, details string) {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		log.Printf("🔒 SECURITY: %s from IP %s - %s [request %s]", event, ip, details, requestID)
+	} else {
+		log.Printf("🔒 SECURITY: %s from IP %s - %s", event, ip, details)
+	}
+
+	if auditSink == nil || !(auditedEvents[event] || strings.HasPrefix(event, "UNAUTHORIZED_")) {
+		return
+	}
+	if err := auditSink(event, userID, ip, details); err != nil {
+		log.Printf("⚠️  Failed to write audit log for %s: %v", event, err)
+	}
 }
 
 // IsValidID validates that an ID is a positive integer
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeVideoURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"http://youtube.com/watch?v=dQw4w9WgXcQ&t=42s", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ?si=abc", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"  https://WWW.YouTube.com/watch?v=dQw4w9WgXcQ  ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://vimeo.com/76979871", "https://vimeo.com/76979871"},
+		{"https://player.vimeo.com/video/76979871", "https://vimeo.com/76979871"},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeVideoURL(tt.url)
+		if !ok || got != tt.want {
+			t.Errorf("NormalizeVideoURL(%q) = %q, %v, want %q", tt.url, got, ok, tt.want)
+		}
+	}
+}
+
+func TestNormalizeVideoURLRejects(t *testing.T) {
+	rejected := []string{
+		"https://evil.example.com/watch?v=dQw4w9WgXcQ",
+		"https://youtube.com.evil.example/watch?v=dQw4w9WgXcQ",
+		"https://notyoutube.com/watch?v=dQw4w9WgXcQ",
+		"https://dailymotion.com/video/x7tgad0",
+		"javascript:alert(1)",
+		"ftp://youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://user@youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://youtube.com:8443/watch?v=dQw4w9WgXcQ",
+		"https://www.youtube.com/channel/UC38IQsAvIsxxjztdMZQtwHA",
+		"https://www.youtube.com/watch?v=short",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ\"><script>",
+		"https://youtu.be/",
+		"https://vimeo.com/channels/staffpicks",
+		"//youtube.com/watch?v=dQw4w9WgXcQ",
+	}
+
+	for _, url := range rejected {
+		if got, ok := NormalizeVideoURL(url); ok {
+			t.Errorf("NormalizeVideoURL(%q) = %q, want it rejected", url, got)
+		}
+		if ValidateVideoURL(url).Valid {
+			t.Errorf("ValidateVideoURL(%q) accepted it", url)
+		}
+	}
+
+	if !ValidateVideoURL("  ").Valid {
+		t.Error("ValidateVideoURL rejected an empty URL, which means no video")
+	}
+}
+
+// validationCase is one input to a Validate* function and whether it must pass
+type validationCase struct {
+	input string
+	valid bool
+}
+
+// checkValidation runs validate over cases, checking the verdict and the reported field
+func checkValidation(t *testing.T, name string, validate func(string) ValidationResult, field string, cases []validationCase) {
+	t.Helper()
+
+	for _, tc := range cases {
+		result := validate(tc.input)
+		if result.Valid != tc.valid {
+			t.Errorf("%s(%q) valid = %v (%q), want %v", name, tc.input, result.Valid, result.Message, tc.valid)
+		}
+		if result.Field != field {
+			t.Errorf("%s(%q) reported field %q, want %q", name, tc.input, result.Field, field)
+		}
+		if !result.Valid && result.Message == "" {
+			t.Errorf("%s(%q) failed without a message", name, tc.input)
+		}
+	}
+}
+
+// These pin the validation rules, which the frontend forms mirror
+
+func TestValidateUsername(t *testing.T) {
+	checkValidation(t, "ValidateUsername", ValidateUsername, "username", []validationCase{
+		{"", false},
+		{"ab", false},
+		{"abc", true},
+		{strings.Repeat("a", 30), true},
+		{strings.Repeat("a", 31), false},
+		{"  chef_42  ", true},
+		{"chef 42", false},
+		{"chef-42", false},
+		{"chef@home", false},
+	})
+}
+
+func TestValidatePassword(t *testing.T) {
+	checkValidation(t, "ValidatePassword", ValidatePassword, "password", []validationCase{
+		{"", false},
+		{"abc12", false},
+		{"abc123", true},
+		{strings.Repeat("a", 127) + "1", true},
+		{strings.Repeat("a", 128) + "1", false},
+		{"abcdefgh", false},
+		{"12345678", false},
+	})
+}
+
+func TestValidateEmail(t *testing.T) {
+	checkValidation(t, "ValidateEmail", ValidateEmail, "email", []validationCase{
+		{"", false},
+		{"cook@example.com", true},
+		{"  cook@example.com  ", true},
+		{"cook@example", false},
+		{"cook.example.com", false},
+		{strings.Repeat("a", 243) + "@example.com", false},
+	})
+}
+
+func TestValidateRecipeTitle(t *testing.T) {
+	checkValidation(t, "ValidateRecipeTitle", ValidateRecipeTitle, "title", []validationCase{
+		{"", false},
+		{"   ", false},
+		{"A", true},
+		{"Pancakes", true},
+		{strings.Repeat("a", 200), true},
+		{strings.Repeat("a", 201), false},
+		{"<script>alert(1)</script>", false},
+	})
+}
+
+func TestValidateRecipeDescription(t *testing.T) {
+	checkValidation(t, "ValidateRecipeDescription", ValidateRecipeDescription, "description", []validationCase{
+		{"", true},
+		{strings.Repeat("a", 1000), true},
+		{strings.Repeat("a", 1001), false},
+	})
+}
+
+func TestValidateRecipeInstructions(t *testing.T) {
+	checkValidation(t, "ValidateRecipeInstructions", ValidateRecipeInstructions, "instructions", []validationCase{
+		{"", false},
+		{" \n\t ", false},
+		{"Mix and bake.", true},
+		{strings.Repeat("a", MaxRecipeInstructionsLength), true},
+		{strings.Repeat("a", MaxRecipeInstructionsLength+1), false},
+		// The limit is in characters, as in the database, not bytes
+		{strings.Repeat("é", MaxRecipeInstructionsLength), true},
+		{strings.Repeat("é", MaxRecipeInstructionsLength+1), false},
+		// Surrounding whitespace is stored, so it counts
+		{" " + strings.Repeat("a", MaxRecipeInstructionsLength-1), true},
+		{" " + strings.Repeat("a", MaxRecipeInstructionsLength-1) + "\n", false},
+	})
+}
+
+func TestValidateTagName(t *testing.T) {
+	checkValidation(t, "ValidateTagName", ValidateTagName, "name", []validationCase{
+		{"", false},
+		{"Quick and Easy", true},
+		{"Sweet & Sour", false},
+		{"Gluten-Free", true},
+		{strings.Repeat("a", 50), true},
+		{strings.Repeat("a", 51), false},
+	})
+}
+
+func TestValidateIngredientName(t *testing.T) {
+	checkValidation(t, "ValidateIngredientName", ValidateIngredientName, "name", []validationCase{
+		{"", false},
+		{"Olive oil", true},
+		{strings.Repeat("a", 100), true},
+		{strings.Repeat("a", 101), false},
+	})
+}
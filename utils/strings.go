@@ -0,0 +1,53 @@
+package utils
+
+import "strings"
+
+// accentReplacements maps common accented Latin letters to ASCII
+var accentReplacements = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i",
+	'ł': "l", 'ľ': "l", 'ĺ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ř': "r", 'ś': "s", 'š': "s", 'ß': "ss", 'ť': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// maxSlugLength keeps generated slugs short enough for filenames and URLs
+const maxSlugLength = 80
+
+// SlugifyTitle converts a title into a lowercase, hyphen-separated ASCII slug
+// suitable for filenames and URLs, e.g. "Crème Brûlée!" becomes "creme-brulee"
+func SlugifyTitle(title string) string {
+	var b strings.Builder
+	lastHyphen := true // avoid a leading hyphen
+
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			lastHyphen = false
+		case accentReplacements[r] != "":
+			b.WriteString(accentReplacements[r])
+			lastHyphen = false
+		case r == '\'' || r == '’':
+			// Drop apostrophes so "Grandma's" becomes "grandmas"
+		default:
+			if !lastHyphen {
+				b.WriteByte('-')
+				lastHyphen = true
+			}
+		}
+	}
+
+	slug := strings.Trim(b.String(), "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	if slug == "" {
+		return "recipe"
+	}
+	return slug
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugifyTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Chocolate Chip Cookies", "chocolate-chip-cookies"},
+		{"  Spaghetti   Carbonara  ", "spaghetti-carbonara"},
+		{"Crème Brûlée!", "creme-brulee"},
+		{"Jalapeño Poppers", "jalapeno-poppers"},
+		{"Grandma's Apple Pie", "grandmas-apple-pie"},
+		{"Mac & Cheese (Baked)", "mac-cheese-baked"},
+		{"Quick-and-Easy: 15 Minute Pasta?", "quick-and-easy-15-minute-pasta"},
+		{"!!!", "recipe"},
+		{"", "recipe"},
+	}
+
+	for _, tt := range tests {
+		if got := SlugifyTitle(tt.title); got != tt.want {
+			t.Errorf("SlugifyTitle(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSlugifyTitleLength(t *testing.T) {
+	slug := SlugifyTitle(strings.Repeat("word ", 40))
+
+	if len(slug) > maxSlugLength {
+		t.Errorf("slug is %d characters, want at most %d", len(slug), maxSlugLength)
+	}
+	if strings.HasSuffix(slug, "-") {
+		t.Errorf("slug %q ends with a hyphen", slug)
+	}
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"recipe-book/models"
+)
+
+// Ingredient words the tag heuristics look for. Words also match their plural.
+var (
+	meatWords = wordSet("anchovy", "anchovies", "bacon", "beef", "chicken", "chorizo", "clam", "cod", "crab",
+		"duck", "fish", "gelatin", "ham", "lamb", "lobster", "mince", "mussel", "oyster", "pancetta",
+		"pepperoni", "pork", "prawn", "prosciutto", "salami", "salmon", "sausage", "scallop", "shrimp",
+		"squid", "steak", "tuna", "turkey", "veal", "venison")
+	dairyWords = wordSet("butter", "buttermilk", "cheddar", "cheese", "cream", "feta", "ghee", "mascarpone",
+		"milk", "mozzarella", "parmesan", "ricotta", "yogurt", "yoghurt")
+	// plantWords make an ingredient such as "almond milk" or "peanut butter" plant-based
+	plantWords = wordSet("almond", "cashew", "coconut", "oat", "peanut", "soy", "vegan")
+	eggWords   = wordSet("egg")
+	honeyWords = wordSet("honey")
+	spicyWords = wordSet("cayenne", "chile", "chili", "chilli", "chipotle", "gochujang", "habanero",
+		"harissa", "jalapeno", "jalapeño", "sambal", "sriracha")
+	flourWords     = wordSet("flour")
+	sugarWords     = wordSet("sugar")
+	chocolateWords = wordSet("chocolate", "cocoa")
+	brothWords     = wordSet("broth", "stock")
+)
+
+// quickRecipeMinutes is the longest total time a "Quick & Easy" recipe takes
+const quickRecipeMinutes = 30
+
+// tagRules suggest a tag, by name, when their test holds for a recipe
+var tagRules = []struct {
+	tag  string
+	test func(r *recipeTraits) bool
+}{
+	{"Vegetarian", func(r *recipeTraits) bool { return r.hasIngredients && !r.meat }},
+	{"Vegan", func(r *recipeTraits) bool { return r.hasIngredients && !r.meat && !r.dairy && !r.egg && !r.honey }},
+	{"Dairy-Free", func(r *recipeTraits) bool { return r.hasIngredients && !r.dairy }},
+	{"Dessert", func(r *recipeTraits) bool { return r.flour && r.sugar && r.egg || r.chocolate && r.sugar }},
+	{"Quick & Easy", func(r *recipeTraits) bool { return r.totalTime > 0 && r.totalTime <= quickRecipeMinutes }},
+	{"Spicy", func(r *recipeTraits) bool { return r.spicy }},
+	{"Soup", func(r *recipeTraits) bool { return r.soupTitle || r.broth && r.simmered }},
+}
+
+// recipeTraits are what the tag rules know about a recipe
+type recipeTraits struct {
+	hasIngredients                 bool
+	meat, dairy, egg, honey, spicy bool
+	flour, sugar, chocolate, broth bool
+	soupTitle, simmered            bool
+	totalTime                      int
+}
+
+// SuggestTags proposes tags for a recipe from its ingredients, instructions and total
+// time, e.g. "Vegetarian" when no ingredient is meat or fish. Only tags in allTags that
+// the recipe doesn't have yet are suggested, in the order of allTags.
+func SuggestTags(recipe models.Recipe, allTags []models.Tag) []models.Tag {
+	traits := recipeTraits{
+		hasIngredients: len(recipe.Ingredients) > 0,
+		totalTime:      recipe.PrepTime + recipe.CookTime,
+		soupTitle:      hasAnyWord(recipe.Title, wordSet("soup", "chowder", "bisque", "stew")),
+		simmered:       hasAnyWord(recipe.Instructions, wordSet("simmer", "simmered", "simmering")),
+	}
+	for _, ingredient := range recipe.Ingredients {
+		name := ingredient.Name
+		plantBased := hasAnyWord(name, plantWords)
+
+		traits.meat = traits.meat || hasAnyWord(name, meatWords)
+		traits.dairy = traits.dairy || !plantBased && hasAnyWord(name, dairyWords)
+		traits.egg = traits.egg || hasAnyWord(name, eggWords)
+		traits.honey = traits.honey || hasAnyWord(name, honeyWords)
+		traits.spicy = traits.spicy || hasAnyWord(name, spicyWords)
+		traits.flour = traits.flour || hasAnyWord(name, flourWords)
+		traits.sugar = traits.sugar || hasAnyWord(name, sugarWords)
+		traits.chocolate = traits.chocolate || hasAnyWord(name, chocolateWords)
+		traits.broth = traits.broth || hasAnyWord(name, brothWords)
+	}
+
+	suggested := make(map[string]bool)
+	for _, rule := range tagRules {
+		if rule.test(&traits) {
+			suggested[strings.ToLower(rule.tag)] = true
+		}
+	}
+	for _, tag := range recipe.Tags {
+		delete(suggested, strings.ToLower(tag.Name))
+	}
+
+	tags := []models.Tag{}
+	for _, tag := range allTags {
+		if suggested[strings.ToLower(tag.Name)] {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// hasAnyWord reports whether text contains one of the words, or its plural, as a whole
+// word, so that "eggplant" is not an egg
+func hasAnyWord(text string, words map[string]bool) bool {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, field := range fields {
+		if words[field] || words[strings.TrimSuffix(field, "s")] || words[strings.TrimSuffix(field, "es")] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+
+	"recipe-book/models"
+)
+
+// suggestibleTags are the tags SuggestTags picks from, in their listing order
+var suggestibleTags = []models.Tag{
+	{ID: 1, Name: "Dairy-Free"}, {ID: 2, Name: "Dessert"}, {ID: 3, Name: "Quick & Easy"},
+	{ID: 4, Name: "Soup"}, {ID: 5, Name: "Spicy"}, {ID: 6, Name: "Vegan"}, {ID: 7, Name: "Vegetarian"},
+}
+
+// withIngredients is a recipe with ingredients of the given names
+func withIngredients(names ...string) models.Recipe {
+	var recipe models.Recipe
+	for _, name := range names {
+		recipe.Ingredients = append(recipe.Ingredients, models.RecipeIngredient{Name: name})
+	}
+	return recipe
+}
+
+func TestSuggestTags(t *testing.T) {
+	soup := withIngredients("Carrots", "Vegetable stock", "Onion")
+	soup.Instructions = "Chop everything, then simmer for 40 minutes."
+	soup.PrepTime, soup.CookTime = 10, 40
+
+	tagged := withIngredients("Eggplant", "Olive oil", "Salt")
+	tagged.Tags = []models.Tag{{ID: 9, Name: "vegan"}}
+
+	quick := withIngredients("Chicken breast", "Butter", "Jalapeños")
+	quick.PrepTime, quick.CookTime = 5, 20
+
+	chowder := withIngredients("Clams", "Potatoes", "Cream")
+	chowder.Title = "Clam Chowder"
+
+	tests := []struct {
+		name   string
+		recipe models.Recipe
+		want   []string
+	}{
+		{"no ingredients", models.Recipe{}, nil},
+		{"meat and dairy", withIngredients("Beef mince", "Cheddar cheese"), nil},
+		{"vegan", withIngredients("Tofu", "Rice", "Spring onions"), []string{"Dairy-Free", "Vegan", "Vegetarian"}},
+		{"plant milk", withIngredients("Oat milk", "Peanut butter", "Bananas"), []string{"Dairy-Free", "Vegan", "Vegetarian"}},
+		{"eggs and honey", withIngredients("Eggs", "Honey", "Oats"), []string{"Dairy-Free", "Vegetarian"}},
+		{"cake", withIngredients("Flour", "Caster sugar", "Eggs", "Butter"), []string{"Dessert", "Vegetarian"}},
+		{"brownies", withIngredients("Dark chocolate", "Brown sugar", "Butter"), []string{"Dessert", "Vegetarian"}},
+		{"simmered in stock", soup, []string{"Dairy-Free", "Soup", "Vegan", "Vegetarian"}},
+		{"soup title", chowder, []string{"Soup"}},
+		{"quick and spicy", quick, []string{"Quick & Easy", "Spicy"}},
+		{"existing tags are not suggested", tagged, []string{"Dairy-Free", "Vegetarian"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, tag := range SuggestTags(tt.recipe, suggestibleTags) {
+			got = append(got, tag.Name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: SuggestTags = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestTagsOnlyExisting(t *testing.T) {
+	tags := SuggestTags(withIngredients("Lentils", "Tomatoes"), []models.Tag{{ID: 3, Name: "vegetarian"}, {ID: 4, Name: "Baking"}})
+
+	if len(tags) != 1 || tags[0].ID != 3 {
+		t.Errorf("SuggestTags = %+v, want only the existing vegetarian tag", tags)
+	}
+}
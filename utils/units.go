@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unitCategory groups units that can be converted into each other
+type unitCategory string
+
+const (
+	unitCategoryVolume unitCategory = "volume"
+	unitCategoryWeight unitCategory = "weight"
+)
+
+// unitDefinition describes a unit by its category and its size in the
+// category's base unit (millilitres for volume, grams for weight)
+type unitDefinition struct {
+	category unitCategory
+	toBase   float64
+}
+
+var convertibleUnits = map[string]unitDefinition{
+	// Volume (base: ml)
+	"tsp":   {unitCategoryVolume, 4.92892159375},
+	"tbsp":  {unitCategoryVolume, 14.78676478125},
+	"cup":   {unitCategoryVolume, 236.5882365},
+	"ml":    {unitCategoryVolume, 1},
+	"l":     {unitCategoryVolume, 1000},
+	"fl oz": {unitCategoryVolume, 29.5735295625},
+
+	// Weight (base: g)
+	"g":  {unitCategoryWeight, 1},
+	"kg": {unitCategoryWeight, 1000},
+	"oz": {unitCategoryWeight, 28.349523125},
+	"lb": {unitCategoryWeight, 453.59237},
+}
+
+// ConvertUnit converts a quantity between two units of the same category
+// (volume or weight). Converting across categories, e.g. grams to cups, is an error.
+func ConvertUnit(quantity float64, from, to string) (float64, error) {
+	if quantity < 0 {
+		return 0, fmt.Errorf("quantity must not be negative")
+	}
+
+	fromUnit, ok := convertibleUnits[strings.ToLower(strings.TrimSpace(from))]
+	if !ok {
+		return 0, fmt.Errorf("unknown or non-convertible unit %q", from)
+	}
+
+	toUnit, ok := convertibleUnits[strings.ToLower(strings.TrimSpace(to))]
+	if !ok {
+		return 0, fmt.Errorf("unknown or non-convertible unit %q", to)
+	}
+
+	if fromUnit.category != toUnit.category {
+		return 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from, fromUnit.category, to, toUnit.category)
+	}
+
+	return quantity * fromUnit.toBase / toUnit.toBase, nil
+}
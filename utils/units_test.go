@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		quantity float64
+		from, to string
+		want     float64
+	}{
+		{3, "tsp", "tbsp", 1},
+		{1, "cup", "tbsp", 16},
+		{2, "cup", "fl oz", 16},
+		{1, "l", "ml", 1000},
+		{250, "ml", "cup", 1.0566882},
+		{1, "kg", "g", 1000},
+		{1, "lb", "oz", 16},
+		{500, "g", "lb", 1.1023113},
+		{0, "cup", "ml", 0},
+		{2, "g", "g", 2},
+		{1, " TBSP ", "Tsp", 3},
+	}
+
+	for _, tt := range tests {
+		got, err := ConvertUnit(tt.quantity, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("ConvertUnit(%v, %q, %q) returned error: %v", tt.quantity, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("ConvertUnit(%v, %q, %q) = %v, want %v", tt.quantity, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConvertUnitErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity float64
+		from, to string
+	}{
+		{"across categories", 100, "g", "cup"},
+		{"unknown source unit", 1, "pinch", "g"},
+		{"unknown target unit", 1, "cup", "bucket"},
+		{"empty unit", 1, "", "g"},
+		{"negative quantity", -1, "cup", "ml"},
+	}
+
+	for _, tt := range tests {
+		if got, err := ConvertUnit(tt.quantity, tt.from, tt.to); err == nil {
+			t.Errorf("%s: ConvertUnit(%v, %q, %q) = %v, want an error", tt.name, tt.quantity, tt.from, tt.to, got)
+		}
+	}
+}
@@ -8,10 +8,14 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"math"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
+	"recipe-book/templates"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -48,16 +52,22 @@ func LoadTemplates() {
 			s := reflect.ValueOf(slice)
 			return s.Len()
 		},
+		"quantity": FormatQuantity,
+		"steps":    ParseInstructionSteps,
+		"asset":    StaticAssetURL,
 	}
 
 	var err error
-	Templates, err = template.New("").Funcs(funcMap).ParseGlob("templates/*.html")
+	Templates, err = template.New("").Funcs(funcMap).ParseFS(templates.FS, "*.html")
 	if err != nil {
 		log.Fatal("Failed to parse templates:", err)
 		return
 	}
 
 	for _, tmpl := range Templates.Templates() {
+		if tmpl.Name() == "" {
+			continue // the unnamed root the templates are parsed into
+		}
 		fmt.Printf("📄 Loaded template: %s\n", tmpl.Name())
 	}
 }
@@ -80,6 +90,33 @@ func IsValidImageFile(filename string) bool {
 	return false
 }
 
+// imageExtensions maps the image MIME types accepted for upload to their file extensions
+var imageExtensions = map[string][]string{
+	"image/jpeg": {".jpg", ".jpeg"},
+	"image/png":  {".png"},
+	"image/gif":  {".gif"},
+	"image/webp": {".webp"},
+}
+
+// DetectImageType sniffs the MIME type from the first 512 bytes of r and returns
+// an error unless it is one of the accepted image types
+func DetectImageType(r io.Reader) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return "", fmt.Errorf("empty file")
+		}
+		return "", err
+	}
+
+	contentType := http.DetectContentType(buf[:n])
+	if _, ok := imageExtensions[contentType]; !ok {
+		return "", fmt.Errorf("unsupported content type %s", contentType)
+	}
+	return contentType, nil
+}
+
 func SaveUploadedFile(file multipart.File, header *multipart.FileHeader) (string, error) {
 	if !IsValidImageFile(header.Filename) {
 		return "", fmt.Errorf("invalid file type")
@@ -89,6 +126,35 @@ func SaveUploadedFile(file multipart.File, header *multipart.FileHeader) (string
 		return "", fmt.Errorf("file too large")
 	}
 
+	// The extension alone can be faked, so the content must match it too
+	contentType, err := DetectImageType(file)
+	if err != nil {
+		return "", err
+	}
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	matches := false
+	for _, allowed := range imageExtensions[contentType] {
+		if ext == allowed {
+			matches = true
+			break
+		}
+	}
+	if !matches {
+		return "", fmt.Errorf("file content (%s) does not match extension %s", contentType, ext)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	if convertWebP {
+		if filename, ok := saveAsWebP(file, contentType, header.Size); ok {
+			return filename, nil
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+
 	filename := GenerateUniqueFilename(header.Filename)
 	filepath := filepath.Join("uploads", filename)
 
@@ -105,3 +171,52 @@ func SaveUploadedFile(file multipart.File, header *multipart.FileHeader) (string
 
 	return filename, nil
 }
+
+// ScaleQuantity multiplies an ingredient quantity by a scale factor,
+// rounding to two decimal places so scaled amounts stay readable
+func ScaleQuantity(quantity, factor float64) float64 {
+	return math.Round(quantity*factor*100) / 100
+}
+
+// displayFractions are the fractions FormatQuantity writes instead of decimals
+var displayFractions = []struct {
+	value float64
+	text  string
+}{
+	{1.0 / 4, "1/4"},
+	{1.0 / 3, "1/3"},
+	{1.0 / 2, "1/2"},
+	{2.0 / 3, "2/3"},
+	{3.0 / 4, "3/4"},
+}
+
+// fractionTolerance is how close a quantity must be to a fraction to be written as one.
+// It allows for quantities rounded to two decimals by ScaleQuantity, such as 0.33.
+const fractionTolerance = 0.01
+
+// FormatQuantity renders an ingredient quantity for reading: common fractions and mixed
+// numbers where the quantity is close to one (0.333 as "1/3", 1.5 as "1 1/2"), and a
+// decimal rounded to two places without trailing zeros otherwise (2.0 as "2")
+func FormatQuantity(quantity float64) string {
+	if quantity <= 0 || math.IsInf(quantity, 0) || math.IsNaN(quantity) {
+		return strconv.FormatFloat(quantity, 'f', -1, 64)
+	}
+
+	whole := math.Floor(quantity)
+	part := quantity - whole
+
+	if part <= fractionTolerance || part >= 1-fractionTolerance {
+		return strconv.FormatFloat(math.Round(quantity), 'f', -1, 64)
+	}
+
+	for _, fraction := range displayFractions {
+		if math.Abs(part-fraction.value) <= fractionTolerance {
+			if whole == 0 {
+				return fraction.text
+			}
+			return strconv.FormatFloat(whole, 'f', -1, 64) + " " + fraction.text
+		}
+	}
+
+	return strconv.FormatFloat(math.Round(quantity*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"bytes"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakePNG is a PNG signature followed by an IHDR chunk header, enough for sniffing
+var fakePNG = append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), bytes.Repeat([]byte{0}, 64)...)
+
+// uploadedFile is an in-memory multipart.File
+type uploadedFile struct {
+	*bytes.Reader
+}
+
+func (uploadedFile) Close() error { return nil }
+
+func newUpload(filename string, content []byte) (multipart.File, *multipart.FileHeader) {
+	return uploadedFile{bytes.NewReader(content)}, &multipart.FileHeader{Filename: filename, Size: int64(len(content))}
+}
+
+func TestDetectImageType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+		wantErr bool
+	}{
+		{"png header", fakePNG, "image/png", false},
+		{"jpeg header", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "image/jpeg", false},
+		{"gif header", []byte("GIF89a\x01\x00\x01\x00"), "image/gif", false},
+		{"text file", []byte("just some text, not an image\n"), "", true},
+		{"html file", []byte("<html><body>hi</body></html>"), "", true},
+		{"empty file", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := DetectImageType(bytes.NewReader(tt.content))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: DetectImageType = %q, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: DetectImageType = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSaveUploadedFileChecksContent(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("uploads", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	filename, err := SaveUploadedFile(newUpload("photo.png", fakePNG))
+	if err != nil {
+		t.Fatalf("saving a PNG: %v", err)
+	}
+	saved, err := os.ReadFile(filepath.Join("uploads", filename))
+	if err != nil {
+		t.Fatalf("reading the saved file: %v", err)
+	}
+	if !bytes.Equal(saved, fakePNG) {
+		t.Errorf("saved %d bytes, want the whole %d byte upload", len(saved), len(fakePNG))
+	}
+
+	rejected := []struct {
+		name     string
+		filename string
+		content  []byte
+	}{
+		{"text renamed to png", "notes.png", []byte("just some text, not an image\n")},
+		{"png renamed to jpg", "photo.jpg", fakePNG},
+		{"disallowed extension", "script.sh", []byte("#!/bin/sh\n")},
+	}
+	for _, tt := range rejected {
+		if filename, err := SaveUploadedFile(newUpload(tt.filename, tt.content)); err == nil {
+			t.Errorf("%s: saved as %q, want an error", tt.name, filename)
+		}
+	}
+
+	entries, _ := os.ReadDir("uploads")
+	if len(entries) != 1 {
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+		t.Errorf("uploads contains %s, want only the PNG", strings.Join(names, ", "))
+	}
+}
+
+func TestFormatQuantity(t *testing.T) {
+	tests := []struct {
+		quantity float64
+		want     string
+	}{
+		{0.25, "1/4"},
+		{0.333, "1/3"},
+		{0.33, "1/3"},
+		{0.5, "1/2"},
+		{0.67, "2/3"},
+		{0.75, "3/4"},
+		{1.5, "1 1/2"},
+		{2.25, "2 1/4"},
+		{2.0, "2"},
+		{1.999, "2"},
+		{3.004, "3"},
+		{0.1, "0.1"},
+		{1.6, "1.6"},
+		{0.125, "0.13"},
+		{250, "250"},
+		{0, "0"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatQuantity(tt.quantity); got != tt.want {
+			t.Errorf("FormatQuantity(%v) = %q, want %q", tt.quantity, got, tt.want)
+		}
+	}
+}
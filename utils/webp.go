@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// convertWebP re-encodes JPEG and PNG uploads to WebP, see SetConvertWebP
+var convertWebP bool
+
+// SetConvertWebP turns the WebP conversion of uploaded JPEG and PNG images on or off,
+// see CONVERT_WEBP
+func SetConvertWebP(enabled bool) {
+	convertWebP = enabled
+}
+
+// maxWebPPixels bounds the images that are converted, as decoding needs four bytes per
+// pixel; a small file can declare very large dimensions
+const maxWebPPixels = 16_000_000
+
+// webpDecoders decode the upload types that are converted to WebP
+var webpDecoders = map[string]struct {
+	decode       func(io.Reader) (image.Image, error)
+	decodeConfig func(io.Reader) (image.Config, error)
+}{
+	"image/jpeg": {jpeg.Decode, jpeg.DecodeConfig},
+	"image/png":  {png.Decode, png.DecodeConfig},
+}
+
+// EncodeWebP decodes a JPEG or PNG image and encodes it as WebP with the same dimensions.
+// The encoder is lossless, so the caller should keep whichever file is smaller.
+func EncodeWebP(r io.ReadSeeker, contentType string) ([]byte, error) {
+	decoder, ok := webpDecoders[contentType]
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %s to WebP", contentType)
+	}
+
+	config, err := decoder.decodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxWebPPixels {
+		return nil, fmt.Errorf("image is too large to convert (%dx%d)", config.Width, config.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	img, err := decoder.decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// saveAsWebP stores a WebP version of an uploaded JPEG or PNG image of size bytes in
+// uploads. It reports false, leaving the original to be stored, for other types, when
+// encoding fails and when the WebP file would not be smaller.
+func saveAsWebP(file io.ReadSeeker, contentType string, size int64) (string, bool) {
+	if _, ok := webpDecoders[contentType]; !ok {
+		return "", false
+	}
+
+	encoded, err := EncodeWebP(file, contentType)
+	if err != nil {
+		log.Printf("Keeping the uploaded %s, WebP conversion failed: %v", contentType, err)
+		return "", false
+	}
+	if int64(len(encoded)) >= size {
+		return "", false
+	}
+
+	filename := GenerateUniqueFilename("image.webp")
+	if err := os.WriteFile(filepath.Join("uploads", filename), encoded, 0644); err != nil {
+		log.Printf("Keeping the uploaded %s, saving the WebP version failed: %v", contentType, err)
+		os.Remove(filepath.Join("uploads", filename))
+		return "", false
+	}
+	return filename, true
+}
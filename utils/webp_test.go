@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPNG encodes a width by height PNG of horizontal color bands without compression,
+// so that its WebP version is smaller
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{uint8(y * 255 / height), 120, uint8(255 - y*255/height), 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.NoCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// webpDimensions checks that data is a lossless WebP file and returns its dimensions
+func webpDimensions(t *testing.T, data []byte) (int, int) {
+	t.Helper()
+
+	if contentType := http.DetectContentType(data); contentType != "image/webp" {
+		t.Fatalf("content is detected as %s, want image/webp", contentType)
+	}
+	if len(data) < 25 || string(data[12:16]) != "VP8L" || data[20] != 0x2f {
+		t.Fatalf("content is not a VP8L bitstream")
+	}
+	if size := binary.LittleEndian.Uint32(data[4:8]); int(size) != len(data)-8 {
+		t.Errorf("RIFF size is %d, want %d", size, len(data)-8)
+	}
+
+	// 14 bits each of width - 1 and height - 1 follow the signature byte
+	bits := binary.LittleEndian.Uint32(data[21:25])
+	return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1
+}
+
+func TestEncodeWebP(t *testing.T) {
+	encoded, err := EncodeWebP(bytes.NewReader(testPNG(t, 64, 48)), "image/png")
+	if err != nil {
+		t.Fatalf("EncodeWebP: %v", err)
+	}
+
+	if width, height := webpDimensions(t, encoded); width != 64 || height != 48 {
+		t.Errorf("WebP is %dx%d, want the original 64x48", width, height)
+	}
+}
+
+func TestEncodeWebPRejects(t *testing.T) {
+	if _, err := EncodeWebP(bytes.NewReader(fakePNG), "image/png"); err == nil {
+		t.Error("EncodeWebP accepted a truncated PNG")
+	}
+	if _, err := EncodeWebP(bytes.NewReader(testPNG(t, 4, 4)), "image/gif"); err == nil {
+		t.Error("EncodeWebP accepted a type it does not convert")
+	}
+
+	// The header of a PNG declaring 5000x5000 pixels is enough to refuse it
+	huge := testPNG(t, 1, 1)
+	binary.BigEndian.PutUint32(huge[16:20], 5000)
+	binary.BigEndian.PutUint32(huge[20:24], 5000)
+	binary.BigEndian.PutUint32(huge[29:33], crc32.ChecksumIEEE(huge[12:29]))
+	if _, err := EncodeWebP(bytes.NewReader(huge), "image/png"); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("EncodeWebP on a 25 megapixel PNG returned %v, want a too large error", err)
+	}
+}
+
+func TestSaveUploadedFileConvertsToWebP(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("uploads", 0755); err != nil {
+		t.Fatal(err)
+	}
+	SetConvertWebP(true)
+	t.Cleanup(func() { SetConvertWebP(false) })
+
+	filename, err := SaveUploadedFile(newUpload("bands.png", testPNG(t, 64, 48)))
+	if err != nil {
+		t.Fatalf("SaveUploadedFile: %v", err)
+	}
+	if filepath.Ext(filename) != ".webp" {
+		t.Fatalf("stored as %s, want a .webp file", filename)
+	}
+
+	saved, err := os.ReadFile(filepath.Join("uploads", filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width, height := webpDimensions(t, saved); width != 64 || height != 48 {
+		t.Errorf("stored WebP is %dx%d, want the original 64x48", width, height)
+	}
+
+	// A PNG the WebP version would not be smaller than is stored as it was uploaded
+	var compact bytes.Buffer
+	if err := png.Encode(&compact, image.NewGray(image.Rect(0, 0, 64, 48))); err != nil {
+		t.Fatal(err)
+	}
+	filename, err = SaveUploadedFile(newUpload("blank.png", compact.Bytes()))
+	if err != nil {
+		t.Fatalf("SaveUploadedFile: %v", err)
+	}
+	if filepath.Ext(filename) != ".png" {
+		t.Errorf("PNG with a larger WebP version stored as %s, want the original .png", filename)
+	}
+
+	// A PNG that cannot be decoded is stored as it was uploaded
+	filename, err = SaveUploadedFile(newUpload("broken.png", fakePNG))
+	if err != nil {
+		t.Fatalf("SaveUploadedFile: %v", err)
+	}
+	if filepath.Ext(filename) != ".png" {
+		t.Errorf("undecodable PNG stored as %s, want the original .png", filename)
+	}
+}